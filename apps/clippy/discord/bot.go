@@ -4,43 +4,85 @@ package discord
 import (
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
 	"github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
 	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/features"
+	"github.com/sawyer/go-discord-bots/pkg/guilds"
 	"github.com/sawyer/go-discord-bots/pkg/logging"
 	"github.com/sawyer/go-discord-bots/pkg/metrics"
+	"github.com/sawyer/go-discord-bots/pkg/outbound"
+	"github.com/sawyer/go-discord-bots/pkg/presence"
 )
 
 // Bot represents a Discord bot instance with all necessary components.
 type Bot struct {
 	session         *discordgo.Session
 	config          *config.Config
+	features        *features.Manager
+	guilds          *guilds.Settings
+	audit           *audit.Recorder
+	presence        *presence.Manager
+	outbound        *outbound.Dispatcher
 	commandHandlers map[string]CommandHandler
 	registeredCmds  []*discordgo.ApplicationCommand
 	randomTicker    *time.Ticker
 	stopRandomChan  chan struct{}
+	randomDue       map[string]time.Time // guild ID, or "" for the shared schedule -> next random message
+	randomMutex     sync.Mutex
 	quotes          []string
 	wisdomQuotes    []string
 }
 
+// randomMessagesFlag controls random messages and replies per guild.
+const randomMessagesFlag = "clippy.random_messages"
+
+// randomCheckInterval is how often guilds are checked for a due random message.
+const randomCheckInterval = time.Minute
+
 // CommandHandler represents a function that handles Discord bot commands.
 type CommandHandler func(s *discordgo.Session, i *discordgo.InteractionCreate) error
 
 // NewBot creates a new Discord bot instance.
 func NewBot(cfg *config.Config) (*Bot, error) {
-	session, err := discordgo.New("Bot " + cfg.DiscordToken)
+	session, err := discordgo.New("Bot " + cfg.DiscordToken.Value())
 	if err != nil {
 		return nil, errors.NewDiscordError("failed to create Discord session", err)
 	}
 
+	flags, err := features.NewManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	flags.Define(randomMessagesFlag, true, "Random Clippy messages and replies")
+
+	recorder, err := audit.OpenRecorder(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := presence.NewManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	bot := &Bot{
 		session:         session,
 		config:          cfg,
+		features:        flags,
+		guilds:          guilds.NewSettings(cfg),
+		audit:           recorder,
+		presence:        activity,
+		outbound:        outbound.NewDispatcher(cfg),
 		commandHandlers: make(map[string]CommandHandler),
 		registeredCmds:  make([]*discordgo.ApplicationCommand, 0),
 		stopRandomChan:  make(chan struct{}),
+		randomDue:       make(map[string]time.Time),
 		quotes:          getClippyQuotes(),
 		wisdomQuotes:    getWisdomQuotes(),
 	}
@@ -61,7 +103,7 @@ func NewBot(cfg *config.Config) (*Bot, error) {
 
 // Start starts the Discord bot.
 func (b *Bot) Start() error {
-	logger := logging.WithComponent("discord")
+	logger := withComponent("discord")
 	logger.Info("Starting bot", "bot_name", b.config.BotName)
 
 	err := b.session.Open()
@@ -71,21 +113,28 @@ func (b *Bot) Start() error {
 
 	logger.Info("Bot is now running", "username", b.session.State.User.Username)
 
-	// Start random responses if enabled
-	if b.config.RandomResponses {
-		b.startRandomResponses()
-	}
+	// Start recording command usage
+	b.audit.Start()
+
+	// Start delivering queued messages and rotating the status
+	b.outbound.Start(b.session)
+	b.presence.Start(b.session)
+
+	// Start the random response loop; it stays idle while random responses are disabled
+	b.startRandomResponses()
 
 	return nil
 }
 
 // Stop stops the Discord bot.
 func (b *Bot) Stop() error {
-	logger := logging.WithComponent("discord")
+	logger := withComponent("discord")
 	logger.Info("Stopping bot", "bot_name", b.config.BotName)
 
-	// Stop random responses
+	// Stop random responses and status rotation, then deliver queued messages
 	b.stopRandomResponses()
+	b.presence.Stop()
+	b.outbound.Stop()
 
 	// Remove commands
 	if err := b.removeCommands(); err != nil {
@@ -96,12 +145,15 @@ func (b *Bot) Stop() error {
 		return errors.NewDiscordError("failed to close Discord session", err)
 	}
 
+	// Flush pending audit entries
+	b.audit.Stop()
+
 	return nil
 }
 
 // ready handles the ready event.
 func (b *Bot) ready(s *discordgo.Session, event *discordgo.Ready) {
-	logger := logging.WithComponent("discord")
+	logger := withComponent("discord")
 	logger.Info("Bot is ready", "username", event.User.Username)
 
 	// Register slash commands
@@ -116,55 +168,44 @@ func (b *Bot) registerCommands() {
 	b.commandHandlers["clippy_wisdom"] = b.handleWisdomCommand
 	b.commandHandlers["clippy_help"] = b.handleHelpCommand
 	b.commandHandlers["clippy_stats"] = b.handleStatsCommand
+	b.commandHandlers["clippy_features"] = b.features.HandleAdminCommand
+	b.commandHandlers["audit"] = b.audit.HandleAdminCommand
 }
 
-// isValidGuildID checks if the guild ID is a valid Discord snowflake.
-func (b *Bot) isValidGuildID(guildID string) bool {
-	if guildID == "" {
-		return false
-	}
-
-	// Discord snowflakes are 17-19 digit numbers
-	if len(guildID) < 17 || len(guildID) > 19 {
-		return false
-	}
-
-	// Check if all characters are digits
-	for _, char := range guildID {
-		if char < '0' || char > '9' {
-			return false
-		}
-	}
-
-	return true
-}
-
-// registerSlashCommands registers all slash commands with Discord.
-func (b *Bot) registerSlashCommands() error {
-	logger := logging.WithComponent("discord")
-
-	commands := []*discordgo.ApplicationCommand{
-		{
+// Commands returns the application commands Clippy registers.
+func Commands() []*discordgo.ApplicationCommand {
+	// Clippy only replies through interactions, so its commands also work in
+	// DMs and wherever a user has installed the app.
+	return []*discordgo.ApplicationCommand{
+		shareddiscord.Everywhere(&discordgo.ApplicationCommand{
 			Name:        "clippy",
 			Description: "Get an unhinged Clippy response",
-		},
-		{
+		}),
+		shareddiscord.Everywhere(&discordgo.ApplicationCommand{
 			Name:        "clippy_wisdom",
 			Description: "Receive Clippy's questionable wisdom",
-		},
-		{
+		}),
+		shareddiscord.Everywhere(&discordgo.ApplicationCommand{
 			Name:        "clippy_help",
 			Description: "Get help from Clippy (if you dare)",
-		},
-		{
+		}),
+		shareddiscord.Everywhere(&discordgo.ApplicationCommand{
 			Name:        "clippy_stats",
 			Description: "View Clippy's performance statistics",
-		},
+		}),
+		features.AdminCommand("clippy_features"),
+		audit.AdminCommand("audit"),
 	}
+}
+
+// registerSlashCommands registers all slash commands with Discord.
+func (b *Bot) registerSlashCommands() error {
+	logger := withComponent("discord")
+	commands := Commands()
 
 	// Determine if we should register globally or guild-specific
 	var guildID string
-	if b.isValidGuildID(b.config.GuildID) {
+	if shareddiscord.IsValidGuildID(b.config.GuildID) {
 		guildID = b.config.GuildID
 		logger.Info("Registering guild-specific commands", "guild_id", guildID)
 	} else {
@@ -176,15 +217,17 @@ func (b *Bot) registerSlashCommands() error {
 		guildID = ""
 	}
 
-	// Register each command
+	// Register each command, limiting dark-launched commands to their guilds
 	for _, command := range commands {
-		cmd, err := b.session.ApplicationCommandCreate(b.session.State.User.ID, guildID, command)
-		if err != nil {
-			return errors.NewDiscordError(fmt.Sprintf("failed to register command %s", command.Name), err)
-		}
+		for _, commandGuildID := range b.features.CommandGuilds(command.Name, guildID) {
+			cmd, err := b.session.ApplicationCommandCreate(b.session.State.User.ID, commandGuildID, command)
+			if err != nil {
+				return errors.NewDiscordError(fmt.Sprintf("failed to register command %s", command.Name), err)
+			}
 
-		b.registeredCmds = append(b.registeredCmds, cmd)
-		logger.Info("Registered command", "command", command.Name, "guild_specific", guildID != "")
+			b.registeredCmds = append(b.registeredCmds, cmd)
+			logger.Info("Registered command", "command", command.Name, "guild_specific", commandGuildID != "")
+		}
 	}
 
 	return nil
@@ -192,16 +235,11 @@ func (b *Bot) registerSlashCommands() error {
 
 // removeCommands removes all registered commands.
 func (b *Bot) removeCommands() error {
-	logger := logging.WithComponent("discord")
+	logger := withComponent("discord")
 
 	// Remove previously registered commands
 	for _, cmd := range b.registeredCmds {
-		var guildID string
-		if b.isValidGuildID(b.config.GuildID) {
-			guildID = b.config.GuildID
-		}
-
-		err := b.session.ApplicationCommandDelete(b.session.State.User.ID, guildID, cmd.ID)
+		err := b.session.ApplicationCommandDelete(b.session.State.User.ID, cmd.GuildID, cmd.ID)
 		if err != nil {
 			logger.Error("Failed to delete command", "command", cmd.Name, "error", err)
 		} else {
@@ -238,9 +276,30 @@ func (b *Bot) handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionC
 		return
 	}
 
+	if !b.features.Enabled(features.CommandFlag(commandName), i.GuildID) {
+		if err := features.RespondDisabled(s, i); err != nil {
+			logger := withComponent("discord")
+			logger.Error("Failed to send disabled command response", "error", err)
+		}
+		b.audit.RecordResult(audit.FromInteraction(i), start, errors.NewPermissionError("command disabled in this guild", nil))
+		return
+	}
+
+	if ok, err := b.guilds.CheckInteraction(s, i); !ok {
+		if err != nil {
+			logger := withComponent("discord")
+			logger.Error("Failed to send command rejection", "error", err)
+		}
+		b.audit.RecordResult(audit.FromInteraction(i), start, errors.NewPermissionError("command not allowed by guild settings", nil))
+		return
+	}
+
 	// Execute command
-	if err := handler(s, i); err != nil {
-		logger := logging.WithComponent("discord").With(
+	err := handler(s, i)
+	b.audit.RecordResult(audit.FromInteraction(i), start, err)
+
+	if err != nil {
+		logger := withComponent("discord").With(
 			"user_id", getUserID(i),
 			"username", getUsername(i),
 			"command", commandName,
@@ -250,7 +309,7 @@ func (b *Bot) handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionC
 		b.sendErrorMessage(s, i, "Sorry, something went wrong processing your command.")
 	} else {
 		metrics.RecordCommand(commandName, getUserID(i), true, time.Since(start))
-		logging.LogDiscordCommand(getUserID(i), getUsername(i), commandName, true)
+		logging.LogDiscordCommand(string(config.BotTypeClipper), getUserID(i), getUsername(i), commandName, true)
 	}
 }
 
@@ -277,9 +336,10 @@ func (b *Bot) handleComponentInteraction(s *discordgo.Session, i *discordgo.Inte
 			Flags:   discordgo.MessageFlagsEphemeral,
 		},
 	})
+	b.audit.RecordResult(audit.FromInteraction(i), start, err)
 
 	if err != nil {
-		logger := logging.WithComponent("discord").With(
+		logger := withComponent("discord").With(
 			"user_id", getUserID(i),
 			"username", getUsername(i),
 			"custom_id", customID,
@@ -288,20 +348,24 @@ func (b *Bot) handleComponentInteraction(s *discordgo.Session, i *discordgo.Inte
 		metrics.RecordCommand("component_"+customID, getUserID(i), false, time.Since(start))
 	} else {
 		metrics.RecordCommand("component_"+customID, getUserID(i), true, time.Since(start))
-		logging.LogDiscordCommand(getUserID(i), getUsername(i), "component_"+customID, true)
+		logging.LogDiscordCommand(string(config.BotTypeClipper), getUserID(i), getUsername(i), "component_"+customID, true)
 	}
 }
 
 // messageCreate handles incoming messages for random responses.
 func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore messages from bots
-	if m.Author.Bot {
+	if m.Author == nil || m.Author.Bot {
 		return
 	}
 
-	// Random responses (2% chance)
-	if b.config.RandomResponses && rand.Float64() < 0.02 {
-		b.sendRandomResponse(s, m)
+	// Random responses (2% chance) in guilds and channels that allow them
+	cfg := b.guilds.ConfigFor(m.GuildID)
+	if !cfg.RandomResponses || !b.guilds.ChannelAllowed(m.GuildID, m.ChannelID) {
+		return
+	}
+	if rand.Float64() < 0.02 && b.features.Enabled(randomMessagesFlag, m.GuildID) {
+		b.sendRandomResponse(s, m, cfg.RandomMessageDelay.Std())
 	}
 }
 
@@ -347,7 +411,7 @@ func (b *Bot) handleHelpCommand(s *discordgo.Session, i *discordgo.InteractionCr
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name:   "🎭 Commands",
-				Value:  "`/clippy` - Get a classic unhinged Clippy response\n`/clippy_wisdom` - Receive questionable life advice\n`/clippy_help` - Get help (if you dare)\n`/clippy_stats` - View performance statistics",
+				Value:  "`/clippy` - Get a classic unhinged Clippy response\n`/clippy_wisdom` - Receive questionable life advice\n`/clippy_help` - Get help (if you dare)\n`/clippy_stats` - View performance statistics\n`/clippy_features` - Manage features for this server (admins)\n`/audit` - Recent command usage in this server (admins)",
 				Inline: false,
 			},
 			{
@@ -445,43 +509,37 @@ func (b *Bot) handleStatsCommand(s *discordgo.Session, i *discordgo.InteractionC
 }
 
 // sendRandomResponse sends a random response to a message with a delay.
-func (b *Bot) sendRandomResponse(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (b *Bot) sendRandomResponse(s *discordgo.Session, m *discordgo.MessageCreate, messageDelay time.Duration) {
 	// Add a slight delay to make it feel more natural
-	delay := time.Duration(rand.Intn(int(b.config.RandomMessageDelay.Seconds())+1)) * time.Second
+	delay := time.Duration(rand.Intn(int(messageDelay.Seconds())+1)) * time.Second
 	time.Sleep(delay)
 
 	quote := b.quotes[rand.Intn(len(b.quotes))]
 
-	_, err := s.ChannelMessageSend(m.ChannelID, quote)
+	_, err := b.outbound.SendText(m.ChannelID, quote)
 	if err != nil {
-		logger := logging.WithComponent("discord")
+		logger := withComponent("discord")
 		logger.Error("Failed to send random response", "error", err)
 	} else {
-		logger := logging.WithComponent("discord")
+		logger := withComponent("discord")
 		logger.Info("Sent random response", "channel", m.ChannelID, "user", m.Author.Username)
 	}
 }
 
-// startRandomResponses starts sending random responses at intervals.
+// startRandomResponses starts sending random messages on the bot's
+// random_interval and on the intervals of guilds that set their own.
 func (b *Bot) startRandomResponses() {
-	logger := logging.WithComponent("discord")
-	logger.Info("Starting random responses", "interval", b.config.RandomInterval)
-
-	// Calculate random intervals around the base interval
-	minInterval := b.config.RandomInterval - (b.config.RandomInterval / 4)
-	maxInterval := b.config.RandomInterval + (b.config.RandomInterval / 4)
-	interval := minInterval + time.Duration(rand.Int63n(int64(maxInterval-minInterval)))
+	logger := withComponent("discord")
+	cfg := b.guilds.ConfigFor("")
+	logger.Info("Starting random responses", "enabled", cfg.RandomResponses, "interval", cfg.RandomInterval)
 
-	b.randomTicker = time.NewTicker(interval)
+	b.randomTicker = time.NewTicker(randomCheckInterval)
 
 	go func() {
 		for {
 			select {
 			case <-b.randomTicker.C:
-				b.sendRandomMessage()
-				// Reset ticker with new random interval
-				newInterval := minInterval + time.Duration(rand.Int63n(int64(maxInterval-minInterval)))
-				b.randomTicker.Reset(newInterval)
+				b.sendRandomMessages()
 			case <-b.stopRandomChan:
 				return
 			}
@@ -489,6 +547,52 @@ func (b *Bot) startRandomResponses() {
 	}()
 }
 
+// nextRandomInterval picks a random interval within 25% of a base interval.
+func nextRandomInterval(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		interval = 45 * time.Minute
+	}
+
+	minInterval := interval - (interval / 4)
+	maxInterval := interval + (interval / 4)
+	if maxInterval <= minInterval {
+		return interval
+	}
+
+	return minInterval + time.Duration(rand.Int63n(int64(maxInterval-minInterval)))
+}
+
+// ApplyConfig applies hot-reloadable settings from a configuration change.
+func (b *Bot) ApplyConfig(change *config.Change) {
+	b.guilds.Update(change.New)
+
+	// Reschedule random messages with the new intervals
+	if change.Has("random_interval") || change.Has("guilds") {
+		b.randomMutex.Lock()
+		clear(b.randomDue)
+		b.randomMutex.Unlock()
+	}
+
+	if change.Has("features") {
+		b.features.Update(change.New.Features)
+	}
+
+	logger := withComponent("discord")
+
+	if change.Has("presence") || change.Has("presence_interval") {
+		if err := b.presence.Update(change.New); err != nil {
+			logging.LogError(logger, err, "Failed to apply presence configuration")
+		}
+	}
+
+	logger.Info("Applied configuration change",
+		"command_cooldown", change.New.CommandCooldown,
+		"random_responses", change.New.RandomResponses,
+		"random_interval", change.New.RandomInterval,
+		"random_message_delay", change.New.RandomMessageDelay,
+	)
+}
+
 // stopRandomResponses stops sending random responses.
 func (b *Bot) stopRandomResponses() {
 	if b.randomTicker != nil {
@@ -497,19 +601,64 @@ func (b *Bot) stopRandomResponses() {
 	close(b.stopRandomChan)
 }
 
-// sendRandomMessage sends a random message to a random channel.
-func (b *Bot) sendRandomMessage() {
-	if len(b.session.State.Guilds) == 0 {
-		return
+// sendRandomMessages sends the random messages that are due. Guilds that
+// set their own random_interval or allowed_channels have their own schedule;
+// the others share the bot's schedule, which sends one message to one of
+// them per interval.
+func (b *Bot) sendRandomMessages() {
+	now := time.Now()
+	global := b.guilds.ConfigFor("")
+
+	var shared []*discordgo.Guild
+	for _, guild := range b.session.State.Guilds {
+		cfg := b.guilds.ConfigFor(guild.ID)
+		if !cfg.RandomResponses || !b.features.Enabled(randomMessagesFlag, guild.ID) {
+			continue
+		}
+
+		if !ownRandomSchedule(global, guild.ID) {
+			shared = append(shared, guild)
+			continue
+		}
+		if b.randomMessageDue(guild.ID, cfg.RandomInterval.Std(), now) {
+			b.sendRandomMessage(guild, cfg)
+		}
 	}
 
-	// Pick a random guild
-	guild := b.session.State.Guilds[rand.Intn(len(b.session.State.Guilds))]
+	if len(shared) > 0 && b.randomMessageDue("", global.RandomInterval.Std(), now) {
+		guild := shared[rand.Intn(len(shared))]
+		b.sendRandomMessage(guild, b.guilds.ConfigFor(guild.ID))
+	}
+}
 
+// randomMessageDue reports whether the next message of a schedule is due and
+// schedules the one after it. A new schedule gets its first message after a
+// full interval.
+func (b *Bot) randomMessageDue(key string, interval time.Duration, now time.Time) bool {
+	b.randomMutex.Lock()
+	defer b.randomMutex.Unlock()
+
+	due, scheduled := b.randomDue[key]
+	if scheduled && now.Before(due) {
+		return false
+	}
+	b.randomDue[key] = now.Add(nextRandomInterval(interval))
+	return scheduled
+}
+
+// ownRandomSchedule reports whether a guild overrides the random_interval or
+// allowed_channels of the bot's configuration.
+func ownRandomSchedule(cfg *config.Config, guildID string) bool {
+	guild, ok := cfg.Guilds[guildID]
+	return ok && (guild.RandomInterval != nil || len(guild.AllowedChannels) > 0)
+}
+
+// sendRandomMessage sends a random message to a random allowed channel of a guild.
+func (b *Bot) sendRandomMessage(guild *discordgo.Guild, cfg *config.Config) {
 	// Find text channels
 	var textChannels []*discordgo.Channel
 	for _, channel := range guild.Channels {
-		if channel.Type == discordgo.ChannelTypeGuildText {
+		if channel.Type == discordgo.ChannelTypeGuildText && cfg.ChannelAllowed(channel.ID) {
 			// Check permissions
 			permissions, err := b.session.UserChannelPermissions(b.session.State.User.ID, channel.ID)
 			if err == nil && permissions&discordgo.PermissionSendMessages != 0 {
@@ -526,12 +675,12 @@ func (b *Bot) sendRandomMessage() {
 	channel := textChannels[rand.Intn(len(textChannels))]
 	quote := b.quotes[rand.Intn(len(b.quotes))]
 
-	_, err := b.session.ChannelMessageSend(channel.ID, quote)
+	_, err := b.outbound.SendText(channel.ID, quote)
 	if err != nil {
-		logger := logging.WithComponent("discord")
+		logger := withComponent("discord")
 		logger.Error("Failed to send random message", "error", err)
 	} else {
-		logger := logging.WithComponent("discord")
+		logger := withComponent("discord")
 		logger.Info("Sent random message", "guild", guild.Name, "channel", channel.Name)
 	}
 }
@@ -551,7 +700,7 @@ func (b *Bot) sendErrorMessage(s *discordgo.Session, i *discordgo.InteractionCre
 			Flags:  discordgo.MessageFlagsEphemeral,
 		},
 	}); err != nil {
-		logger := logging.WithComponent("discord")
+		logger := withComponent("discord")
 		logger.Error("Failed to send error message", "error", err)
 	}
 }
@@ -560,24 +709,12 @@ func (b *Bot) sendErrorMessage(s *discordgo.Session, i *discordgo.InteractionCre
 
 // getUserID safely extracts user ID from interaction.
 func getUserID(i *discordgo.InteractionCreate) string {
-	if i.Member != nil && i.Member.User != nil {
-		return i.Member.User.ID
-	}
-	if i.User != nil {
-		return i.User.ID
-	}
-	return ""
+	return shareddiscord.InteractionUserID(i.Interaction)
 }
 
 // getUsername safely extracts username from interaction.
 func getUsername(i *discordgo.InteractionCreate) string {
-	if i.Member != nil && i.Member.User != nil {
-		return i.Member.User.Username
-	}
-	if i.User != nil {
-		return i.User.Username
-	}
-	return ""
+	return shareddiscord.InteractionUsername(i.Interaction)
 }
 
 // formatDuration formats a duration into a human-readable string.
@@ -28,6 +28,7 @@ type CardCache struct {
 	entries   map[string]*Entry
 	mutex     sync.RWMutex
 	ttl       time.Duration
+	ticker    *time.Ticker
 	maxSize   int
 	hits      int64
 	misses    int64
@@ -39,6 +40,7 @@ func NewCardCache(ttl time.Duration, maxSize int) *CardCache {
 	cache := &CardCache{
 		entries: make(map[string]*Entry),
 		ttl:     ttl,
+		ticker:  time.NewTicker(ttl / 2), // Run cleanup at half the TTL interval.
 		maxSize: maxSize,
 	}
 
@@ -71,6 +73,7 @@ func (c *CardCache) Get(cardName string) (*scryfall.Card, bool) {
 
 	c.mutex.RLock()
 	entry, exists := c.entries[key]
+	ttl := c.ttl
 	c.mutex.RUnlock()
 
 	if !exists {
@@ -83,7 +86,7 @@ func (c *CardCache) Get(cardName string) (*scryfall.Card, bool) {
 		return nil, false
 	}
 
-	if entry.IsExpired(c.ttl) {
+	if entry.IsExpired(ttl) {
 		// Remove expired entry.
 		c.mutex.Lock()
 		delete(c.entries, key)
@@ -223,14 +226,25 @@ func (c *CardCache) Clear() {
 
 // cleanupLoop periodically removes expired entries.
 func (c *CardCache) cleanupLoop() {
-	ticker := time.NewTicker(c.ttl / 2) // Run cleanup at half the TTL interval.
-	defer ticker.Stop()
-
-	for range ticker.C {
+	for range c.ticker.C {
 		c.cleanup()
 	}
 }
 
+// SetTTL changes the cache TTL. Existing entries are judged against the new TTL.
+func (c *CardCache) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	c.ttl = ttl
+	c.mutex.Unlock()
+
+	c.ticker.Reset(ttl / 2)
+	logging.Debug("Cache TTL updated", "ttl", ttl)
+}
+
 // cleanup removes expired entries from the cache.
 func (c *CardCache) cleanup() {
 	c.mutex.Lock()
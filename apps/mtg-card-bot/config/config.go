@@ -1,18 +1,18 @@
-// Package config handles application configuration loading from environment variables.
+// Package config holds the configuration of the MTG card bot, loaded through
+// the shared configuration loader.
 package config
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"strconv"
 	"strings"
 	"time"
+
+	sharedconfig "github.com/sawyer/go-discord-bots/pkg/config"
 )
 
 // Config holds the application configuration settings.
 type Config struct {
-	DiscordToken    string
+	DiscordToken    sharedconfig.Secret
 	CommandPrefix   string
 	LogLevel        string
 	JSONLogging     bool
@@ -25,74 +25,22 @@ type Config struct {
 	CacheSize       int
 }
 
-// Load loads configuration from environment variables.
-func Load() (*Config, error) {
-	cfg := &Config{
-		CommandPrefix:   "!",    // default prefix.
-		LogLevel:        "info", // default log level.
-		JSONLogging:     false,  // default to text logging.
-		BotName:         getEnv("BOT_NAME", "mtg-card-bot"),
-		ShutdownTimeout: 30 * time.Second, // default shutdown timeout.
-		RequestTimeout:  30 * time.Second, // default request timeout.
-		MaxRetries:      3,                // default max retries.
-		DebugMode:       false,            // default debug mode.
-		CacheTTL:        1 * time.Hour,    // increased cache TTL for better performance.
-		CacheSize:       1000,             // increased cache size for better hit rate.
-	}
-
-	// Discord token is required.
-	cfg.DiscordToken = os.Getenv("MTG_DISCORD_TOKEN")
-	if cfg.DiscordToken == "" {
-		return nil, fmt.Errorf("MTG_DISCORD_TOKEN environment variable is required")
-	}
-
-	// Optional configurations.
-	if prefix := os.Getenv("COMMAND_PREFIX"); prefix != "" {
-		cfg.CommandPrefix = prefix
-	}
-
-	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
-		cfg.LogLevel = strings.ToLower(logLevel)
-	}
-
-	// Parse timeout configurations.
-	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
-		if parsed, err := time.ParseDuration(timeout); err == nil {
-			cfg.ShutdownTimeout = parsed
-		} else {
-			log.Printf("Warning: invalid SHUTDOWN_TIMEOUT format '%s', using default", timeout)
-		}
-	}
-
-	if timeout := os.Getenv("REQUEST_TIMEOUT"); timeout != "" {
-		if parsed, err := time.ParseDuration(timeout); err == nil {
-			cfg.RequestTimeout = parsed
-		} else {
-			log.Printf("Warning: invalid REQUEST_TIMEOUT format '%s', using default", timeout)
-		}
-	}
-
-	// Parse retry configuration.
-	cfg.MaxRetries = GetInt("MAX_RETRIES", cfg.MaxRetries)
-
-	// Parse debug mode.
-	cfg.DebugMode = GetBool("DEBUG", cfg.DebugMode)
-
-	// Parse JSON logging.
-	cfg.JSONLogging = GetBool("JSON_LOGGING", cfg.JSONLogging)
-
-	// Parse cache configuration.
-	if ttl := os.Getenv("CACHE_TTL"); ttl != "" {
-		if parsed, err := time.ParseDuration(ttl); err == nil {
-			cfg.CacheTTL = parsed
-		} else {
-			log.Printf("Warning: invalid CACHE_TTL format '%s', using default", ttl)
-		}
+// FromShared converts the shared configuration of the MTG bot, loaded like
+// every bot's (see sharedconfig.LoadWith), into the bot's own configuration.
+func FromShared(shared *sharedconfig.Config) *Config {
+	return &Config{
+		DiscordToken:    shared.DiscordToken,
+		CommandPrefix:   shared.CommandPrefix,
+		LogLevel:        strings.ToLower(shared.LogLevel),
+		JSONLogging:     shared.JSONLogging,
+		BotName:         shared.BotName,
+		ShutdownTimeout: shared.ShutdownTimeout.Std(),
+		RequestTimeout:  shared.RequestTimeout.Std(),
+		MaxRetries:      shared.MaxRetries,
+		DebugMode:       shared.DebugMode,
+		CacheTTL:        shared.CacheTTL.Std(),
+		CacheSize:       shared.CacheSize,
 	}
-
-	cfg.CacheSize = GetInt("CACHE_SIZE", cfg.CacheSize)
-
-	return cfg, nil
 }
 
 // Validate validates the configuration.
@@ -133,45 +81,6 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-// GetBool returns a boolean environment variable with a default value.
-func GetBool(key string, defaultValue bool) bool {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-
-	boolVal, err := strconv.ParseBool(value)
-	if err != nil {
-		return defaultValue
-	}
-
-	return boolVal
-}
-
-// GetInt returns an integer environment variable with a default value.
-func GetInt(key string, defaultValue int) int {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-
-	intVal, err := strconv.Atoi(value)
-	if err != nil {
-		return defaultValue
-	}
-
-	return intVal
-}
-
-// getEnv returns an environment variable with a default value.
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-
-	return defaultValue
-}
-
 // contains checks if a slice contains a string.
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
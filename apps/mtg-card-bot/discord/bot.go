@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -18,7 +19,12 @@ import (
 	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/logging"
 	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/metrics"
 	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/scryfall"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
 	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/features"
+	"github.com/sawyer/go-discord-bots/pkg/guilds"
+	"github.com/sawyer/go-discord-bots/pkg/outbound"
+	"github.com/sawyer/go-discord-bots/pkg/presence"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -29,9 +35,20 @@ type Bot struct {
 	config          *config.Config
 	scryfallClient  *scryfall.Client
 	cache           *cache.CardCache
+	features        *features.Manager
+	guilds          *guilds.Settings
+	audit           *audit.Recorder
+	presence        *presence.Manager
+	outbound        *outbound.Dispatcher
 	commandHandlers map[string]CommandHandler
+	registeredCmds  []*discordgo.ApplicationCommand
+	cardsLookedUp   atomic.Int64
 }
 
+// priceDisplayFlag controls whether card embeds show prices per guild. It is
+// off unless configured or enabled by a guild's admins.
+const priceDisplayFlag = "mtg.price_display"
+
 // CommandHandler represents a function that handles Discord bot commands.
 type CommandHandler func(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error
 
@@ -44,8 +61,8 @@ type multiResolved struct {
 }
 
 // NewBot creates a new Discord bot instance.
-func NewBot(cfg *config.Config, scryfallClient *scryfall.Client, cardCache *cache.CardCache) (*Bot, error) {
-	session, err := discordgo.New("Bot " + cfg.DiscordToken)
+func NewBot(cfg *config.Config, scryfallClient *scryfall.Client, cardCache *cache.CardCache, flags *features.Manager, guildSettings *guilds.Settings, recorder *audit.Recorder, activity *presence.Manager, outbox *outbound.Dispatcher) (*Bot, error) {
+	session, err := discordgo.New("Bot " + cfg.DiscordToken.Value())
 	if err != nil {
 		return nil, errors.NewDiscordError("failed to create Discord session", err)
 	}
@@ -55,14 +72,24 @@ func NewBot(cfg *config.Config, scryfallClient *scryfall.Client, cardCache *cach
 		config:          cfg,
 		scryfallClient:  scryfallClient,
 		cache:           cardCache,
+		features:        flags,
+		guilds:          guildSettings,
+		audit:           recorder,
+		presence:        activity,
+		outbound:        outbox,
 		commandHandlers: make(map[string]CommandHandler),
 	}
 
+	flags.Define(priceDisplayFlag, false, "Show card prices in lookups")
+	activity.AddValues(bot.presenceValues)
+
 	// Register command handlers.
 	bot.registerCommands()
 
-	// Add message handler.
+	// Add message and slash command handlers.
 	session.AddHandler(bot.messageCreate)
+	session.AddHandler(bot.interactionCreate)
+	session.AddHandler(bot.ready)
 
 	// Set intents.
 	session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages
@@ -82,6 +109,9 @@ func (b *Bot) Start() error {
 
 	logger.Info("Bot is now running", "username", b.session.State.User.Username)
 
+	b.outbound.Start(b.session)
+	b.presence.Start(b.session)
+
 	return nil
 }
 
@@ -90,6 +120,10 @@ func (b *Bot) Stop() error {
 	logger := logging.WithComponent("discord")
 	logger.Info("Stopping bot", "bot_name", b.config.BotName)
 
+	b.presence.Stop()
+	b.outbound.Stop()
+	b.removeSlashCommands()
+
 	if err := b.session.Close(); err != nil {
 		return errors.NewDiscordError("failed to close Discord session", err)
 	}
@@ -103,13 +137,15 @@ func (b *Bot) registerCommands() {
 	b.commandHandlers["help"] = b.handleHelp
 	b.commandHandlers["stats"] = b.handleStats
 	b.commandHandlers["cache"] = b.handleCacheStats
+	b.commandHandlers["features"] = b.handleFeatures
+	b.commandHandlers["audit"] = b.handleAudit
 	// Card lookup is handled differently since it uses dynamic card names.
 }
 
 // messageCreate handles incoming messages.
 func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore messages from bots.
-	if m.Author.Bot {
+	if m.Author == nil || m.Author.Bot {
 		return
 	}
 
@@ -118,12 +154,24 @@ func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 
+	// Stay quiet in channels the guild does not allow commands in.
+	if !b.guilds.ChannelAllowed(m.GuildID, m.ChannelID) {
+		return
+	}
+
 	// Remove prefix
 	content := strings.TrimPrefix(m.Content, b.config.CommandPrefix)
 
+	start := time.Now()
+
 	// If the content contains semicolons, treat as multi-card lookup.
 	if strings.Contains(content, ";") {
-		if err := b.handleMultiCardLookup(s, m, content); err != nil {
+		if !b.checkCooldown(s, m, cardCommandName) {
+			return
+		}
+		err := b.handleMultiCardLookup(s, m, content)
+		b.audit.RecordResult(audit.FromMessage(m, "multi_card_lookup", []string{content}), start, err)
+		if err != nil {
 			logger := logging.WithComponent("discord").With(
 				"user_id", m.Author.ID,
 				"username", m.Author.Username,
@@ -150,7 +198,18 @@ func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 
 	// Handle specific commands.
 	if handler, exists := b.commandHandlers[command]; exists {
-		if err := handler(s, m, args); err != nil {
+		if !b.features.Enabled(features.CommandFlag(command), m.GuildID) {
+			b.sendErrorMessage(s, m.ChannelID, "This command is not enabled in this server.")
+			b.audit.RecordResult(audit.FromMessage(m, command, args), start, errors.NewPermissionError("command disabled in this guild", nil))
+			return
+		}
+		if !b.checkCooldown(s, m, command) {
+			return
+		}
+
+		err := handler(s, m, args)
+		b.audit.RecordResult(audit.FromMessage(m, command, args), start, err)
+		if err != nil {
 			logger := logging.WithComponent("discord").With(
 				"user_id", m.Author.ID,
 				"username", m.Author.Username,
@@ -169,8 +228,13 @@ func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	}
 
 	// If no specific handler, treat it as a card lookup.
+	if !b.checkCooldown(s, m, cardCommandName) {
+		return
+	}
 	cardQuery := strings.Join(parts, " ")
-	if err := b.handleCardLookup(s, m, cardQuery); err != nil {
+	err := b.handleCardLookup(s, m, cardQuery)
+	b.audit.RecordResult(audit.FromMessage(m, "card_lookup", parts), start, err)
+	if err != nil {
 		logger := logging.WithComponent("discord").With(
 			"user_id", m.Author.ID,
 			"username", m.Author.Username,
@@ -180,25 +244,24 @@ func (b *Bot) messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		metrics.RecordCommand(false)
 		metrics.RecordError(err)
 
-		// Provide more helpful error messages based on error type
-		switch {
-		case errors.IsErrorType(err, errors.ErrorTypeNotFound):
-			if b.hasFilterParameters(cardQuery) {
-				b.sendErrorMessage(s, m.ChannelID, fmt.Sprintf("No cards found for '%s'. Try simpler filters like `e:set` or `is:foil`, or check the spelling.", cardQuery))
-			} else {
-				b.sendErrorMessage(s, m.ChannelID, fmt.Sprintf("Card '%s' not found. Try partial names like 'bolt' for 'Lightning Bolt'.", cardQuery))
-			}
-		case errors.IsErrorType(err, errors.ErrorTypeRateLimit):
-			b.sendErrorMessage(s, m.ChannelID, "API rate limit exceeded. Please try again in a moment.")
-		default:
-			b.sendErrorMessage(s, m.ChannelID, "Sorry, something went wrong while searching for that card.")
-		}
+		b.sendErrorMessage(s, m.ChannelID, b.lookupErrorMessage(err, cardQuery))
 	} else {
 		metrics.RecordCommand(true)
 		logging.LogDiscordCommand(m.Author.ID, m.Author.Username, "card_lookup", true)
 	}
 }
 
+// checkCooldown enforces the guild's command cooldown for a prefix command and
+// tells the user how long to wait. Prefix card lookups share the cooldown of
+// the /card command.
+func (b *Bot) checkCooldown(s *discordgo.Session, m *discordgo.MessageCreate, command string) bool {
+	message, ok := b.guilds.Check(m.GuildID, m.ChannelID, m.Author.ID, command)
+	if !ok {
+		b.sendErrorMessage(s, m.ChannelID, message)
+	}
+	return ok
+}
+
 // handleRandomCard handles the !random command.
 func (b *Bot) handleRandomCard(s *discordgo.Session, m *discordgo.MessageCreate, _ []string) error {
 	logger := logging.WithComponent("discord").With(
@@ -213,7 +276,9 @@ func (b *Bot) handleRandomCard(s *discordgo.Session, m *discordgo.MessageCreate,
 		return errors.NewAPIError("failed to fetch random card", err)
 	}
 
-	return b.sendCardMessage(s, m.ChannelID, card, false, "")
+	b.cardsLookedUp.Add(1)
+
+	return b.sendCardMessage(s, m.ChannelID, m.GuildID, card, false, "")
 }
 
 // handleCardLookup handles card lookup with support for filtering parameters.
@@ -234,7 +299,7 @@ func (b *Bot) handleCardLookup(s *discordgo.Session, m *discordgo.MessageCreate,
 		return err
 	}
 
-	return b.sendCardMessage(s, m.ChannelID, card, usedFallback, cardQuery)
+	return b.sendCardMessage(s, m.ChannelID, m.GuildID, card, usedFallback, cardQuery)
 }
 
 // resolveCardQuery encapsulates the logic to resolve a single card query into a card,
@@ -284,6 +349,8 @@ func (b *Bot) resolveCardQuery(cardQuery string) (*scryfall.Card, bool, error) {
 		return nil, false, errors.NewValidationError("no card found for query")
 	}
 
+	b.cardsLookedUp.Add(1)
+
 	return card, usedFallback, nil
 }
 
@@ -393,7 +460,7 @@ func (b *Bot) sendCardGridMessage(s *discordgo.Session, channelID string, items
 
 	// If no images, send just the embed; otherwise send embed first, then images
 	if len(files) == 0 {
-		_, err := s.ChannelMessageSendEmbed(channelID, embed)
+		_, err := b.outbound.SendEmbed(channelID, embed)
 		if err != nil {
 			return errors.NewDiscordError("failed to send multi-card embed message", err)
 		}
@@ -401,12 +468,12 @@ func (b *Bot) sendCardGridMessage(s *discordgo.Session, channelID string, items
 	}
 
 	// Send the list embed first so it appears above the image grid
-	if _, err := s.ChannelMessageSendEmbed(channelID, embed); err != nil {
+	if _, err := b.outbound.SendEmbed(channelID, embed); err != nil {
 		return errors.NewDiscordError("failed to send multi-card list embed", err)
 	}
 
 	// Then send the image grid as a separate message with only attachments
-	if _, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Files: files}); err != nil {
+	if _, err := b.outbound.Send(channelID, &discordgo.MessageSend{Files: files}); err != nil {
 		return errors.NewDiscordError("failed to send multi-card grid attachments", err)
 	}
 	return nil
@@ -531,9 +598,23 @@ func (b *Bot) extractCardName(query string) string {
 }
 
 // sendCardMessage sends a card image and details to a Discord channel.
-func (b *Bot) sendCardMessage(s *discordgo.Session, channelID string, card *scryfall.Card, usedFallback bool, originalQuery string) error {
+func (b *Bot) sendCardMessage(s *discordgo.Session, channelID, guildID string, card *scryfall.Card, usedFallback bool, originalQuery string) error {
+	embed, err := b.cardEmbed(card, guildID, usedFallback, originalQuery)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.outbound.SendEmbed(channelID, embed); err != nil {
+		return errors.NewDiscordError("failed to send card embed", err)
+	}
+
+	return nil
+}
+
+// cardEmbed builds the embed showing a card's image and details.
+func (b *Bot) cardEmbed(card *scryfall.Card, guildID string, usedFallback bool, originalQuery string) (*discordgo.MessageEmbed, error) {
 	if !card.IsValidCard() {
-		return errors.NewValidationError("received invalid card data from API")
+		return nil, errors.NewValidationError("received invalid card data from API")
 	}
 
 	if !card.HasImage() {
@@ -565,18 +646,13 @@ func (b *Bot) sendCardMessage(s *discordgo.Session, channelID string, card *scry
 			})
 		}
 
-		_, err := s.ChannelMessageSendEmbed(channelID, embed)
-		if err != nil {
-			return errors.NewDiscordError("failed to send text-only card embed", err)
-		}
-
-		return nil
+		return embed, nil
 	}
 
 	// Get the highest quality image URL.
 	imageURL := card.GetBestImageURL()
 	if imageURL == "" {
-		return errors.NewValidationError("no image available for card")
+		return nil, errors.NewValidationError("no image available for card")
 	}
 
 	// Create rich embed with card image.
@@ -603,6 +679,12 @@ func (b *Bot) sendCardMessage(s *discordgo.Session, channelID string, card *scry
 		descriptions = append(descriptions, fmt.Sprintf("**Mana Cost:** %s", card.ManaCost))
 	}
 
+	if b.features.Enabled(priceDisplayFlag, guildID) {
+		if prices := formatPrices(card.Prices); prices != "" {
+			descriptions = append(descriptions, fmt.Sprintf("**Price:** %s", prices))
+		}
+	}
+
 	if len(descriptions) > 0 {
 		embed.Description = strings.Join(descriptions, "\n")
 	}
@@ -612,14 +694,151 @@ func (b *Bot) sendCardMessage(s *discordgo.Session, channelID string, card *scry
 		embed.Footer.Text += fmt.Sprintf(" • Art by %s", card.Artist)
 	}
 
-	_, err := s.ChannelMessageSendEmbed(channelID, embed)
+	return embed, nil
+}
+
+// presenceValues adds the MTG template values to the status rotation. The bot
+// keeps its own metrics, so Commands is replaced with its command count.
+func (b *Bot) presenceValues(values presence.Values) {
+	values["CardsLookedUp"] = b.cardsLookedUp.Load()
+	values["CachedCards"] = b.cache.Stats().Size
+	values["Commands"] = metrics.Get().GetSummary().CommandsTotal
+}
+
+// formatPrices renders the available prices of a card, or "" if none are known.
+func formatPrices(prices scryfall.Prices) string {
+	var parts []string
+
+	if prices.USD != nil {
+		parts = append(parts, "$"+*prices.USD)
+	}
+	if prices.USDFoil != nil {
+		parts = append(parts, "$"+*prices.USDFoil+" foil")
+	}
+	if prices.EUR != nil {
+		parts = append(parts, "€"+*prices.EUR)
+	}
+
+	return strings.Join(parts, " • ")
+}
+
+// handleFeatures handles the !features command for guild administrators.
+func (b *Bot) handleFeatures(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	if m.GuildID == "" {
+		b.sendErrorMessage(s, m.ChannelID, "Feature flags can only be managed in a server.")
+		return nil
+	}
+
+	permissions, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return errors.NewDiscordError("failed to check member permissions", err)
+	}
+	if permissions&discordgo.PermissionManageServer == 0 {
+		b.sendErrorMessage(s, m.ChannelID, "You need the Manage Server permission to change features.")
+		return nil
+	}
+
+	reply, err := b.features.Execute(m.GuildID, args)
 	if err != nil {
-		return errors.NewDiscordError("failed to send card embed with image", err)
+		return err
+	}
+
+	if _, err := b.outbound.SendText(m.ChannelID, reply); err != nil {
+		return errors.NewDiscordError("failed to send features message", err)
 	}
 
 	return nil
 }
 
+// handleAudit handles the !audit command for guild administrators.
+// Usage: !audit [@user] [command] [hours]
+func (b *Bot) handleAudit(s *discordgo.Session, m *discordgo.MessageCreate, args []string) error {
+	if m.GuildID == "" {
+		b.sendErrorMessage(s, m.ChannelID, "The audit log can only be viewed in a server.")
+		return nil
+	}
+
+	permissions, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return errors.NewDiscordError("failed to check member permissions", err)
+	}
+	if permissions&discordgo.PermissionManageServer == 0 {
+		b.sendErrorMessage(s, m.ChannelID, "You need the Manage Server permission to view the audit log.")
+		return nil
+	}
+
+	filter := audit.Filter{
+		GuildID: m.GuildID,
+		Since:   time.Now().Add(-24 * time.Hour),
+		Limit:   20,
+	}
+
+	for _, arg := range args {
+		var hours int
+		switch {
+		case len(m.Mentions) > 0 && strings.HasPrefix(arg, "<@"):
+			filter.UserID = m.Mentions[0].ID
+		case parseHours(arg, &hours):
+			filter.Since = time.Now().Add(-time.Duration(hours) * time.Hour)
+		default:
+			filter.Command = strings.ToLower(arg)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entries, err := b.audit.Store().Query(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, audit.FormatLine(entry, true))
+	}
+	description := strings.Join(lines, "\n")
+	if description == "" {
+		description = "No matching commands."
+	}
+	if len(description) > 4000 {
+		description = description[:4000]
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "📋 Command Audit Log",
+		Description: description,
+		Color:       0x3498DB,
+	}
+
+	if _, err := b.outbound.SendEmbed(m.ChannelID, embed); err != nil {
+		return errors.NewDiscordError("failed to send audit log", err)
+	}
+
+	return nil
+}
+
+// parseHours parses an hour count such as "12" or "12h".
+func parseHours(arg string, hours *int) bool {
+	n, err := fmt.Sscanf(strings.TrimSuffix(arg, "h"), "%d", hours)
+	return err == nil && n == 1 && *hours > 0
+}
+
+// lookupErrorMessage returns a helpful message for a failed card lookup.
+func (b *Bot) lookupErrorMessage(err error, cardQuery string) string {
+	switch {
+	case errors.IsErrorType(err, errors.ErrorTypeNotFound):
+		if b.hasFilterParameters(cardQuery) {
+			return fmt.Sprintf("No cards found for '%s'. Try simpler filters like `e:set` or `is:foil`, or check the spelling.", cardQuery)
+		}
+		return fmt.Sprintf("Card '%s' not found. Try partial names like 'bolt' for 'Lightning Bolt'.", cardQuery)
+	case errors.IsErrorType(err, errors.ErrorTypeRateLimit):
+		return "API rate limit exceeded. Please try again in a moment."
+	default:
+		return "Sorry, something went wrong while searching for that card."
+	}
+}
+
 // sendErrorMessage sends an error message to a Discord channel.
 func (b *Bot) sendErrorMessage(s *discordgo.Session, channelID, message string) {
 	embed := &discordgo.MessageEmbed{
@@ -628,7 +847,7 @@ func (b *Bot) sendErrorMessage(s *discordgo.Session, channelID, message string)
 		Color:       0xE74C3C, // Red color.
 	}
 
-	if _, err := s.ChannelMessageSendEmbed(channelID, embed); err != nil {
+	if _, err := b.outbound.SendEmbed(channelID, embed); err != nil {
 		logger := logging.WithComponent("discord")
 		logger.Error("Failed to send error message", "error", err)
 	}
@@ -670,8 +889,8 @@ func (b *Bot) handleHelp(s *discordgo.Session, m *discordgo.MessageCreate, _ []s
 		Fields: []*discordgo.MessageEmbedField{
 			{
 				Name: "Commands",
-				Value: fmt.Sprintf("`%s<card>` – Look up a card\n`%s<card1>; <card2>; ...` – Grid lookup (up to 10)\n`%srandom` – Random card\n`%sstats` – Bot statistics\n`%scache` – Cache stats\n`%shelp` – This menu",
-					b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix),
+				Value: fmt.Sprintf("`%s<card>` – Look up a card\n`/card <query>` – Look up a card anywhere the app is installed\n`%s<card1>; <card2>; ...` – Grid lookup (up to 10)\n`%srandom` – Random card\n`%sstats` – Bot statistics\n`%scache` – Cache stats\n`%sfeatures` – Server features (admins)\n`%saudit [@user] [command] [hours]` – Command audit log (admins)\n`%shelp` – This menu",
+					b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix, b.config.CommandPrefix),
 				Inline: false,
 			},
 			{
@@ -707,7 +926,7 @@ func (b *Bot) handleHelp(s *discordgo.Session, m *discordgo.MessageCreate, _ []s
 		},
 	}
 
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err := b.outbound.SendEmbed(m.ChannelID, embed)
 	if err != nil {
 		return errors.NewDiscordError("failed to send help message", err)
 	}
@@ -783,7 +1002,7 @@ func (b *Bot) handleStats(s *discordgo.Session, m *discordgo.MessageCreate, _ []
 		}
 	}
 
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err := b.outbound.SendEmbed(m.ChannelID, embed)
 	if err != nil {
 		return errors.NewDiscordError("failed to send stats message", err)
 	}
@@ -831,7 +1050,7 @@ func (b *Bot) handleCacheStats(s *discordgo.Session, m *discordgo.MessageCreate,
 		},
 	}
 
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err := b.outbound.SendEmbed(m.ChannelID, embed)
 	if err != nil {
 		return errors.NewDiscordError("failed to send cache stats message", err)
 	}
@@ -0,0 +1,166 @@
+package discord
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/logging"
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/metrics"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/features"
+)
+
+// cardCommandName is the slash command equivalent of a prefix card lookup.
+const cardCommandName = "card"
+
+// Commands returns the application commands offered next to the prefix
+// commands. They only reply through the interaction, so they work in DMs,
+// group DMs and servers where a user rather than the server installed the app.
+func Commands() []*discordgo.ApplicationCommand {
+	return []*discordgo.ApplicationCommand{
+		shareddiscord.Everywhere(&discordgo.ApplicationCommand{
+			Name:        cardCommandName,
+			Description: "Look up a Magic: The Gathering card",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "query",
+					Description: "Card name, optionally with filters like e:lea or is:foil",
+					Required:    true,
+				},
+			},
+		}),
+	}
+}
+
+// ready registers the slash commands once the session is ready.
+func (b *Bot) ready(s *discordgo.Session, _ *discordgo.Ready) {
+	logger := logging.WithComponent("discord")
+
+	// User installs only see global commands, so these are never guild-specific.
+	for _, command := range Commands() {
+		for _, guildID := range b.features.CommandGuilds(command.Name, "") {
+			cmd, err := s.ApplicationCommandCreate(s.State.User.ID, guildID, command)
+			if err != nil {
+				logging.LogError(logger, errors.NewDiscordError(fmt.Sprintf("failed to register command %s", command.Name), err), "Failed to register slash command")
+				continue
+			}
+
+			b.registeredCmds = append(b.registeredCmds, cmd)
+			logger.Info("Registered slash command", "command", command.Name, "guild_specific", guildID != "")
+		}
+	}
+}
+
+// removeSlashCommands deletes the commands registered in ready.
+func (b *Bot) removeSlashCommands() {
+	logger := logging.WithComponent("discord")
+
+	for _, cmd := range b.registeredCmds {
+		if err := b.session.ApplicationCommandDelete(b.session.State.User.ID, cmd.GuildID, cmd.ID); err != nil {
+			logger.Error("Failed to delete command", "command", cmd.Name, "error", err)
+		}
+	}
+	b.registeredCmds = nil
+}
+
+// interactionCreate handles slash commands.
+func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name != cardCommandName {
+		return
+	}
+
+	start := time.Now()
+	userID := shareddiscord.InteractionUserID(i.Interaction)
+	username := shareddiscord.InteractionUsername(i.Interaction)
+
+	if !b.features.Enabled(features.CommandFlag(cardCommandName), i.GuildID) {
+		if err := features.RespondDisabled(s, i); err != nil {
+			logger := logging.WithComponent("discord")
+			logger.Error("Failed to send disabled command response", "error", err)
+		}
+		b.audit.RecordResult(audit.FromInteraction(i), start, errors.NewPermissionError("command disabled in this guild", nil))
+		return
+	}
+
+	if ok, err := b.guilds.CheckInteraction(s, i); !ok {
+		if err != nil {
+			logger := logging.WithComponent("discord")
+			logger.Error("Failed to send command rejection", "error", err)
+		}
+		b.audit.RecordResult(audit.FromInteraction(i), start, errors.NewPermissionError("command not allowed by guild settings", nil))
+		return
+	}
+
+	err := b.handleCardSlashCommand(s, i)
+	b.audit.RecordResult(audit.FromInteraction(i), start, err)
+	if err != nil {
+		logger := logging.WithComponent("discord").With(
+			"user_id", userID,
+			"username", username,
+			"command", cardCommandName,
+		)
+		logging.LogError(logger, err, "Slash command failed")
+		metrics.RecordCommand(false)
+		metrics.RecordError(err)
+	} else {
+		metrics.RecordCommand(true)
+		logging.LogDiscordCommand(userID, username, cardCommandName, true)
+	}
+}
+
+// handleCardSlashCommand handles the /card command.
+func (b *Bot) handleCardSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var cardQuery string
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "query" {
+			cardQuery = option.StringValue()
+		}
+	}
+
+	// Scryfall lookups can take longer than Discord's three second reply window.
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	}); err != nil {
+		return errors.NewDiscordError("failed to defer card lookup response", err)
+	}
+
+	card, usedFallback, err := b.resolveCardQuery(cardQuery)
+	if err != nil {
+		b.editErrorResponse(s, i, b.lookupErrorMessage(err, cardQuery))
+		return err
+	}
+
+	embed, err := b.cardEmbed(card, i.GuildID, usedFallback, cardQuery)
+	if err != nil {
+		b.editErrorResponse(s, i, "Sorry, something went wrong while searching for that card.")
+		return err
+	}
+
+	if _, err := b.outbound.EditInteraction(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	}); err != nil {
+		return errors.NewDiscordError("failed to send card embed", err)
+	}
+
+	return nil
+}
+
+// editErrorResponse replaces a deferred interaction response with an error.
+func (b *Bot) editErrorResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	embed := &discordgo.MessageEmbed{
+		Title:       "Error",
+		Description: message,
+		Color:       0xE74C3C, // Red color.
+	}
+
+	if _, err := b.outbound.EditInteraction(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	}); err != nil {
+		logger := logging.WithComponent("discord")
+		logger.Error("Failed to send error message", "error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"context"
+
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/cache"
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/config"
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/logging"
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/metrics"
+	"github.com/sawyer/go-discord-bots/apps/mtg-card-bot/scryfall"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	sharedconfig "github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/features"
+	"github.com/sawyer/go-discord-bots/pkg/guilds"
+	sharedlogging "github.com/sawyer/go-discord-bots/pkg/logging"
+	sharedmetrics "github.com/sawyer/go-discord-bots/pkg/metrics"
+	"github.com/sawyer/go-discord-bots/pkg/outbound"
+	"github.com/sawyer/go-discord-bots/pkg/presence"
+)
+
+// Run runs the MTG Card Bot until ctx is cancelled, applying changes to
+// shared.ConfigFile while it runs. The shared logger must be initialized; the
+// bot logs through it and never replaces it. Its metrics are initialized here.
+func Run(ctx context.Context, shared *sharedconfig.Config) error {
+	if err := shared.Validate(); err != nil {
+		return err
+	}
+
+	cfg := config.FromShared(shared)
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	// The card lookup code logs and counts through the bot's own packages
+	logging.UseLogger(sharedlogging.ForBot(string(shared.BotType)))
+	metrics.Initialize()
+	sharedmetrics.Initialize(shared.BotName, string(shared.BotType))
+
+	// Initialize Scryfall client
+	scryfallClient := scryfall.NewClient()
+	defer scryfallClient.Close()
+
+	// Initialize cache
+	cardCache := cache.NewCardCache(cfg.CacheTTL, cfg.CacheSize)
+
+	// Initialize feature flags
+	flags, err := features.NewManager(shared)
+	if err != nil {
+		return err
+	}
+
+	// Resolve per-guild overrides
+	guildSettings := guilds.NewSettings(shared)
+
+	// Initialize command audit log
+	recorder, err := audit.OpenRecorder(shared)
+	if err != nil {
+		return err
+	}
+	recorder.Start()
+	defer recorder.Stop()
+
+	// Initialize the rotating status
+	activity, err := presence.NewManager(shared)
+	if err != nil {
+		return err
+	}
+
+	// Queue outgoing messages so rate limits are retried instead of dropped
+	outbox := outbound.NewDispatcher(shared)
+
+	bot, err := NewBot(cfg, scryfallClient, cardCache, flags, guildSettings, recorder, activity, outbox)
+	if err != nil {
+		return err
+	}
+
+	// Watch for configuration changes
+	watcher := newConfigWatcher(shared, cardCache, flags, guildSettings, activity)
+	return shareddiscord.RunWithWatcher(ctx, bot, watcher, cfg.ShutdownTimeout)
+}
+
+// newConfigWatcher creates a watcher that applies cache TTL,
+// feature flag, command cooldown and guild override changes at runtime.
+func newConfigWatcher(current *sharedconfig.Config, cardCache *cache.CardCache, flags *features.Manager, guildSettings *guilds.Settings, activity *presence.Manager) *sharedconfig.Watcher {
+	watcher := sharedconfig.NewWatcher(current.ConfigFile, sharedconfig.BotTypeMTG, current)
+	watcher.Subscribe(func(change *sharedconfig.Change) {
+		guildSettings.Update(change.New)
+		if change.Has("cache_ttl") {
+			cardCache.SetTTL(change.New.CacheTTL.Std())
+		}
+		if change.Has("features") {
+			flags.Update(change.New.Features)
+		}
+		if change.Has("presence") || change.Has("presence_interval") {
+			if err := activity.Update(change.New); err != nil {
+				logger := logging.WithComponent("main")
+				logging.LogError(logger, err, "Failed to apply presence configuration")
+			}
+		}
+	})
+
+	return watcher
+}
@@ -5,14 +5,13 @@ import (
 	"context"
 	"errors"
 	"log/slog"
-	"os"
-	"strings"
 
 	mtgErrors "github.com/sawyer/go-discord-bots/pkg/errors"
 )
 
-// DefaultLogger is the global logger instance.
-var DefaultLogger *slog.Logger
+// DefaultLogger is the logger of the MTG Card Bot. It logs through the
+// process logger until UseLogger replaces it.
+var DefaultLogger = slog.Default()
 
 // LogLevel represents the logging level.
 type LogLevel string
@@ -28,43 +27,11 @@ const (
 	LevelError LogLevel = "error"
 )
 
-// InitializeLogger initializes the global logger with the specified level and format.
-func InitializeLogger(level string, jsonFormat bool) {
-	var slogLevel slog.Level
-
-	switch strings.ToLower(level) {
-	case "debug":
-		slogLevel = slog.LevelDebug
-	case "info":
-		slogLevel = slog.LevelInfo
-	case "warn", "warning":
-		slogLevel = slog.LevelWarn
-	case "error":
-		slogLevel = slog.LevelError
-	default:
-		slogLevel = slog.LevelInfo
-	}
-
-	opts := &slog.HandlerOptions{
-		Level: slogLevel,
-		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
-			// Customize timestamp format.
-			if a.Key == slog.TimeKey {
-				a.Value = slog.StringValue(a.Value.Time().Format("2006-01-02T15:04:05.000Z07:00"))
-			}
-			return a
-		},
-	}
-
-	var handler slog.Handler
-	if jsonFormat {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
-	}
-
-	DefaultLogger = slog.New(handler)
-	slog.SetDefault(DefaultLogger)
+// UseLogger makes the bot log through logger. The bot never replaces the
+// process-wide default logger: whoever runs it, the standalone binary or the
+// launcher, owns the default logger, its output and its level.
+func UseLogger(logger *slog.Logger) {
+	DefaultLogger = logger
 }
 
 // WithContext returns a logger with context values.
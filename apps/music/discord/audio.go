@@ -1,5 +1,5 @@
-// Package main provides audio streaming functionality.
-package main
+// Package discord provides audio streaming functionality.
+package discord
 
 import (
 	"bufio"
@@ -12,7 +12,6 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/sawyer/go-discord-bots/pkg/errors"
-	"github.com/sawyer/go-discord-bots/pkg/logging"
 	"layeh.com/gopus"
 )
 
@@ -47,7 +46,7 @@ func (as *AudioStream) Start(ctx context.Context) error {
 	as.mutex.Lock()
 	defer as.mutex.Unlock()
 
-	logger := logging.WithComponent("audio-stream")
+	logger := withComponent("audio-stream")
 	logger.Info("Starting audio stream", "guild", as.guildID, "song", as.song.Title)
 
 	// Create cancellable context
@@ -134,7 +133,7 @@ func (as *AudioStream) Start(ctx context.Context) error {
 func (as *AudioStream) streamAudio(source io.Reader) {
 	defer close(as.done)
 
-	logger := logging.WithComponent("audio-stream")
+	logger := withComponent("audio-stream")
 
 	// Create Opus encoder for Discord voice (48kHz, stereo, audio application)
 	opusEncoder, err := gopus.NewEncoder(48000, 2, gopus.Audio)
@@ -349,7 +348,7 @@ func (as *AudioStream) Stop() {
 	as.mutex.Lock()
 	defer as.mutex.Unlock()
 
-	logger := logging.WithComponent("audio-stream")
+	logger := withComponent("audio-stream")
 	logger.Info("Stopping audio stream", "guild", as.guildID)
 
 	// Cancel context
@@ -418,12 +417,34 @@ func NewEnhancedAudioPlayer() *EnhancedAudioPlayer {
 	}
 }
 
-// PlaySong starts playing a song in the specified guild.
+// PlaySong plays a song in the specified guild. It returns when the song
+// finished or was stopped.
 func (eap *EnhancedAudioPlayer) PlaySong(ctx context.Context, guildID string, song *Song, conn *discordgo.VoiceConnection) error {
+	stream, err := eap.startStream(ctx, guildID, song, conn)
+	if err != nil {
+		return err
+	}
+
+	logger := withComponent("enhanced-audio-player")
+	logger.Info("Started playing song", "guild", guildID, "song", song.Title)
+
+	// Wait for the stream to finish
+	stream.Wait()
+
 	eap.mutex.Lock()
-	defer eap.mutex.Unlock()
+	if eap.streams[guildID] == stream {
+		delete(eap.streams, guildID)
+	}
+	eap.mutex.Unlock()
+
+	logger.Info("Song finished", "guild", guildID, "song", song.Title)
+	return nil
+}
 
-	logger := logging.WithComponent("enhanced-audio-player")
+// startStream replaces the audio stream of a guild with a new stream of song.
+func (eap *EnhancedAudioPlayer) startStream(ctx context.Context, guildID string, song *Song, conn *discordgo.VoiceConnection) (*AudioStream, error) {
+	eap.mutex.Lock()
+	defer eap.mutex.Unlock()
 
 	// Stop any existing stream for this guild
 	if existingStream, exists := eap.streams[guildID]; exists {
@@ -441,21 +462,10 @@ func (eap *EnhancedAudioPlayer) PlaySong(ctx context.Context, guildID string, so
 	// Start streaming
 	if err := stream.Start(ctx); err != nil {
 		delete(eap.streams, guildID)
-		return err
+		return nil, err
 	}
 
-	logger.Info("Started playing song", "guild", guildID, "song", song.Title)
-
-	// Wait for stream to finish in a goroutine
-	go func() {
-		stream.Wait()
-		eap.mutex.Lock()
-		delete(eap.streams, guildID)
-		eap.mutex.Unlock()
-		logger.Info("Song finished", "guild", guildID, "song", song.Title)
-	}()
-
-	return nil
+	return stream, nil
 }
 
 // PauseStream pauses the audio stream for a guild.
@@ -518,6 +528,28 @@ func (eap *EnhancedAudioPlayer) SetStreamVolume(guildID string, volume float64)
 	}
 }
 
+// ApplyDefaultVolume sets the active streams of guilds without a volume of
+// their own (see SetVolume) to the guild's default volume, e.g. after
+// volume_level changed.
+func (eap *EnhancedAudioPlayer) ApplyDefaultVolume() {
+	eap.AudioPlayer.mutex.RLock()
+	explicit := make(map[string]bool, len(eap.volumes))
+	for guildID := range eap.volumes {
+		explicit[guildID] = true
+	}
+	defaultVolume := eap.defaultVolume
+	eap.AudioPlayer.mutex.RUnlock()
+
+	eap.mutex.RLock()
+	defer eap.mutex.RUnlock()
+
+	for guildID, stream := range eap.streams {
+		if !explicit[guildID] {
+			stream.SetVolume(defaultVolume(guildID))
+		}
+	}
+}
+
 // Cleanup cleans up all streams and connections.
 func (eap *EnhancedAudioPlayer) Cleanup() {
 	eap.mutex.Lock()
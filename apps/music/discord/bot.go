@@ -1,29 +1,46 @@
-// Package main provides the Music Discord bot implementation.
-package main
+// Package discord provides the Music Discord bot implementation.
+package discord
 
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/apps/music/playlistfile"
+	"github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/internal/storage"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
 	"github.com/sawyer/go-discord-bots/pkg/config"
-	"github.com/sawyer/go-discord-bots/pkg/discord"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
 	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/features"
 	"github.com/sawyer/go-discord-bots/pkg/logging"
 	"github.com/sawyer/go-discord-bots/pkg/metrics"
 )
 
 // Bot represents the Music Discord bot.
 type Bot struct {
-	*discord.BaseBot
-	database        *Database
+	*shareddiscord.BaseBot
+	store           *storage.Store
+	playlists       storage.Playlists
+	features        *features.Manager
+	audit           *audit.Recorder
 	audioPlayer     *AudioPlayer
 	queueManager    *QueueManager
 	audioExtractor  *AudioExtractor
 	commandHandlers map[string]SlashCommandHandler
+	imports         *playlistImports
+	history         *playHistory
+
+	songsPlayed       playCounter
+	nowPlayingEnabled atomic.Bool
 }
 
+// playlistsFlag controls the playlist commands per guild.
+const playlistsFlag = "music.playlists"
+
 // SlashCommandHandler represents a function that handles slash commands.
 type SlashCommandHandler func(s *discordgo.Session, i *discordgo.InteractionCreate) error
 
@@ -33,26 +50,57 @@ func NewBot(cfg *config.Config) (*Bot, error) {
 	cfg.CommandPrefix = "/music_disabled" // Use a prefix that won't conflict
 
 	// Create normal BaseBot - we'll override the message handler behavior
-	baseBot, err := discord.NewBaseBot(cfg)
+	baseBot, err := shareddiscord.NewBaseBot(cfg)
 	if err != nil {
 		return nil, errors.NewConfigError("failed to create base bot", err)
 	}
 
+	flags, err := features.NewManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+	flags.Define(playlistsFlag, true, "Playlist commands")
+
+	recorder, err := audit.OpenRecorder(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	bot := &Bot{
 		BaseBot:         baseBot,
+		features:        flags,
+		audit:           recorder,
 		queueManager:    NewQueueManager(),
 		audioPlayer:     NewAudioPlayer(),
 		audioExtractor:  NewAudioExtractor(),
 		commandHandlers: make(map[string]SlashCommandHandler),
+		imports:         newPlaylistImports(),
 	}
+	bot.audioPlayer.SetDefaultVolume(func(guildID string) float64 {
+		return bot.Guilds().ConfigFor(guildID).VolumeLevel
+	})
+	bot.audioPlayer.OnSongStart(func(guildID string, song *Song) {
+		bot.songsPlayed.Add()
+		bot.history.start(guildID, song)
+	})
+	bot.audioPlayer.OnSongEnd(func(guildID string, song *Song, skipped bool) {
+		bot.history.songEnded(guildID, song, skipped)
+	})
+
+	// Show live music values and the current track in the status
+	bot.nowPlayingEnabled.Store(cfg.PresenceNowPlaying)
+	bot.Presence().AddValues(bot.presenceValues)
+	bot.Presence().SetOverride(bot.nowPlaying)
 
-	// Initialize database if URL is provided
-	if cfg.DatabaseURL != "" {
-		database, err := NewDatabase(cfg.DatabaseURL)
+	// Open the playlist storage if it is configured
+	if hasStorage(cfg) {
+		store, err := storage.Open(cfg)
 		if err != nil {
 			return nil, errors.NewDatabaseError("failed to initialize database", err)
 		}
-		bot.database = database
+		bot.store = store
+		bot.playlists = store.Playlists()
+		bot.history = newPlayHistory(store.History(), bot.requestContext)
 	}
 
 	// Register both slash commands and event handlers
@@ -78,12 +126,15 @@ func (b *Bot) Start() error {
 		return err
 	}
 
+	// Start recording command usage
+	b.audit.Start()
+
 	// Register slash commands with Discord
 	if err := b.registerSlashCommandsWithDiscord(); err != nil {
 		return err
 	}
 
-	logger := logging.WithComponent("music-bot")
+	logger := withComponent("music-bot")
 	logger.Info("Music bot started successfully")
 
 	return nil
@@ -91,29 +142,64 @@ func (b *Bot) Start() error {
 
 // Stop stops the Music bot.
 func (b *Bot) Stop() error {
-	logger := logging.WithComponent("music-bot")
+	logger := withComponent("music-bot")
 	logger.Info("Stopping Music bot")
 
+	// Clear the queues first, so no next song starts when playback stops
+	if b.queueManager != nil {
+		b.queueManager.Cleanup()
+	}
+
 	// Clean up audio connections
 	if b.audioPlayer != nil {
 		b.audioPlayer.Cleanup()
 	}
 
-	// Clean up queues
-	if b.queueManager != nil {
-		b.queueManager.Cleanup()
-	}
+	// Record the end of the songs still playing while the database is open
+	b.history.stop()
+
+	// Stop playlist imports before closing the database they write to
+	b.imports.stop()
 
 	// Close database
-	if b.database != nil {
-		if err := b.database.Close(); err != nil {
+	if b.store != nil {
+		if err := b.store.Close(); err != nil {
 			logger.Error("Error closing database", "error", err)
 		}
 	}
 
+	// Flush pending audit entries
+	b.audit.Stop()
+
 	return b.BaseBot.Stop()
 }
 
+// ApplyConfig applies hot-reloadable settings from a configuration change.
+func (b *Bot) ApplyConfig(change *config.Change) {
+	b.Guilds().Update(change.New)
+
+	if change.Has("features") {
+		b.features.Update(change.New.Features)
+	}
+
+	if change.Has("presence") || change.Has("presence_interval") {
+		if err := b.Presence().Update(change.New); err != nil {
+			logger := withComponent("music-bot")
+			logging.LogError(logger, err, "Failed to apply presence configuration")
+		}
+	}
+	b.nowPlayingEnabled.Store(change.New.PresenceNowPlaying)
+
+	// New songs pick up the guild's volume when they start; the ones playing
+	// follow now, unless the guild set its own volume with /volume
+	if change.Has("volume_level") || change.Has("guilds") {
+		b.audioPlayer.enhanced.ApplyDefaultVolume()
+
+		logger := withComponent("music-bot")
+		logger.Info("Applied configuration change", "volume_level", change.New.VolumeLevel, "guild_overrides", len(change.New.Guilds))
+	}
+}
+
 // registerSlashCommands registers all slash command handlers.
 func (b *Bot) registerSlashCommands() {
 	// Basic playback commands
@@ -124,21 +210,41 @@ func (b *Bot) registerSlashCommands() {
 	b.commandHandlers["stop"] = b.handleStopSlashCommand
 	b.commandHandlers["queue"] = b.handleQueueSlashCommand
 	b.commandHandlers["volume"] = b.handleVolumeSlashCommand
+	b.commandHandlers["music_features"] = b.features.HandleAdminCommand
+	b.commandHandlers["audit"] = b.audit.HandleAdminCommand
+
+	// Listening statistics (only if storage is available)
+	if b.store != nil {
+		b.commandHandlers["music_stats"] = b.handleMusicStatsSlashCommand
+	}
 
 	// Playlist commands (only if database is available)
-	if b.database != nil {
+	if b.playlists != nil {
 		b.commandHandlers["playlist_create"] = b.handlePlaylistCreateSlashCommand
 		b.commandHandlers["playlist_list"] = b.handlePlaylistListSlashCommand
 		b.commandHandlers["playlist_show"] = b.handlePlaylistShowSlashCommand
 		b.commandHandlers["playlist_play"] = b.handlePlaylistPlaySlashCommand
 		b.commandHandlers["playlist_add"] = b.handlePlaylistAddSlashCommand
 		b.commandHandlers["playlist_remove"] = b.handlePlaylistRemoveSlashCommand
+		b.commandHandlers["playlist_move"] = b.handlePlaylistMoveSlashCommand
 		b.commandHandlers["playlist_delete"] = b.handlePlaylistDeleteSlashCommand
+		b.commandHandlers["playlist_share"] = b.handlePlaylistShareSlashCommand
+		b.commandHandlers["playlist_collab"] = b.handlePlaylistCollabSlashCommand
+		b.commandHandlers["playlist_clone"] = b.handlePlaylistCloneSlashCommand
+		b.commandHandlers["playlist_import"] = b.handlePlaylistImportSlashCommand
+		b.commandHandlers["playlist_export"] = b.handlePlaylistExportSlashCommand
 	}
 }
 
-// registerSlashCommandsWithDiscord registers slash commands with Discord API.
-func (b *Bot) registerSlashCommandsWithDiscord() error {
+// hasStorage reports whether playlists can be stored: in the database of
+// database_url or, with the memory storage backend, in memory.
+func hasStorage(cfg *config.Config) bool {
+	return cfg.DatabaseURL != "" || cfg.Storage == config.StorageMemory
+}
+
+// Commands returns the application commands the music bot registers with cfg.
+// Playlist commands are only offered when playlist storage is configured.
+func Commands(cfg *config.Config) []*discordgo.ApplicationCommand {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "play",
@@ -186,10 +292,30 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 				},
 			},
 		},
+		features.AdminCommand("music_features"),
+		audit.AdminCommand("audit"),
 	}
 
-	// Add playlist commands if database is available
-	if b.database != nil {
+	// Add listening statistics and playlist commands if database is available
+	if hasStorage(cfg) {
+		commands = append(commands, &discordgo.ApplicationCommand{
+			Name:        "music_stats",
+			Description: "Show top tracks, top requesters, hours listened and your recent tracks",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "period",
+					Description: "Period to count plays in (default: past 7 days)",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Past 7 days", Value: "week"},
+						{Name: "Past 30 days", Value: "month"},
+						{Name: "All time", Value: "all"},
+					},
+				},
+			},
+		})
+
 		playlistCommands := []*discordgo.ApplicationCommand{
 			{
 				Name:        "playlist_create",
@@ -201,11 +327,17 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 						Description: "Playlist name",
 						Required:    true,
 					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "server",
+						Description: "Create a server playlist managed by DJs",
+						Required:    false,
+					},
 				},
 			},
 			{
 				Name:        "playlist_list",
-				Description: "List your playlists",
+				Description: "List your playlists, shared playlists and server playlists",
 			},
 			{
 				Name:        "playlist_show",
@@ -233,7 +365,7 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 			},
 			{
 				Name:        "playlist_add",
-				Description: "Add current song to playlist",
+				Description: "Add the current song, or a song you search for, to a playlist",
 				Options: []*discordgo.ApplicationCommandOption{
 					{
 						Type:        discordgo.ApplicationCommandOptionInteger,
@@ -241,6 +373,12 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 						Description: "Playlist ID",
 						Required:    true,
 					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "query",
+						Description: "YouTube URL or search query (default: the current song)",
+						Required:    false,
+					},
 				},
 			},
 			{
@@ -261,6 +399,30 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 					},
 				},
 			},
+			{
+				Name:        "playlist_move",
+				Description: "Move a song to another position in a playlist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "playlist_id",
+						Description: "Playlist ID",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "song_number",
+						Description: "Song position in playlist",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "position",
+						Description: "New position of the song",
+						Required:    true,
+					},
+				},
+			},
 			{
 				Name:        "playlist_delete",
 				Description: "Delete a playlist",
@@ -273,13 +435,135 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 					},
 				},
 			},
+			{
+				Name:        "playlist_share",
+				Description: "Choose who can see and play a playlist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "playlist_id",
+						Description: "Playlist ID",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "visibility",
+						Description: "Who can see the playlist",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Private (you and collaborators)", Value: string(database.VisibilityPrivate)},
+							{Name: "Server (everyone in this server)", Value: string(database.VisibilityGuild)},
+							{Name: "Public (everyone, in any server)", Value: string(database.VisibilityPublic)},
+						},
+					},
+				},
+			},
+			{
+				Name:        "playlist_collab",
+				Description: "Add or remove a collaborator who can edit a playlist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "playlist_id",
+						Description: "Playlist ID",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionUser,
+						Name:        "user",
+						Description: "The collaborator",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "action",
+						Description: "Add or remove the user (default: add)",
+						Required:    false,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "Add", Value: "add"},
+							{Name: "Remove", Value: "remove"},
+						},
+					},
+				},
+			},
+			{
+				Name:        "playlist_clone",
+				Description: "Copy a playlist you can see into a playlist of your own",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "playlist_id",
+						Description: "Playlist ID",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Name of the copy (default: the original name)",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Name:        "playlist_import",
+				Description: "Import an M3U, XSPF or JSON playlist file into a new playlist",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+						Name:        "file",
+						Description: "Playlist file (.m3u, .m3u8, .xspf or .json)",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "name",
+						Description: "Playlist name (default: the name in the file)",
+						Required:    false,
+					},
+				},
+			},
+			{
+				Name:        "playlist_export",
+				Description: "Download a playlist as an M3U, XSPF or JSON file",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "playlist_id",
+						Description: "Playlist ID",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "format",
+						Description: "File format (default: JSON)",
+						Required:    false,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "M3U8", Value: string(playlistfile.M3U)},
+							{Name: "XSPF", Value: string(playlistfile.XSPF)},
+							{Name: "JSON (keeps everything)", Value: string(playlistfile.JSON)},
+						},
+					},
+				},
+			},
 		}
 		commands = append(commands, playlistCommands...)
 	}
 
-	logger := logging.WithComponent("music-bot")
+	// Music needs a voice channel, so no command is offered in DMs or user installs
+	for _, command := range commands {
+		shareddiscord.GuildOnly(command)
+	}
+
+	return commands
+}
+
+// registerSlashCommandsWithDiscord registers slash commands with Discord API.
+func (b *Bot) registerSlashCommandsWithDiscord() error {
+	commands := Commands(b.GetConfig())
+
+	logger := withComponent("music-bot")
 	var guildID string
-	if b.isValidGuildID(b.GetConfig().GuildID) {
+	if shareddiscord.IsValidGuildID(b.GetConfig().GuildID) {
 		guildID = b.GetConfig().GuildID
 		logger.Info("Registering guild-specific commands", "guild_id", guildID)
 	} else {
@@ -292,47 +576,28 @@ func (b *Bot) registerSlashCommandsWithDiscord() error {
 	}
 
 	for _, command := range commands {
-		_, err := b.GetSession().ApplicationCommandCreate(b.GetSession().State.User.ID, guildID, command)
-		if err != nil {
-			return errors.NewDiscordError(fmt.Sprintf("failed to register slash command %s", command.Name), err)
+		for _, commandGuildID := range b.features.CommandGuilds(command.Name, guildID) {
+			_, err := b.GetSession().ApplicationCommandCreate(b.GetSession().State.User.ID, commandGuildID, command)
+			if err != nil {
+				return errors.NewDiscordError(fmt.Sprintf("failed to register slash command %s", command.Name), err)
+			}
+			logger.Info("Registered slash command", "command", command.Name, "guild_specific", commandGuildID != "")
 		}
-		logger.Info("Registered slash command", "command", command.Name)
 	}
 
 	return nil
 }
 
-// isValidGuildID checks if the guild ID is a valid Discord snowflake.
-func (b *Bot) isValidGuildID(guildID string) bool {
-	if guildID == "" {
-		return false
-	}
-
-	// Discord snowflakes are 17-19 digit numbers
-	if len(guildID) < 17 || len(guildID) > 19 {
-		return false
-	}
-
-	// Check if all characters are digits
-	for _, char := range guildID {
-		if char < '0' || char > '9' {
-			return false
-		}
-	}
-
-	return true
-}
-
 // onInteractionCreate handles slash command interactions.
 func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.ApplicationCommandData().Name == "" {
+	if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name == "" {
 		return
 	}
 
 	startTime := time.Now()
 	commandName := i.ApplicationCommandData().Name
 
-	logger := logging.WithComponent("music-bot")
+	logger := withComponent("music-bot")
 	logger.Debug("Received slash command", "command", commandName, "user", getUserID(i))
 
 	handler, exists := b.commandHandlers[commandName]
@@ -341,9 +606,32 @@ func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.Interaction
 		return
 	}
 
+	// Without a guild there is no voice channel to play in
+	if i.GuildID == "" {
+		b.respondWithError(s, i, "Music commands can only be used in a server.")
+		return
+	}
+
+	if !b.commandEnabled(commandName, i.GuildID) {
+		if err := features.RespondDisabled(s, i); err != nil {
+			logger.Error("Failed to send disabled command response", "error", err)
+		}
+		b.audit.RecordResult(audit.FromInteraction(i), startTime, errors.NewPermissionError("command disabled in this guild", nil))
+		return
+	}
+
+	if ok, err := b.Guilds().CheckInteraction(s, i); !ok {
+		if err != nil {
+			logger.Error("Failed to send command rejection", "error", err)
+		}
+		b.audit.RecordResult(audit.FromInteraction(i), startTime, errors.NewPermissionError("command not allowed by guild settings", nil))
+		return
+	}
+
 	// Execute command
 	err := handler(s, i)
 	success := err == nil
+	b.audit.RecordResult(audit.FromInteraction(i), startTime, err)
 
 	// Record metrics
 	metrics.RecordCommand(commandName, getUserID(i), success, time.Since(startTime))
@@ -355,19 +643,21 @@ func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.Interaction
 	}
 }
 
+// commandEnabled reports whether a command is enabled for a guild.
+func (b *Bot) commandEnabled(commandName, guildID string) bool {
+	if strings.HasPrefix(commandName, "playlist_") && !b.features.Enabled(playlistsFlag, guildID) {
+		return false
+	}
+	return b.features.Enabled(features.CommandFlag(commandName), guildID)
+}
+
 // Helper functions for interaction handling
 func getUserID(i *discordgo.InteractionCreate) string {
-	if i.Member != nil {
-		return i.Member.User.ID
-	}
-	return i.User.ID
+	return shareddiscord.InteractionUserID(i.Interaction)
 }
 
 func getUsername(i *discordgo.InteractionCreate) string {
-	if i.Member != nil {
-		return i.Member.User.Username
-	}
-	return i.User.Username
+	return shareddiscord.InteractionUsername(i.Interaction)
 }
 
 func (b *Bot) respondWithError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
@@ -379,7 +669,7 @@ func (b *Bot) respondWithError(s *discordgo.Session, i *discordgo.InteractionCre
 		},
 	})
 	if err != nil {
-		logger := logging.WithComponent("music-bot")
+		logger := withComponent("music-bot")
 		logger.Error("Failed to send error response", "error", err)
 	}
 }
@@ -402,7 +692,7 @@ func (b *Bot) handlePlaySlashCommand(s *discordgo.Session, i *discordgo.Interact
 	}
 
 	query := options[0].StringValue()
-	if err := discord.ValidateInput(query, 500); err != nil {
+	if err := shareddiscord.ValidateInput(query, 500); err != nil {
 		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -449,6 +739,18 @@ func (b *Bot) handlePlaySlashCommand(s *discordgo.Session, i *discordgo.Interact
 		})
 	}
 
+	// Check the guild's queue limit before searching
+	queue := b.queueManager.GetQueue(guildID)
+	if maxQueueSize := b.Guilds().ConfigFor(guildID).MaxQueueSize; queue.Size() >= maxQueueSize {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("❌ The queue is full (%d songs). Wait for a few songs to finish or use /skip.", maxQueueSize),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
 	// Send immediate response to avoid timeout
 	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -464,7 +766,7 @@ func (b *Bot) handlePlaySlashCommand(s *discordgo.Session, i *discordgo.Interact
 	song, err := b.extractSongInfo(query)
 	if err != nil {
 		metrics.RecordAPIRequest("youtube", "extract", false, time.Since(startTime))
-		_, editErr := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		_, editErr := b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{
 			Content: &[]string{fmt.Sprintf("❌ Could not find or load the requested song: %s", err.Error())}[0],
 		})
 		if editErr != nil {
@@ -479,7 +781,7 @@ func (b *Bot) handlePlaySlashCommand(s *discordgo.Session, i *discordgo.Interact
 	// Automatically join the user's voice channel
 	audioConn, err := b.audioPlayer.GetConnection(s, guildID, voiceState.ChannelID)
 	if err != nil {
-		_, editErr := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		_, editErr := b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{
 			Content: &[]string{fmt.Sprintf("❌ Failed to join your voice channel: %s\n\nPlease check that I have permission to connect and speak in this channel.", err.Error())}[0],
 		})
 		if editErr != nil {
@@ -490,7 +792,6 @@ func (b *Bot) handlePlaySlashCommand(s *discordgo.Session, i *discordgo.Interact
 	}
 
 	// Add to queue
-	queue := b.queueManager.GetQueue(guildID)
 	position := queue.Add(song)
 
 	var response string
@@ -501,7 +802,7 @@ func (b *Bot) handlePlaySlashCommand(s *discordgo.Session, i *discordgo.Interact
 		response = fmt.Sprintf("🎵 Added to queue: **%s**\nPosition in queue: %d", song.Title, position+1)
 	}
 
-	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+	_, err = b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{
 		Content: &response,
 	})
 
@@ -644,6 +945,9 @@ func (b *Bot) handleStopSlashCommand(s *discordgo.Session, i *discordgo.Interact
 		return err
 	}
 
+	// The current song ends early, like a skipped one
+	b.history.finish(guildID, true)
+
 	// Stop audio stream, disconnect from voice and clear queue
 	b.audioPlayer.enhanced.StopStream(guildID)
 	b.audioPlayer.Disconnect(guildID)
@@ -749,158 +1053,6 @@ func (b *Bot) handleVolumeSlashCommand(s *discordgo.Session, i *discordgo.Intera
 	return err
 }
 
-// Playlist slash command handlers
-
-// handlePlaylistCreateSlashCommand handles the /playlist_create slash command.
-func (b *Bot) handlePlaylistCreateSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	startTime := time.Now()
-	userID := getUserID(i)
-	guildID := i.GuildID
-
-	options := i.ApplicationCommandData().Options
-	if len(options) == 0 || options[0].Name != "name" {
-		return b.respondWithSlashError(s, i, "Please provide a playlist name")
-	}
-
-	name := options[0].StringValue()
-	if len(name) > 50 {
-		return b.respondWithSlashError(s, i, "Playlist name must be 50 characters or less")
-	}
-
-	playlistID, err := b.database.CreatePlaylist(userID, guildID, name)
-	if err != nil {
-		logger := logging.WithComponent("playlist")
-		logging.LogError(logger, err, "Failed to create playlist")
-		return b.respondWithSlashError(s, i, "Failed to create playlist")
-	}
-
-	response := fmt.Sprintf("✅ Created playlist **%s** (ID: %d)", name, playlistID)
-	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: response,
-		},
-	})
-
-	metrics.RecordCommand("playlist_create", userID, err == nil, time.Since(startTime))
-	return err
-}
-
-// handlePlaylistListSlashCommand handles the /playlist_list slash command.
-func (b *Bot) handlePlaylistListSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	startTime := time.Now()
-	userID := getUserID(i)
-	username := getUsername(i)
-	guildID := i.GuildID
-
-	playlists, err := b.database.GetUserPlaylists(userID, guildID)
-	if err != nil {
-		logger := logging.WithComponent("playlist")
-		logging.LogError(logger, err, "Failed to list playlists")
-		return b.respondWithSlashError(s, i, "Failed to list playlists")
-	}
-
-	if len(playlists) == 0 {
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "📝 You don't have any playlists yet. Use `/playlist_create` to make one!",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-		metrics.RecordCommand("playlist_list", userID, err == nil, time.Since(startTime))
-		return err
-	}
-
-	embed := discord.CreateEmbed(fmt.Sprintf("🎵 %s's Playlists", username), "", "info")
-
-	displayCount := len(playlists)
-	if displayCount > 10 {
-		displayCount = 10
-	}
-
-	for i := 0; i < displayCount; i++ {
-		playlist := playlists[i]
-		songCount := len(playlist.Songs)
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:   fmt.Sprintf("%s (ID: %d)", playlist.Name, playlist.ID),
-			Value:  fmt.Sprintf("%d song%s", songCount, map[bool]string{true: "", false: "s"}[songCount == 1]),
-			Inline: true,
-		})
-	}
-
-	if len(playlists) > 10 {
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:   "",
-			Value:  fmt.Sprintf("... and %d more playlists", len(playlists)-10),
-			Inline: false,
-		})
-	}
-
-	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-		},
-	})
-
-	metrics.RecordCommand("playlist_list", userID, err == nil, time.Since(startTime))
-	return err
-}
-
-// handlePlaylistShowSlashCommand handles the /playlist_show slash command.
-func (b *Bot) handlePlaylistShowSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	return b.respondWithSlashError(s, i, "🚧 Playlist show not yet implemented")
-}
-
-// handlePlaylistPlaySlashCommand handles the /playlist_play slash command.
-func (b *Bot) handlePlaylistPlaySlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	return b.respondWithSlashError(s, i, "🚧 Playlist play not yet implemented")
-}
-
-// handlePlaylistAddSlashCommand handles the /playlist_add slash command.
-func (b *Bot) handlePlaylistAddSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	return b.respondWithSlashError(s, i, "🚧 Playlist add not yet implemented")
-}
-
-// handlePlaylistRemoveSlashCommand handles the /playlist_remove slash command.
-func (b *Bot) handlePlaylistRemoveSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	return b.respondWithSlashError(s, i, "🚧 Playlist remove not yet implemented")
-}
-
-// handlePlaylistDeleteSlashCommand handles the /playlist_delete slash command.
-func (b *Bot) handlePlaylistDeleteSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if b.database == nil {
-		return b.respondPlaylistNotAvailable(s, i)
-	}
-
-	return b.respondWithSlashError(s, i, "🚧 Playlist delete not yet implemented")
-}
-
 // Helper methods for slash command responses
 func (b *Bot) respondPlaylistNotAvailable(s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -926,7 +1078,7 @@ func (b *Bot) respondWithSlashError(s *discordgo.Session, i *discordgo.Interacti
 
 // getUserVoiceState gets the user's voice state.
 func (b *Bot) getUserVoiceState(s *discordgo.Session, guildID, userID string) (*discordgo.VoiceState, error) {
-	logger := logging.WithComponent("music-bot")
+	logger := withComponent("music-bot")
 
 	guild, err := s.State.Guild(guildID)
 	if err != nil {
@@ -1011,7 +1163,7 @@ func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
 	// Explicitly do nothing - music bot only handles slash commands
 	// This prevents interference with other bots that use text commands
 	// Debug: if this function is being called with ! commands, there's still interference
-	logger := logging.WithComponent("music-bot")
+	logger := withComponent("music-bot")
 	if strings.HasPrefix(m.Content, "!") && !m.Author.Bot {
 		logger.Debug("Music bot received text command but ignoring", "content", m.Content[:min(20, len(m.Content))], "author", m.Author.Username)
 	}
@@ -1028,7 +1180,7 @@ func min(a, b int) int {
 
 // buildQueueEmbed builds an embed showing the current queue.
 func (b *Bot) buildQueueEmbed(queue *Queue) *discordgo.MessageEmbed {
-	embed := discord.CreateEmbed("🎵 Music Queue", "", "info")
+	embed := shareddiscord.CreateEmbed("🎵 Music Queue", "", "info")
 
 	if current := queue.Current(); current != nil {
 		status := "▶️ Playing"
@@ -1,5 +1,5 @@
-// Package main provides audio extraction functionality.
-package main
+// Package discord provides audio extraction functionality.
+package discord
 
 import (
 	"encoding/json"
@@ -89,6 +89,7 @@ func (e *AudioExtractor) ExtractSongInfo(query string) (*Song, error) {
 	song := &Song{
 		Title:      getStringFromMap(info, "title", "Unknown"),
 		WebpageURL: getStringFromMap(info, "webpage_url", ""),
+		Source:     getStringFromMap(info, "extractor_key", ""),
 	}
 
 	// Extract duration if available
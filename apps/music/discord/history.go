@@ -0,0 +1,317 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/internal/storage"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+const (
+	// statsTopLimit is the number of top tracks and requesters /music_stats
+	// lists.
+	statsTopLimit = 5
+
+	// statsRecentLimit is the number of recently played tracks /music_stats
+	// lists.
+	statsRecentLimit = 5
+
+	// statsTitleLength is the number of characters of a title /music_stats
+	// shows, to keep each list within an embed field.
+	statsTitleLength = 60
+)
+
+// statsPeriods are the periods /music_stats covers, by option value.
+var statsPeriods = map[string]struct {
+	label  string
+	length time.Duration
+}{
+	"week":  {"the past 7 days", 7 * 24 * time.Hour},
+	"month": {"the past 30 days", 30 * 24 * time.Hour},
+	"all":   {"all time", 0},
+}
+
+// playHistory records the songs played in each guild in the listening
+// history. A nil *playHistory records nothing, and neither does one that was
+// stopped.
+type playHistory struct {
+	history storage.History
+	context func() (context.Context, context.CancelFunc)
+
+	// playing holds the history ID of the song playing in each guild.
+	playing map[string]int64
+	stopped bool
+	// writes counts the recordings in progress, which stop waits for.
+	writes sync.WaitGroup
+	mutex  sync.Mutex
+}
+
+// newPlayHistory creates a recorder that writes to history, with a context
+// from newContext for each write.
+func newPlayHistory(history storage.History, newContext func() (context.Context, context.CancelFunc)) *playHistory {
+	return &playHistory{
+		history: history,
+		context: newContext,
+		playing: make(map[string]int64),
+	}
+}
+
+// begin reports whether a recording may start and, if so, counts it as in
+// progress until the caller calls h.writes.Done.
+func (h *playHistory) begin() bool {
+	if h == nil {
+		return false
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.stopped {
+		return false
+	}
+	h.writes.Add(1)
+	return true
+}
+
+// start records a song that started playing in a guild.
+func (h *playHistory) start(guildID string, song *Song) {
+	if !h.begin() {
+		return
+	}
+	defer h.writes.Done()
+
+	h.end(guildID, false)
+
+	// Stream URLs expire, so the web page identifies the track
+	url := song.URL
+	if song.WebpageURL != "" {
+		url = song.WebpageURL
+	}
+
+	ctx, cancel := h.context()
+	defer cancel()
+
+	id, err := h.history.RecordPlay(ctx, database.Play{
+		GuildID:     guildID,
+		RequesterID: song.RequesterID,
+		Title:       song.Title,
+		URL:         url,
+		Duration:    song.Duration,
+		StartedAt:   time.Now(),
+	})
+	if err != nil {
+		logger := withComponent("music-history").With("guild_id", guildID, "song", song.Title)
+		logging.LogError(logger, err, "Failed to record play")
+		return
+	}
+
+	h.mutex.Lock()
+	h.playing[guildID] = id
+	h.mutex.Unlock()
+}
+
+// finish records that the song playing in a guild ended. It does nothing if
+// no play is in progress, e.g. when the end was already recorded.
+func (h *playHistory) finish(guildID string, skipped bool) {
+	if !h.begin() {
+		return
+	}
+	defer h.writes.Done()
+
+	h.end(guildID, skipped)
+}
+
+// end records the end of the play in progress in a guild, if any.
+func (h *playHistory) end(guildID string, skipped bool) {
+	h.mutex.Lock()
+	id, ok := h.playing[guildID]
+	delete(h.playing, guildID)
+	h.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := h.context()
+	defer cancel()
+
+	if err := h.history.FinishPlay(ctx, id, time.Now(), skipped); err != nil {
+		logger := withComponent("music-history").With("guild_id", guildID, "play_id", id)
+		logging.LogError(logger, err, "Failed to finish play")
+	}
+}
+
+// songEnded records the end of a song; it is called by the audio player.
+func (h *playHistory) songEnded(guildID string, _ *Song, skipped bool) {
+	h.finish(guildID, skipped)
+}
+
+// stop stops recording, waits for the recordings in progress and then
+// records the end of every song still playing, before the bot closes the
+// database.
+func (h *playHistory) stop() {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	h.stopped = true
+	h.mutex.Unlock()
+	h.writes.Wait()
+
+	h.mutex.Lock()
+	guildIDs := make([]string, 0, len(h.playing))
+	for guildID := range h.playing {
+		guildIDs = append(guildIDs, guildID)
+	}
+	h.mutex.Unlock()
+
+	for _, guildID := range guildIDs {
+		h.end(guildID, false)
+	}
+}
+
+// handleMusicStatsSlashCommand handles the /music_stats slash command. It
+// shows the guild's top tracks and requesters and the hours listened in a
+// period, and the tracks the user requested last.
+func (b *Bot) handleMusicStatsSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.store == nil {
+		return b.respondWithSlashError(s, i, "❌ Music stats are not available")
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+	guildID := i.GuildID
+
+	periodName := "week"
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "period" {
+			periodName = option.StringValue()
+		}
+	}
+	period, ok := statsPeriods[periodName]
+	if !ok {
+		return b.respondWithSlashError(s, i, "❌ Unknown period")
+	}
+
+	var since time.Time
+	if period.length > 0 {
+		since = startTime.Add(-period.length)
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	history := b.store.History()
+	stats, err := history.PlayStats(ctx, guildID, since, statsTopLimit)
+	if err != nil {
+		logger := withComponent("music-history")
+		logging.LogError(logger, err, "Failed to get music stats")
+		return b.respondWithSlashError(s, i, "❌ Failed to get music stats")
+	}
+	recent, err := history.RecentPlays(ctx, guildID, userID, statsRecentLimit)
+	if err != nil {
+		logger := withComponent("music-history")
+		logging.LogError(logger, err, "Failed to get recently played tracks")
+		return b.respondWithSlashError(s, i, "❌ Failed to get music stats")
+	}
+
+	embed := shareddiscord.CreateEmbed("📊 Music Stats", fmt.Sprintf("Listening in this server over %s", period.label), "info")
+	embed.Fields = append(embed.Fields,
+		&discordgo.MessageEmbedField{
+			Name:   "Tracks Played",
+			Value:  fmt.Sprintf("%d (%d skipped)", stats.Plays, stats.Skipped),
+			Inline: true,
+		},
+		&discordgo.MessageEmbedField{
+			Name:   "Hours Listened",
+			Value:  fmt.Sprintf("%.1f", float64(stats.Listened)/3600),
+			Inline: true,
+		},
+		&discordgo.MessageEmbedField{
+			Name:  "🔥 Top Tracks",
+			Value: topTracksList(stats.TopTracks),
+		},
+		&discordgo.MessageEmbedField{
+			Name:  "🎧 Top Requesters",
+			Value: topRequestersList(stats.TopRequesters),
+		},
+		&discordgo.MessageEmbedField{
+			Name:  "🕘 Your Recently Played",
+			Value: recentPlaysList(recent),
+		},
+	)
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			// Mention requesters without pinging them
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+
+	metrics.RecordCommand("music_stats", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// topTracksList formats the most played tracks as a numbered list.
+func topTracksList(tracks []database.TrackPlays) string {
+	if len(tracks) == 0 {
+		return "Nothing played yet"
+	}
+
+	var list strings.Builder
+	for n, track := range tracks {
+		song := database.Song{Title: truncateRunes(track.Title, statsTitleLength), WebpageURL: track.URL}
+		fmt.Fprintf(&list, "%d. %s — %s\n", n+1, songLink(song), playCount(track.Plays))
+	}
+	return list.String()
+}
+
+// topRequestersList formats the users who requested the most tracks as a
+// numbered list.
+func topRequestersList(requesters []database.RequesterPlays) string {
+	if len(requesters) == 0 {
+		return "Nobody requested anything yet"
+	}
+
+	var list strings.Builder
+	for n, requester := range requesters {
+		fmt.Fprintf(&list, "%d. <@%s> — %s\n", n+1, requester.RequesterID, playCount(requester.Plays))
+	}
+	return list.String()
+}
+
+// recentPlaysList formats plays with when they started, newest first.
+func recentPlaysList(plays []database.Play) string {
+	if len(plays) == 0 {
+		return "You haven't requested anything yet"
+	}
+
+	var list strings.Builder
+	for _, play := range plays {
+		song := database.Song{Title: truncateRunes(play.Title, statsTitleLength), WebpageURL: play.URL}
+		skipped := ""
+		if play.Skipped {
+			skipped = " ⏭️"
+		}
+		fmt.Fprintf(&list, "<t:%d:R> %s%s\n", play.StartedAt.Unix(), songLink(song), skipped)
+	}
+	return list.String()
+}
+
+// playCount formats a number of plays, e.g. "1 play" or "3 plays".
+func playCount(n int) string {
+	if n == 1 {
+		return "1 play"
+	}
+	return fmt.Sprintf("%d plays", n)
+}
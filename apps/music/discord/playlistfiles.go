@@ -0,0 +1,456 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/apps/music/playlistfile"
+	"github.com/sawyer/go-discord-bots/internal/database"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+const (
+	// importMaxFileSize is the largest playlist file accepted for import.
+	importMaxFileSize = 1 << 20
+
+	// importTrackLimit is the number of tracks imported from one file.
+	// Every track is looked up with yt-dlp, and the import has to finish
+	// while the interaction can still be edited (15 minutes).
+	importTrackLimit = 100
+
+	// importProgressInterval is how often import progress is shown.
+	importProgressInterval = 3 * time.Second
+
+	// importFailureLimit is the number of failed tracks listed in the report.
+	importFailureLimit = 10
+)
+
+// playlistImports tracks running playlist imports so that each user runs one
+// at a time and the bot can stop them on shutdown.
+type playlistImports struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]bool // user IDs with a running import
+}
+
+// newPlaylistImports creates an empty import tracker.
+func newPlaylistImports() *playlistImports {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &playlistImports{
+		ctx:    ctx,
+		cancel: cancel,
+		active: make(map[string]bool),
+	}
+}
+
+// begin marks an import for a user as running. It returns false if the user
+// already has one.
+func (p *playlistImports) begin(userID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.active[userID] {
+		return false
+	}
+	p.active[userID] = true
+	return true
+}
+
+// end marks a user's import as finished.
+func (p *playlistImports) end(userID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.active, userID)
+}
+
+// stop stops running imports after their current track and waits for them.
+func (p *playlistImports) stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// importFailure is a track that could not be imported.
+type importFailure struct {
+	track  playlistfile.Track
+	reason string
+}
+
+// handlePlaylistExportSlashCommand handles the /playlist_export slash command.
+// The playlist is sent back as a file attachment.
+func (b *Bot) handlePlaylistExportSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, nil)
+	if !ok {
+		return err
+	}
+
+	format := playlistfile.JSON
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "format" {
+			if format, err = playlistfile.ParseFormat(option.StringValue()); err != nil {
+				return b.respondWithSlashError(s, i, "❌ "+err.Error())
+			}
+		}
+	}
+
+	file := &playlistfile.Playlist{Name: playlist.Name}
+	for _, song := range playlist.Songs {
+		location := song.WebpageURL
+		if location == "" {
+			location = song.URL
+		}
+		file.Tracks = append(file.Tracks, playlistfile.Track{
+			Title:    song.Title,
+			Location: location,
+			Duration: song.Duration,
+			Source:   song.Source,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := playlistfile.Write(format, &buf, file); err != nil {
+		logger := withComponent("playlist")
+		logging.LogError(logger, err, "Failed to export playlist")
+		return b.respondWithSlashError(s, i, "Failed to export the playlist")
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("📤 **%s** (%s) as %s", playlist.Name, songCount(len(playlist.Songs)), strings.ToUpper(string(format))),
+			Files: []*discordgo.File{{
+				Name:        exportFilename(playlist.Name) + format.Extension(),
+				ContentType: format.ContentType(),
+				Reader:      &buf,
+			}},
+		},
+	})
+
+	metrics.RecordCommand("playlist_export", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistImportSlashCommand handles the /playlist_import slash command.
+// It reads the attached playlist file into a new playlist and looks up its
+// tracks in the background, editing the response with the progress.
+func (b *Bot) handlePlaylistImportSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+	data := i.ApplicationCommandData()
+
+	var attachment *discordgo.MessageAttachment
+	var name string
+	for _, option := range data.Options {
+		switch option.Name {
+		case "file":
+			if id, ok := option.Value.(string); ok && data.Resolved != nil {
+				attachment = data.Resolved.Attachments[id]
+			}
+		case "name":
+			name = strings.TrimSpace(option.StringValue())
+		}
+	}
+
+	if attachment == nil {
+		return b.respondWithSlashError(s, i, "Please attach a playlist file")
+	}
+	format, err := playlistfile.FormatForFile(attachment.Filename)
+	if err != nil {
+		return b.respondWithSlashError(s, i, "❌ Unsupported playlist file. Use .m3u, .m3u8, .xspf or .json")
+	}
+	if attachment.Size > importMaxFileSize {
+		return b.respondWithSlashError(s, i, fmt.Sprintf("❌ Playlist files can be at most %d KB", importMaxFileSize/1024))
+	}
+	if len(name) > 50 {
+		return b.respondWithSlashError(s, i, "Playlist name must be 50 characters or less")
+	}
+
+	if !b.imports.begin(userID) {
+		return b.respondWithSlashError(s, i, "❌ You are already importing a playlist. Wait for it to finish.")
+	}
+	started := false
+	defer func() {
+		if !started {
+			b.imports.end(userID)
+		}
+	}()
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("📥 Reading **%s**...", attachment.Filename),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := downloadPlaylistFile(attachment.URL, format)
+	if err != nil {
+		b.editImportStatus(i, fmt.Sprintf("❌ Could not read **%s**: %s", attachment.Filename, err.Error()))
+		metrics.RecordCommand("playlist_import", userID, false, time.Since(startTime))
+		return nil
+	}
+	if len(file.Tracks) == 0 {
+		b.editImportStatus(i, fmt.Sprintf("❌ **%s** contains no tracks", attachment.Filename))
+		metrics.RecordCommand("playlist_import", userID, false, time.Since(startTime))
+		return nil
+	}
+
+	if name == "" {
+		name = file.Name
+	}
+	if name == "" {
+		name = strings.TrimSuffix(attachment.Filename, path.Ext(attachment.Filename))
+	}
+	name = truncateRunes(name, 50)
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	playlistID, err := b.playlists.CreatePlaylist(ctx, name, userID, i.GuildID)
+	if err != nil {
+		b.editImportStatus(i, playlistFailureMessage(err, "❌ Failed to create playlist"))
+		metrics.RecordCommand("playlist_import", userID, false, time.Since(startTime))
+		return nil
+	}
+
+	tracks := file.Tracks
+	skipped := 0
+	if len(tracks) > importTrackLimit {
+		skipped = len(tracks) - importTrackLimit
+		tracks = tracks[:importTrackLimit]
+	}
+
+	actor := b.playlistActor(s, i)
+	started = true
+	b.imports.wg.Add(1)
+	go func() {
+		defer b.imports.wg.Done()
+		defer b.imports.end(userID)
+
+		imported := b.importTracks(i, format, playlistID, name, tracks, skipped, actor)
+		metrics.RecordCommand("playlist_import", userID, imported > 0, time.Since(startTime))
+	}()
+
+	return nil
+}
+
+// importTracks looks up tracks one by one and adds the songs found to a
+// playlist, reporting progress by editing the interaction response. It
+// returns the number of songs added.
+func (b *Bot) importTracks(i *discordgo.InteractionCreate, format playlistfile.Format, playlistID int, name string,
+	tracks []playlistfile.Track, skipped int, actor database.Actor) int {
+	var failures []importFailure
+	imported := 0
+	lastProgress := time.Now()
+
+	for n, track := range tracks {
+		if b.imports.ctx.Err() != nil {
+			b.editImportStatus(i, fmt.Sprintf("⏹️ Import of **%s** stopped because the bot is shutting down. %s imported (ID: %d).",
+				name, songCount(imported), playlistID))
+			return imported
+		}
+
+		if time.Since(lastProgress) >= importProgressInterval {
+			lastProgress = time.Now()
+			b.editImportStatus(i, fmt.Sprintf("📥 Importing **%s**: %d/%d tracks looked up, %d failed...",
+				name, n, len(tracks), len(failures)))
+		}
+
+		if reason := b.importTrack(playlistID, track, actor); reason != "" {
+			failures = append(failures, importFailure{track: track, reason: reason})
+			continue
+		}
+		imported++
+	}
+
+	b.editImportStatus(i, importReport(format, playlistID, name, imported, len(tracks), skipped, failures))
+	return imported
+}
+
+// importTrack looks up a track and adds it to a playlist. It returns why the
+// track could not be imported, or "" on success.
+func (b *Bot) importTrack(playlistID int, track playlistfile.Track, actor database.Actor) string {
+	query := importQuery(track)
+	if query == "" {
+		return "no URL or title"
+	}
+	if err := shareddiscord.ValidateInput(query, 500); err != nil {
+		return "invalid entry"
+	}
+
+	song, err := b.extractSongInfo(query)
+	if err != nil {
+		return "not found"
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	if err := b.playlists.AddSongToPlaylist(ctx, playlistID, playlistSong(song), actor); err != nil {
+		return strings.TrimPrefix(playlistFailureMessage(err, "could not be saved"), "❌ ")
+	}
+	return ""
+}
+
+// editImportStatus replaces the import response. If the interaction can no
+// longer be edited, the status is posted to the channel instead.
+func (b *Bot) editImportStatus(i *discordgo.InteractionCreate, content string) {
+	_, err := b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{Content: &content})
+	if err == nil {
+		return
+	}
+
+	if _, err := b.Outbound().SendText(i.ChannelID, content); err != nil {
+		logger := withComponent("playlist")
+		logging.LogError(logger, err, "Failed to report playlist import status")
+	}
+}
+
+// downloadPlaylistFile downloads and parses an attached playlist file.
+func downloadPlaylistFile(url string, format playlistfile.Format) (*playlistfile.Playlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, importMaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download failed")
+	}
+	if len(data) > importMaxFileSize {
+		return nil, fmt.Errorf("file is larger than %d KB", importMaxFileSize/1024)
+	}
+
+	return playlistfile.Read(format, bytes.NewReader(data))
+}
+
+// importQuery returns what to look up for a track: its URL, else its artist
+// and title, else the name of a local file without its extension.
+func importQuery(track playlistfile.Track) string {
+	location := strings.TrimSpace(track.Location)
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return location
+	}
+
+	if title := strings.TrimSpace(track.Artist + " " + track.Title); title != "" {
+		return title
+	}
+
+	// Local files of other players, such as C:\Music\Artist - Song.mp3
+	location = strings.TrimPrefix(location, "file://")
+	base := path.Base(strings.ReplaceAll(location, "\\", "/"))
+	base = strings.TrimSuffix(base, path.Ext(base))
+	if base == "." || base == "/" {
+		return ""
+	}
+	return strings.TrimSpace(base)
+}
+
+// importReport returns the final message of an import.
+func importReport(format playlistfile.Format, playlistID int, name string, imported, total, skipped int, failures []importFailure) string {
+	var report strings.Builder
+
+	icon := "✅"
+	if imported == 0 {
+		icon = "❌"
+	} else if len(failures) > 0 || skipped > 0 {
+		icon = "⚠️"
+	}
+	fmt.Fprintf(&report, "%s Imported %d of %d tracks into **%s** (ID: %d)", icon, imported, total, name, playlistID)
+
+	if skipped > 0 {
+		fmt.Fprintf(&report, "\nOnly the first %d tracks are imported; %d more were skipped.", importTrackLimit, skipped)
+	}
+
+	if len(failures) > 0 {
+		report.WriteString("\nCould not import:")
+		for n, failure := range failures {
+			if n == importFailureLimit {
+				fmt.Fprintf(&report, "\n... and %d more", len(failures)-importFailureLimit)
+				break
+			}
+
+			label := failure.track.Title
+			if label == "" {
+				label = failure.track.Location
+			}
+			fmt.Fprintf(&report, "\n• %s %d: %s (%s)", entryUnit(format), failure.track.Line,
+				truncateRunes(label, 60), failure.reason)
+		}
+	}
+
+	return report.String()
+}
+
+// entryUnit names what Track.Line counts in a format.
+func entryUnit(format playlistfile.Format) string {
+	if format == playlistfile.M3U {
+		return "line"
+	}
+	return "track"
+}
+
+// exportFilename turns a playlist name into a safe file name.
+func exportFilename(name string) string {
+	filename := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+
+	filename = strings.Trim(filename, "_")
+	if filename == "" {
+		return "playlist"
+	}
+	return filename
+}
+
+// truncateRunes shortens s to at most n characters.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,813 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/internal/database"
+	dberrors "github.com/sawyer/go-discord-bots/internal/errors"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+const (
+	// playlistShowLimit is the number of songs /playlist_show lists.
+	playlistShowLimit = 20
+
+	// djRoleName is the name of the role that makes members DJs.
+	djRoleName = "DJ"
+)
+
+// Playlist slash command handlers
+
+// handlePlaylistCreateSlashCommand handles the /playlist_create slash command.
+func (b *Bot) handlePlaylistCreateSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+	guildID := i.GuildID
+
+	var name string
+	var server bool
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "name":
+			name = strings.TrimSpace(option.StringValue())
+		case "server":
+			server = option.BoolValue()
+		}
+	}
+
+	if name == "" {
+		return b.respondWithSlashError(s, i, "Please provide a playlist name")
+	}
+	if len(name) > 50 {
+		return b.respondWithSlashError(s, i, "Playlist name must be 50 characters or less")
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	var playlistID int
+	var err error
+	if server {
+		playlistID, err = b.playlists.CreateServerPlaylist(ctx, name, b.playlistActor(s, i))
+	} else {
+		playlistID, err = b.playlists.CreatePlaylist(ctx, name, userID, guildID)
+	}
+	if err != nil {
+		return b.respondPlaylistFailure(s, i, err, "Failed to create playlist")
+	}
+
+	response := fmt.Sprintf("✅ Created playlist **%s** (ID: %d)", name, playlistID)
+	if server {
+		response = fmt.Sprintf("✅ Created server playlist **%s** (ID: %d). DJs can manage it, and everyone in the server can play it.", name, playlistID)
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: response,
+		},
+	})
+
+	metrics.RecordCommand("playlist_create", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistListSlashCommand handles the /playlist_list slash command.
+func (b *Bot) handlePlaylistListSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+	username := getUsername(i)
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	playlists, err := b.playlists.GetPlaylistsFor(ctx, b.playlistActor(s, i))
+	if err != nil {
+		logger := withComponent("playlist")
+		logging.LogError(logger, err, "Failed to list playlists")
+		return b.respondWithSlashError(s, i, "Failed to list playlists")
+	}
+
+	if len(playlists) == 0 {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "📝 You don't have any playlists yet. Use `/playlist_create` to make one!",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		metrics.RecordCommand("playlist_list", userID, err == nil, time.Since(startTime))
+		return err
+	}
+
+	embed := shareddiscord.CreateEmbed(fmt.Sprintf("🎵 %s's Playlists", username),
+		"Your playlists, the ones you collaborate on (👥) and server playlists (📻)", "info")
+
+	displayCount := min(len(playlists), 10)
+	for i := 0; i < displayCount; i++ {
+		playlist := playlists[i]
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("%s%s (ID: %d)", playlistIcon(playlist, userID), playlist.Name, playlist.ID),
+			Value:  fmt.Sprintf("%s • %s", songCount(len(playlist.Songs)), playlist.Visibility),
+			Inline: true,
+		})
+	}
+
+	if len(playlists) > 10 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "",
+			Value:  fmt.Sprintf("... and %d more playlists", len(playlists)-10),
+			Inline: false,
+		})
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+
+	metrics.RecordCommand("playlist_list", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistShowSlashCommand handles the /playlist_show slash command.
+func (b *Bot) handlePlaylistShowSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, nil)
+	if !ok {
+		return err
+	}
+
+	embed := shareddiscord.CreateEmbed(fmt.Sprintf("🎵 %s%s", playlistIcon(playlist, userID), playlist.Name),
+		fmt.Sprintf("%s • by <@%s> • %s • ID: %d", songCount(len(playlist.Songs)), playlist.OwnerID, playlist.Visibility, playlist.ID), "info")
+
+	if len(playlist.Collaborators) > 0 {
+		mentions := make([]string, len(playlist.Collaborators))
+		for n, collaborator := range playlist.Collaborators {
+			mentions[n] = fmt.Sprintf("<@%s>", collaborator)
+		}
+		embed.Description += "\nCollaborators: " + strings.Join(mentions, ", ")
+	}
+	if len(playlist.Songs) == 0 {
+		embed.Description += "\n\nThis playlist is empty. Add the current song with `/playlist_add`."
+	}
+
+	var lines []string
+	for n, song := range playlist.Songs {
+		if n == playlistShowLimit {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(playlist.Songs)-playlistShowLimit))
+			break
+		}
+		line := fmt.Sprintf("`%d.` %s%s", n+1, songLink(song), formatSongDuration(song.Duration))
+		if song.AddedBy != "" && song.AddedBy != playlist.OwnerID {
+			line += fmt.Sprintf(" • added by <@%s>", song.AddedBy)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Songs",
+			Value: strings.Join(lines, "\n"),
+		})
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+
+	metrics.RecordCommand("playlist_show", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistPlaySlashCommand handles the /playlist_play slash command.
+// It joins the user's voice channel and queues every song of the playlist
+// that fits into the guild's queue.
+func (b *Bot) handlePlaylistPlaySlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+	username := getUsername(i)
+	guildID := i.GuildID
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, nil)
+	if !ok {
+		return err
+	}
+	if len(playlist.Songs) == 0 {
+		return b.respondWithSlashError(s, i, fmt.Sprintf("❌ Playlist **%s** is empty", playlist.Name))
+	}
+
+	// Check if user is in a voice channel
+	voiceState, err := b.getUserVoiceState(s, guildID, userID)
+	if err != nil {
+		return b.respondWithSlashError(s, i, "❌ Failed to check your voice channel status")
+	}
+	if voiceState == nil {
+		return b.respondWithSlashError(s, i, "❌ You must be in a voice channel to play a playlist!")
+	}
+
+	// Check if bot is already in a different voice channel
+	botVoiceState, err := b.getUserVoiceState(s, guildID, s.State.User.ID)
+	if err == nil && botVoiceState != nil && botVoiceState.ChannelID != voiceState.ChannelID {
+		return b.respondWithSlashError(s, i, "❌ I'm already playing music in another voice channel! Please join that channel or wait for the current session to end.")
+	}
+
+	queue := b.queueManager.GetQueue(guildID)
+	space := b.Guilds().ConfigFor(guildID).MaxQueueSize - queue.Size()
+	if space <= 0 {
+		return b.respondWithSlashError(s, i, "❌ The queue is full. Wait for a few songs to finish or use /skip.")
+	}
+
+	// Send immediate response to avoid timeout while joining
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("🎵 Loading playlist **%s**...", playlist.Name),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	audioConn, err := b.audioPlayer.GetConnection(s, guildID, voiceState.ChannelID)
+	if err != nil {
+		_, editErr := b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{
+			Content: &[]string{fmt.Sprintf("❌ Failed to join your voice channel: %s", err.Error())}[0],
+		})
+		if editErr != nil {
+			return editErr
+		}
+		metrics.RecordCommand("playlist_play", userID, false, time.Since(startTime))
+		return err
+	}
+
+	queued := 0
+	for _, stored := range playlist.Songs {
+		if queued == space {
+			break
+		}
+		queue.Add(queueSong(stored, userID, username))
+		queued++
+	}
+
+	if !queue.IsPlaying() {
+		go b.audioPlayer.PlayNext(s, guildID, audioConn, queue)
+	}
+
+	response := fmt.Sprintf("🎶 Queued %s from **%s**", songCount(queued), playlist.Name)
+	if skipped := len(playlist.Songs) - queued; skipped > 0 {
+		response += fmt.Sprintf("\n%s did not fit into the queue", songCount(skipped))
+	}
+
+	_, err = b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{
+		Content: &response,
+	})
+
+	metrics.RecordCommand("playlist_play", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistAddSlashCommand handles the /playlist_add slash command. It
+// adds the song found for the query option or, without a query, the song
+// that is currently playing.
+func (b *Bot) handlePlaylistAddSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, (*database.Playlist).CanEdit)
+	if !ok {
+		return err
+	}
+
+	var query string
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "query" {
+			query = strings.TrimSpace(option.StringValue())
+		}
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	// Without a query, add the song that is playing right now
+	if query == "" {
+		current := b.queueManager.GetQueue(i.GuildID).Current()
+		if current == nil {
+			return b.respondWithSlashError(s, i, "❌ Nothing is currently playing. Give a song name or URL to add instead.")
+		}
+
+		if err := b.playlists.AddSongToPlaylist(ctx, playlist.ID, playlistSong(current), actor); err != nil {
+			return b.respondPlaylistFailure(s, i, err, "Failed to add the song to the playlist")
+		}
+
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("✅ Added **%s** to **%s**", current.Title, playlist.Name),
+			},
+		})
+		metrics.RecordCommand("playlist_add", userID, err == nil, time.Since(startTime))
+		return err
+	}
+
+	if err := shareddiscord.ValidateInput(query, 500); err != nil {
+		return b.respondWithSlashError(s, i, fmt.Sprintf("❌ Invalid input: %s", err.Error()))
+	}
+
+	// Searching can take a while, so respond first
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "🔍 Searching...",
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	response := ""
+	song, err := b.extractSongInfo(query)
+	if err != nil {
+		response = fmt.Sprintf("❌ Could not find the requested song: %s", err.Error())
+	} else if err = b.playlists.AddSongToPlaylist(ctx, playlist.ID, playlistSong(song), actor); err != nil {
+		response = playlistFailureMessage(err, "❌ Failed to add the song to the playlist")
+	} else {
+		response = fmt.Sprintf("✅ Added **%s** to **%s**", song.Title, playlist.Name)
+	}
+
+	if _, editErr := b.Outbound().EditInteraction(i.Interaction, &discordgo.WebhookEdit{Content: &response}); editErr != nil {
+		return editErr
+	}
+
+	metrics.RecordCommand("playlist_add", userID, err == nil, time.Since(startTime))
+	return nil
+}
+
+// handlePlaylistRemoveSlashCommand handles the /playlist_remove slash command.
+func (b *Bot) handlePlaylistRemoveSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, (*database.Playlist).CanEdit)
+	if !ok {
+		return err
+	}
+
+	var number int
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "song_number" {
+			number = int(option.IntValue())
+		}
+	}
+	if number < 1 || number > len(playlist.Songs) {
+		return b.respondWithSlashError(s, i, fmt.Sprintf("❌ Song number must be between 1 and %d", len(playlist.Songs)))
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	removed := playlist.Songs[number-1]
+	if err := b.playlists.RemoveSongFromPlaylist(ctx, playlist.ID, number-1, actor); err != nil {
+		return b.respondPlaylistFailure(s, i, err, "Failed to remove the song from the playlist")
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("🗑️ Removed **%s** from **%s**", removed.Title, playlist.Name),
+		},
+	})
+
+	metrics.RecordCommand("playlist_remove", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistMoveSlashCommand handles the /playlist_move slash command.
+func (b *Bot) handlePlaylistMoveSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, (*database.Playlist).CanEdit)
+	if !ok {
+		return err
+	}
+
+	var from, to int
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "song_number":
+			from = int(option.IntValue())
+		case "position":
+			to = int(option.IntValue())
+		}
+	}
+	if from < 1 || from > len(playlist.Songs) || to < 1 || to > len(playlist.Songs) {
+		return b.respondWithSlashError(s, i, fmt.Sprintf("❌ Song numbers must be between 1 and %d", len(playlist.Songs)))
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	if err := b.playlists.MoveSongInPlaylist(ctx, playlist.ID, from-1, to-1, actor); err != nil {
+		return b.respondPlaylistFailure(s, i, err, "Failed to move the song")
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("↕️ Moved **%s** to position %d in **%s**", playlist.Songs[from-1].Title, to, playlist.Name),
+		},
+	})
+
+	metrics.RecordCommand("playlist_move", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistDeleteSlashCommand handles the /playlist_delete slash command.
+func (b *Bot) handlePlaylistDeleteSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, (*database.Playlist).CanManage)
+	if !ok {
+		return err
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	if err := b.playlists.DeletePlaylist(ctx, playlist.ID, actor); err != nil {
+		return b.respondPlaylistFailure(s, i, err, "Failed to delete playlist")
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("🗑️ Deleted playlist **%s**", playlist.Name),
+		},
+	})
+
+	metrics.RecordCommand("playlist_delete", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistShareSlashCommand handles the /playlist_share slash command.
+func (b *Bot) handlePlaylistShareSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, (*database.Playlist).CanManage)
+	if !ok {
+		return err
+	}
+
+	var visibility database.Visibility
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "visibility" {
+			visibility = database.Visibility(option.StringValue())
+		}
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	if err := b.playlists.SetPlaylistVisibility(ctx, playlist.ID, visibility, actor); err != nil {
+		return b.respondPlaylistFailure(s, i, err, "Failed to share the playlist")
+	}
+
+	var response string
+	switch visibility {
+	case database.VisibilityPublic:
+		response = fmt.Sprintf("🌍 **%s** is now public. Anyone can play or clone it with ID %d.", playlist.Name, playlist.ID)
+	case database.VisibilityGuild:
+		response = fmt.Sprintf("🏠 **%s** is now visible to everyone in this server.", playlist.Name)
+	default:
+		response = fmt.Sprintf("🔒 **%s** is now private.", playlist.Name)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: response,
+		},
+	})
+
+	metrics.RecordCommand("playlist_share", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistCollabSlashCommand handles the /playlist_collab slash
+// command. Collaborators may also use it to remove themselves.
+func (b *Bot) handlePlaylistCollabSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	var collaborator *discordgo.User
+	action := "add"
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "user":
+			collaborator = option.UserValue(nil)
+		case "action":
+			action = option.StringValue()
+		}
+	}
+	if collaborator == nil {
+		return b.respondWithSlashError(s, i, "Please choose a user")
+	}
+	if action == "add" && collaborator.Bot {
+		return b.respondWithSlashError(s, i, "❌ Bots can't be collaborators")
+	}
+
+	actor := b.playlistActor(s, i)
+	allowed := (*database.Playlist).CanManage
+	if action == "remove" && collaborator.ID == userID {
+		allowed = (*database.Playlist).CanEdit
+	}
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, allowed)
+	if !ok {
+		return err
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	var response string
+	if action == "remove" {
+		if err := b.playlists.RemovePlaylistCollaborator(ctx, playlist.ID, collaborator.ID, actor); err != nil {
+			if dberrors.IsErrorType(err, dberrors.ErrorTypeNotFound) {
+				return b.respondWithSlashError(s, i, fmt.Sprintf("❌ <@%s> is not a collaborator of **%s**", collaborator.ID, playlist.Name))
+			}
+			return b.respondPlaylistFailure(s, i, err, "Failed to remove the collaborator")
+		}
+		response = fmt.Sprintf("👋 <@%s> can no longer edit **%s**", collaborator.ID, playlist.Name)
+	} else {
+		if err := b.playlists.AddPlaylistCollaborator(ctx, playlist.ID, collaborator.ID, actor); err != nil {
+			return b.respondPlaylistFailure(s, i, err, "Failed to add the collaborator")
+		}
+		response = fmt.Sprintf("👥 <@%s> can now add, remove and reorder songs in **%s**", collaborator.ID, playlist.Name)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         response,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+
+	metrics.RecordCommand("playlist_collab", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// handlePlaylistCloneSlashCommand handles the /playlist_clone slash command.
+func (b *Bot) handlePlaylistCloneSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if b.playlists == nil {
+		return b.respondPlaylistNotAvailable(s, i)
+	}
+
+	startTime := time.Now()
+	userID := getUserID(i)
+
+	actor := b.playlistActor(s, i)
+	playlist, ok, err := b.playlistFromOptions(s, i, actor, nil)
+	if !ok {
+		return err
+	}
+
+	var name string
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "name" {
+			name = strings.TrimSpace(option.StringValue())
+		}
+	}
+	if len(name) > 50 {
+		return b.respondWithSlashError(s, i, "Playlist name must be 50 characters or less")
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	cloneID, err := b.playlists.ClonePlaylist(ctx, playlist.ID, name, actor)
+	if err != nil {
+		return b.respondPlaylistFailure(s, i, err, "Failed to clone the playlist")
+	}
+	if name == "" {
+		name = playlist.Name
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("✅ Copied %s from **%s** into your playlist **%s** (ID: %d)",
+				songCount(len(playlist.Songs)), playlist.Name, name, cloneID),
+		},
+	})
+
+	metrics.RecordCommand("playlist_clone", userID, err == nil, time.Since(startTime))
+	return err
+}
+
+// playlistFromOptions loads the playlist named by the playlist_id option for
+// an actor. Playlists the actor cannot see are not found, and if allowed is
+// set the actor also needs that permission, such as
+// (*database.Playlist).CanEdit. The database checks permissions again when
+// the playlist is changed. If the playlist cannot be used, the user has been
+// told why and ok is false; err is the error of that response.
+func (b *Bot) playlistFromOptions(s *discordgo.Session, i *discordgo.InteractionCreate, actor database.Actor,
+	allowed func(*database.Playlist, database.Actor) bool) (playlist *database.Playlist, ok bool, err error) {
+	var playlistID int
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "playlist_id" {
+			playlistID = int(option.IntValue())
+		}
+	}
+
+	ctx, cancel := b.requestContext()
+	defer cancel()
+
+	playlist, err = b.playlists.ViewPlaylist(ctx, playlistID, actor)
+	if err != nil {
+		return nil, false, b.respondPlaylistFailure(s, i, err, "Failed to load the playlist")
+	}
+	if allowed != nil && !allowed(playlist, actor) {
+		return nil, false, b.respondWithSlashError(s, i, "❌ You don't have permission to change this playlist")
+	}
+
+	return playlist, true, nil
+}
+
+// playlistActor returns the user of an interaction as a playlist actor.
+// Members with the Manage Server permission or a role named DJ are the DJs
+// of their server and manage its server playlists.
+func (b *Bot) playlistActor(s *discordgo.Session, i *discordgo.InteractionCreate) database.Actor {
+	actor := database.Actor{UserID: getUserID(i), GuildID: i.GuildID}
+	if i.Member == nil {
+		return actor
+	}
+
+	if i.Member.Permissions&discordgo.PermissionManageServer != 0 {
+		actor.DJ = true
+		return actor
+	}
+
+	for _, roleID := range i.Member.Roles {
+		role, err := s.State.Role(i.GuildID, roleID)
+		if err == nil && strings.EqualFold(role.Name, djRoleName) {
+			actor.DJ = true
+			break
+		}
+	}
+
+	return actor
+}
+
+// respondPlaylistFailure tells the user why a playlist operation failed.
+func (b *Bot) respondPlaylistFailure(s *discordgo.Session, i *discordgo.InteractionCreate, err error, fallback string) error {
+	return b.respondWithSlashError(s, i, playlistFailureMessage(err, fallback))
+}
+
+// playlistFailureMessage returns the message for a failed playlist operation.
+// Unexpected errors are logged and reported with the fallback message.
+func playlistFailureMessage(err error, fallback string) string {
+	switch {
+	case dberrors.IsErrorType(err, dberrors.ErrorTypeNotFound):
+		return "❌ Playlist not found. Use `/playlist_list` to see your playlists."
+	case dberrors.IsErrorType(err, dberrors.ErrorTypePermission):
+		return "❌ You don't have permission to do that: " + err.(*dberrors.BotError).Message
+	case dberrors.IsErrorType(err, dberrors.ErrorTypeValidation):
+		return "❌ " + err.(*dberrors.BotError).Message
+	}
+
+	logger := withComponent("playlist")
+	logging.LogError(logger, err, fallback)
+	return fallback
+}
+
+// playlistIcon marks server playlists and playlists the user collaborates on.
+func playlistIcon(playlist *database.Playlist, userID string) string {
+	switch {
+	case playlist.Server:
+		return "📻 "
+	case playlist.IsCollaborator(userID):
+		return "👥 "
+	}
+	return ""
+}
+
+// requestContext returns a context for a database request.
+func (b *Bot) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), b.GetConfig().RequestTimeout.Std())
+}
+
+// playlistSong converts a song into a playlist song.
+func playlistSong(song *Song) database.Song {
+	return database.Song{
+		Title:      song.Title,
+		URL:        song.URL,
+		WebpageURL: song.WebpageURL,
+		Duration:   song.Duration,
+		Source:     song.Source,
+	}
+}
+
+// queueSong converts a playlist song into a song for the queue. Stream URLs
+// expire, so the song is played from its web page URL when it has one.
+func queueSong(song database.Song, requesterID, requesterName string) *Song {
+	url := song.URL
+	if song.WebpageURL != "" {
+		url = song.WebpageURL
+	}
+
+	return &Song{
+		Title:         song.Title,
+		URL:           url,
+		WebpageURL:    song.WebpageURL,
+		Duration:      song.Duration,
+		Source:        song.Source,
+		RequesterID:   requesterID,
+		RequesterName: requesterName,
+	}
+}
+
+// songLink returns the song title, linked to its web page if known.
+func songLink(song database.Song) string {
+	if song.WebpageURL == "" {
+		return song.Title
+	}
+	return fmt.Sprintf("[%s](%s)", song.Title, song.WebpageURL)
+}
+
+// formatSongDuration formats a song length as " (m:ss)", or "" if unknown.
+func formatSongDuration(seconds *int) string {
+	if seconds == nil || *seconds <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d:%02d)", *seconds/60, *seconds%60)
+}
+
+// songCount formats a number of songs, e.g. "1 song" or "3 songs".
+func songCount(n int) string {
+	if n == 1 {
+		return "1 song"
+	}
+	return fmt.Sprintf("%d songs", n)
+}
@@ -0,0 +1,85 @@
+// Package discord provides the Music bot's Discord status.
+package discord
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/presence"
+)
+
+// playCounter counts the songs started since local midnight.
+type playCounter struct {
+	day   string
+	count int
+	mutex sync.Mutex
+}
+
+// Add counts a started song.
+func (c *playCounter) Add() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	if c.day != today {
+		c.day = today
+		c.count = 0
+	}
+	c.count++
+}
+
+// Today returns the number of songs started today.
+func (c *playCounter) Today() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.day != time.Now().Format("2006-01-02") {
+		return 0
+	}
+	return c.count
+}
+
+// presenceValues adds the music template values to the status rotation.
+func (b *Bot) presenceValues(values presence.Values) {
+	values["SongsToday"] = b.songsPlayed.Today()
+
+	queued, playing := 0, 0
+	for _, queue := range b.queueManager.Queues() {
+		queued += queue.Size()
+		if queue.Current() != nil {
+			playing++
+		}
+	}
+	values["Queued"] = queued
+	values["PlayingGuilds"] = playing
+}
+
+// nowPlaying shows the current track while the bot is in a single guild, where
+// the status can only refer to one queue.
+func (b *Bot) nowPlaying() *presence.Activity {
+	if !b.nowPlayingEnabled.Load() {
+		return nil
+	}
+
+	state := b.GetSession().State
+	state.RLock()
+	if len(state.Guilds) != 1 {
+		state.RUnlock()
+		return nil
+	}
+	guildID := state.Guilds[0].ID
+	state.RUnlock()
+
+	queue, exists := b.queueManager.Queues()[guildID]
+	if !exists {
+		return nil
+	}
+
+	song := queue.Current()
+	if song == nil || queue.IsPaused() {
+		return nil
+	}
+
+	return &presence.Activity{Type: discordgo.ActivityTypeListening, Text: song.Title}
+}
@@ -1,5 +1,5 @@
-// Package main provides music queue functionality.
-package main
+// Package discord provides music queue functionality.
+package discord
 
 import (
 	"sync"
@@ -11,6 +11,7 @@ type Song struct {
 	URL           string `json:"url"`
 	WebpageURL    string `json:"webpage_url"`
 	Duration      *int   `json:"duration,omitempty"`
+	Source        string `json:"source,omitempty"`
 	RequesterID   string `json:"requester_id"`
 	RequesterName string `json:"requester_name"`
 }
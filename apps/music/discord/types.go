@@ -1,5 +1,5 @@
-// Package main provides types for the Music bot.
-package main
+// Package discord provides types for the Music bot.
+package discord
 
 import (
 	"context"
@@ -8,7 +8,6 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/sawyer/go-discord-bots/pkg/errors"
-	"github.com/sawyer/go-discord-bots/pkg/logging"
 )
 
 // Queue represents a music queue for a guild - uses MusicQueue from queue.go
@@ -45,6 +44,18 @@ func (qm *QueueManager) GetQueue(guildID string) *Queue {
 	return queue
 }
 
+// Queues returns a snapshot of the existing queues keyed by guild ID.
+func (qm *QueueManager) Queues() map[string]*Queue {
+	qm.mutex.RLock()
+	defer qm.mutex.RUnlock()
+
+	queues := make(map[string]*Queue, len(qm.queues))
+	for guildID, queue := range qm.queues {
+		queues[guildID] = queue
+	}
+	return queues
+}
+
 // ClearQueue clears a guild's queue.
 func (qm *QueueManager) ClearQueue(guildID string) {
 	qm.mutex.Lock()
@@ -69,17 +80,21 @@ func (qm *QueueManager) Cleanup() {
 
 // AudioPlayer manages audio playback for multiple guilds.
 type AudioPlayer struct {
-	volumes     map[string]float64
-	connections map[string]*discordgo.VoiceConnection
-	enhanced    *EnhancedAudioPlayer
-	mutex       sync.RWMutex
+	volumes       map[string]float64
+	defaultVolume func(guildID string) float64
+	connections   map[string]*discordgo.VoiceConnection
+	enhanced      *EnhancedAudioPlayer
+	onSongStart   func(guildID string, song *Song)
+	onSongEnd     func(guildID string, song *Song, skipped bool)
+	mutex         sync.RWMutex
 }
 
 // NewAudioPlayer creates a new audio player.
 func NewAudioPlayer() *AudioPlayer {
 	ap := &AudioPlayer{
-		volumes:     make(map[string]float64),
-		connections: make(map[string]*discordgo.VoiceConnection),
+		volumes:       make(map[string]float64),
+		defaultVolume: func(string) float64 { return 0.5 },
+		connections:   make(map[string]*discordgo.VoiceConnection),
 	}
 	// Create enhanced player after base player is created
 	ap.enhanced = &EnhancedAudioPlayer{
@@ -101,7 +116,7 @@ func (ap *AudioPlayer) GetConnection(session *discordgo.Session, guildID, channe
 		}
 		// Different channel, disconnect and reconnect
 		if err := conn.Disconnect(); err != nil {
-			logger := logging.WithComponent("audio-player")
+			logger := withComponent("audio-player")
 			logger.Error("Failed to disconnect from voice channel", "error", err)
 		}
 		delete(ap.connections, guildID)
@@ -121,7 +136,7 @@ func (ap *AudioPlayer) GetConnection(session *discordgo.Session, guildID, channe
 	for {
 		select {
 		case <-timeout:
-			logger := logging.WithComponent("audio-player")
+			logger := withComponent("audio-player")
 			logger.Error("Voice connection timeout", "guild", guildID, "channel", channelID)
 			if err := conn.Disconnect(); err != nil {
 				logger.Error("Failed to disconnect after timeout", "error", err)
@@ -141,7 +156,7 @@ func (ap *AudioPlayer) PlayNext(session *discordgo.Session, guildID string, conn
 	ap.mutex.Lock()
 	defer ap.mutex.Unlock()
 
-	logger := logging.WithComponent("audio-player")
+	logger := withComponent("audio-player")
 
 	// Check if queue is empty
 	if queue.IsEmpty() {
@@ -167,10 +182,17 @@ func (ap *AudioPlayer) PlayNext(session *discordgo.Session, guildID string, conn
 	logger.Info("Playing next song", "guild", guildID, "song", nextSong.Title)
 
 	// Start playing the song using enhanced audio player
+	onSongStart := ap.onSongStart
 	go func() {
+		// Called outside the mutex; it may write to the database
+		if onSongStart != nil {
+			onSongStart(guildID, nextSong)
+		}
+
 		// Use a context without timeout for audio streaming since songs can be long
 		ctx := context.Background()
 		err := ap.enhanced.PlaySong(ctx, guildID, nextSong, connection)
+		ap.songEnded(guildID, nextSong, err == nil && queue.ShouldSkip())
 		if err != nil {
 			logger.Error("Failed to play song", "error", err, "song", nextSong.Title)
 			// Clear current song and try next song on error
@@ -195,6 +217,34 @@ func (ap *AudioPlayer) PlayNext(session *discordgo.Session, guildID string, conn
 	}()
 }
 
+// OnSongStart registers a function called whenever a song starts playing.
+func (ap *AudioPlayer) OnSongStart(fn func(guildID string, song *Song)) {
+	ap.mutex.Lock()
+	defer ap.mutex.Unlock()
+
+	ap.onSongStart = fn
+}
+
+// OnSongEnd registers a function called whenever a song stops playing, with
+// whether it was skipped.
+func (ap *AudioPlayer) OnSongEnd(fn func(guildID string, song *Song, skipped bool)) {
+	ap.mutex.Lock()
+	defer ap.mutex.Unlock()
+
+	ap.onSongEnd = fn
+}
+
+// songEnded calls the OnSongEnd function, if any.
+func (ap *AudioPlayer) songEnded(guildID string, song *Song, skipped bool) {
+	ap.mutex.RLock()
+	onSongEnd := ap.onSongEnd
+	ap.mutex.RUnlock()
+
+	if onSongEnd != nil {
+		onSongEnd(guildID, song, skipped)
+	}
+}
+
 // Disconnect disconnects from voice channel.
 func (ap *AudioPlayer) Disconnect(guildID string) {
 	ap.mutex.Lock()
@@ -202,7 +252,7 @@ func (ap *AudioPlayer) Disconnect(guildID string) {
 
 	if conn, exists := ap.connections[guildID]; exists {
 		if err := conn.Disconnect(); err != nil {
-			logger := logging.WithComponent("audio-player")
+			logger := withComponent("audio-player")
 			logger.Error("Failed to disconnect from voice channel", "error", err)
 		}
 		delete(ap.connections, guildID)
@@ -217,7 +267,16 @@ func (ap *AudioPlayer) GetVolume(guildID string) float64 {
 	if volume, exists := ap.volumes[guildID]; exists {
 		return volume
 	}
-	return 0.5 // Default volume
+	return ap.defaultVolume(guildID)
+}
+
+// SetDefaultVolume sets the function returning the volume of guilds without
+// an explicit volume, usually the guild's configured volume_level.
+func (ap *AudioPlayer) SetDefaultVolume(volume func(guildID string) float64) {
+	ap.mutex.Lock()
+	defer ap.mutex.Unlock()
+
+	ap.defaultVolume = volume
 }
 
 // SetVolume sets the volume for a guild.
@@ -241,47 +300,10 @@ func (ap *AudioPlayer) Cleanup() {
 	// Disconnect all voice connections
 	for guildID, conn := range ap.connections {
 		if err := conn.Disconnect(); err != nil {
-			logger := logging.WithComponent("audio-player")
+			logger := withComponent("audio-player")
 			logger.Error("Failed to disconnect from voice channel", "error", err, "guild_id", guildID)
 		}
 		delete(ap.connections, guildID)
 	}
 	ap.volumes = make(map[string]float64)
 }
-
-// Playlist represents a music playlist.
-type Playlist struct {
-	ID      int     `json:"id"`
-	Name    string  `json:"name"`
-	OwnerID string  `json:"owner_id"`
-	GuildID string  `json:"guild_id"`
-	Songs   []*Song `json:"songs"`
-}
-
-// Database provides playlist storage (stub implementation).
-type Database struct {
-	// This would contain actual database connections
-}
-
-// NewDatabase creates a new database connection.
-func NewDatabase(url string) (*Database, error) {
-	// This would create a real database connection
-	return &Database{}, nil
-}
-
-// CreatePlaylist creates a new playlist.
-func (db *Database) CreatePlaylist(userID, guildID, name string) (int, error) {
-	// This would create a playlist in the database
-	return 1, nil // Mock playlist ID
-}
-
-// GetUserPlaylists gets playlists for a user.
-func (db *Database) GetUserPlaylists(userID, guildID string) ([]*Playlist, error) {
-	// This would fetch playlists from the database
-	return []*Playlist{}, nil
-}
-
-// Close closes the database connection.
-func (db *Database) Close() error {
-	return nil
-}
@@ -0,0 +1,83 @@
+package playlistfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonFormatName identifies files in the JSON format.
+const jsonFormatName = "go-discord-bots/playlist"
+
+// jsonVersion is the version of the JSON format written.
+const jsonVersion = 1
+
+// jsonPlaylist is a playlist in the JSON format.
+type jsonPlaylist struct {
+	Format  string      `json:"format"`
+	Version int         `json:"version"`
+	Name    string      `json:"name"`
+	Tracks  []jsonTrack `json:"tracks"`
+}
+
+// jsonTrack is a track in the JSON format.
+type jsonTrack struct {
+	Title    string `json:"title"`
+	Artist   string `json:"artist,omitempty"`
+	Location string `json:"location"`
+	Duration *int   `json:"duration,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// readJSON parses a file in the JSON format.
+func readJSON(r io.Reader) (*Playlist, error) {
+	var file jsonPlaylist
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to read JSON playlist: %w", err)
+	}
+
+	if file.Format != jsonFormatName {
+		return nil, fmt.Errorf("not a playlist exported by the bot: format is %q, expected %q", file.Format, jsonFormatName)
+	}
+	if file.Version > jsonVersion {
+		return nil, fmt.Errorf("playlist format version %d is newer than this bot supports (%d)", file.Version, jsonVersion)
+	}
+
+	playlist := &Playlist{Name: file.Name}
+	for n, entry := range file.Tracks {
+		playlist.Tracks = append(playlist.Tracks, Track{
+			Title:    entry.Title,
+			Artist:   entry.Artist,
+			Location: entry.Location,
+			Duration: entry.Duration,
+			Source:   entry.Source,
+			Line:     n + 1,
+		})
+	}
+
+	return playlist, nil
+}
+
+// writeJSON writes a file in the JSON format.
+func writeJSON(w io.Writer, playlist *Playlist) error {
+	file := jsonPlaylist{
+		Format:  jsonFormatName,
+		Version: jsonVersion,
+		Name:    playlist.Name,
+		Tracks:  make([]jsonTrack, 0, len(playlist.Tracks)),
+	}
+
+	for _, track := range playlist.Tracks {
+		file.Tracks = append(file.Tracks, jsonTrack{
+			Title:    track.Title,
+			Artist:   track.Artist,
+			Location: track.Location,
+			Duration: track.Duration,
+			Source:   track.Source,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
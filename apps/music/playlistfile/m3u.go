@@ -0,0 +1,122 @@
+package playlistfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readM3U parses a plain or extended M3U file.
+func readM3U(r io.Reader) (*Playlist, error) {
+	playlist := &Playlist{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pending Track  // #EXTINF data for the next location
+	var artist *string // #EXTART value for the next location
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "#PLAYLIST:"):
+			playlist.Name = strings.TrimSpace(strings.TrimPrefix(text, "#PLAYLIST:"))
+		case strings.HasPrefix(text, "#EXTINF:"):
+			info := parseExtinf(strings.TrimPrefix(text, "#EXTINF:"))
+			pending.Title, pending.Duration = info.Title, info.Duration
+		case strings.HasPrefix(text, "#EXTART:"):
+			value := strings.TrimSpace(strings.TrimPrefix(text, "#EXTART:"))
+			artist = &value
+		case strings.HasPrefix(text, "#"):
+			// #EXTM3U and directives of other players
+			continue
+		default:
+			track := pending
+			if artist != nil {
+				// The file names the artist, so the title is only
+				// prefixed with it for players that ignore #EXTART.
+				track.Artist = *artist
+				if track.Artist != "" {
+					track.Title = strings.TrimPrefix(track.Title, track.Artist+" - ")
+				}
+			} else if name, title, ok := strings.Cut(track.Title, " - "); ok {
+				track.Artist = strings.TrimSpace(name)
+				track.Title = strings.TrimSpace(title)
+			}
+			track.Location = text
+			track.Line = line
+			playlist.Tracks = append(playlist.Tracks, track)
+			pending = Track{}
+			artist = nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read M3U file: %w", err)
+	}
+
+	return playlist, nil
+}
+
+// parseExtinf parses the value of an #EXTINF line,
+// "<seconds> [attributes],<title>". The title is returned as written;
+// readM3U splits off an "<artist> - " prefix.
+func parseExtinf(value string) Track {
+	var track Track
+
+	info, title, found := strings.Cut(value, ",")
+	if !found {
+		title = ""
+	}
+
+	fields := strings.Fields(info)
+	if len(fields) > 0 {
+		if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil && seconds > 0 {
+			duration := int(seconds)
+			track.Duration = &duration
+		}
+	}
+
+	track.Title = strings.TrimSpace(title)
+
+	return track
+}
+
+// writeM3U writes an extended M3U file. Every track gets an #EXTART
+// line, empty when it has no artist, so that readM3U does not split a
+// title containing " - " into artist and title.
+func writeM3U(w io.Writer, playlist *Playlist) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "#EXTM3U")
+	if playlist.Name != "" {
+		fmt.Fprintf(bw, "#PLAYLIST:%s\n", singleLine(playlist.Name))
+	}
+
+	for _, track := range playlist.Tracks {
+		duration := -1
+		if track.Duration != nil {
+			duration = *track.Duration
+		}
+
+		title := singleLine(track.Title)
+		if track.Artist != "" {
+			title = singleLine(track.Artist) + " - " + title
+		}
+
+		fmt.Fprintf(bw, "#EXTINF:%d,%s\n", duration, title)
+		fmt.Fprintf(bw, "#EXTART:%s\n", singleLine(track.Artist))
+		fmt.Fprintln(bw, singleLine(track.Location))
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,123 @@
+// Package playlistfile reads and writes playlists in the M3U, XSPF and JSON
+// formats, so playlists can move between the music bot and other players.
+//
+// M3U files are read with their #EXTINF titles and durations and written as
+// UTF-8 (M3U8). XSPF is the XML Shareable Playlist Format. The JSON format is
+// the bot's own and keeps every field the bot stores.
+package playlistfile
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Format is a playlist file format.
+type Format string
+
+const (
+	// M3U is the extended M3U format, written as UTF-8 (M3U8).
+	M3U Format = "m3u"
+	// XSPF is the XML Shareable Playlist Format.
+	XSPF Format = "xspf"
+	// JSON is the music bot's own format.
+	JSON Format = "json"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{M3U, XSPF, JSON}
+
+// Playlist is the content of a playlist file.
+type Playlist struct {
+	Name   string
+	Tracks []Track
+}
+
+// Track is an entry of a playlist file.
+type Track struct {
+	Title  string
+	Artist string
+	// Location is a URL or, in files from other players, often a file path.
+	Location string
+	// Duration is the length in seconds, if known.
+	Duration *int
+	// Source is the site the track was found on, such as "Youtube".
+	Source string
+	// Line is where the track was read from: the line of its location in
+	// M3U files and its 1-based position in XSPF and JSON files.
+	Line int
+}
+
+// ParseFormat returns the format with the given name. "m3u8" is accepted for
+// M3U.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "m3u", "m3u8":
+		return M3U, nil
+	case "xspf":
+		return XSPF, nil
+	case "json":
+		return JSON, nil
+	}
+	return "", fmt.Errorf("unknown playlist format %q", name)
+}
+
+// FormatForFile returns the format of a file by its extension.
+func FormatForFile(filename string) (Format, error) {
+	ext := strings.TrimPrefix(path.Ext(filename), ".")
+	if ext == "" {
+		return "", fmt.Errorf("%s has no file extension; use .m3u, .m3u8, .xspf or .json", filename)
+	}
+	return ParseFormat(ext)
+}
+
+// Extension returns the file extension written for the format.
+func (f Format) Extension() string {
+	if f == M3U {
+		return ".m3u8"
+	}
+	return "." + string(f)
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case M3U:
+		return "audio/x-mpegurl"
+	case XSPF:
+		return "application/xspf+xml"
+	}
+	return "application/json"
+}
+
+// Read parses a playlist file.
+func Read(format Format, r io.Reader) (*Playlist, error) {
+	switch format {
+	case M3U:
+		return readM3U(r)
+	case XSPF:
+		return readXSPF(r)
+	case JSON:
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("unknown playlist format %q", format)
+}
+
+// Write writes a playlist file.
+func Write(format Format, w io.Writer, playlist *Playlist) error {
+	switch format {
+	case M3U:
+		return writeM3U(w, playlist)
+	case XSPF:
+		return writeXSPF(w, playlist)
+	case JSON:
+		return writeJSON(w, playlist)
+	}
+	return fmt.Errorf("unknown playlist format %q", format)
+}
+
+// singleLine replaces line breaks, which would end an M3U entry early.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,75 @@
+package playlistfile_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sawyer/go-discord-bots/apps/music/playlistfile"
+)
+
+func seconds(n int) *int { return &n }
+
+func TestRoundTrip(t *testing.T) {
+	tracks := []struct {
+		name  string
+		track playlistfile.Track
+	}{
+		{"artist and title", playlistfile.Track{Title: "Song", Artist: "Band", Location: "https://example.com/a", Duration: seconds(215)}},
+		{"no artist, separator in title", playlistfile.Track{Title: "Live - Remastered", Location: "https://example.com/b", Duration: seconds(60)}},
+		{"separator in artist and title", playlistfile.Track{Title: "Part 1 - Intro", Artist: "A - B", Location: "https://example.com/c", Duration: seconds(5)}},
+		{"title repeats artist", playlistfile.Track{Title: "Band - Song", Artist: "Band", Location: "https://example.com/d", Duration: seconds(1)}},
+		{"no duration", playlistfile.Track{Title: "Stream", Location: "https://example.com/e"}},
+	}
+
+	for _, format := range playlistfile.Formats {
+		for _, tc := range tracks {
+			t.Run(string(format)+"/"+tc.name, func(t *testing.T) {
+				in := &playlistfile.Playlist{Name: "Road trip", Tracks: []playlistfile.Track{tc.track}}
+
+				var buf bytes.Buffer
+				if err := playlistfile.Write(format, &buf, in); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				out, err := playlistfile.Read(format, &buf)
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+
+				if out.Name != in.Name {
+					t.Errorf("name = %q, want %q", out.Name, in.Name)
+				}
+				if len(out.Tracks) != 1 {
+					t.Fatalf("got %d tracks, want 1", len(out.Tracks))
+				}
+				got, want := out.Tracks[0], tc.track
+				if got.Title != want.Title || got.Artist != want.Artist || got.Location != want.Location {
+					t.Errorf("track = %q by %q at %q, want %q by %q at %q",
+						got.Title, got.Artist, got.Location, want.Title, want.Artist, want.Location)
+				}
+				if (got.Duration == nil) != (want.Duration == nil) ||
+					(got.Duration != nil && *got.Duration != *want.Duration) {
+					t.Errorf("duration = %v, want %v", got.Duration, want.Duration)
+				}
+			})
+		}
+	}
+}
+
+func TestReadM3UWithoutArtistDirective(t *testing.T) {
+	file := "#EXTM3U\n#EXTINF:120,Band - Song\nsong.mp3\n#EXTINF:-1,Untitled\nother.mp3\n"
+
+	playlist, err := playlistfile.Read(playlistfile.M3U, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(playlist.Tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(playlist.Tracks))
+	}
+	if got := playlist.Tracks[0]; got.Artist != "Band" || got.Title != "Song" {
+		t.Errorf("track 1 = %q by %q, want \"Song\" by \"Band\"", got.Title, got.Artist)
+	}
+	if got := playlist.Tracks[1]; got.Artist != "" || got.Title != "Untitled" || got.Duration != nil {
+		t.Errorf("track 2 = %q by %q (%v), want \"Untitled\" with no artist or duration", got.Title, got.Artist, got.Duration)
+	}
+}
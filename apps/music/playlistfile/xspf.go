@@ -0,0 +1,98 @@
+package playlistfile
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xspfNamespace is the XML namespace of XSPF version 1.
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+// xspfPlaylist is the playlist element of an XSPF file.
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Xmlns   string      `xml:"xmlns,attr,omitempty"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack is a track element of an XSPF file.
+type xspfTrack struct {
+	Locations []string `xml:"location"`
+	Title     string   `xml:"title,omitempty"`
+	Creator   string   `xml:"creator,omitempty"`
+	// Duration is in milliseconds.
+	Duration int `xml:"duration,omitempty"`
+	// Info links to a page about the track.
+	Info string `xml:"info,omitempty"`
+}
+
+// readXSPF parses an XSPF file.
+func readXSPF(r io.Reader) (*Playlist, error) {
+	var file xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to read XSPF file: %w", err)
+	}
+
+	playlist := &Playlist{Name: strings.TrimSpace(file.Title)}
+	for n, entry := range file.Tracks {
+		track := Track{
+			Title:  strings.TrimSpace(entry.Title),
+			Artist: strings.TrimSpace(entry.Creator),
+			Line:   n + 1,
+		}
+		if len(entry.Locations) > 0 {
+			track.Location = strings.TrimSpace(entry.Locations[0])
+		}
+		if track.Location == "" {
+			track.Location = strings.TrimSpace(entry.Info)
+		}
+		if entry.Duration > 0 {
+			seconds := entry.Duration / 1000
+			track.Duration = &seconds
+		}
+		playlist.Tracks = append(playlist.Tracks, track)
+	}
+
+	return playlist, nil
+}
+
+// writeXSPF writes an XSPF file.
+func writeXSPF(w io.Writer, playlist *Playlist) error {
+	file := xspfPlaylist{
+		Xmlns:   xspfNamespace,
+		Version: "1",
+		Title:   playlist.Name,
+		Tracks:  make([]xspfTrack, 0, len(playlist.Tracks)),
+	}
+
+	for _, track := range playlist.Tracks {
+		entry := xspfTrack{
+			Title:   track.Title,
+			Creator: track.Artist,
+		}
+		if track.Location != "" {
+			entry.Locations = []string{track.Location}
+		}
+		if track.Duration != nil {
+			entry.Duration = *track.Duration * 1000
+		}
+		file.Tracks = append(file.Tracks, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return fmt.Errorf("failed to write XSPF file: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
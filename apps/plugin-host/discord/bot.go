@@ -0,0 +1,81 @@
+// Package discord provides the plugin host, a Discord bot whose commands are
+// provided by out-of-process plugins.
+package discord
+
+import (
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/plugin"
+)
+
+// Bot owns the Discord gateway connection and runs the configured plugins.
+type Bot struct {
+	*shareddiscord.BaseBot
+	host  *plugin.Host
+	audit *audit.Recorder
+}
+
+// NewBot creates a new plugin host bot.
+func NewBot(cfg *config.Config) (*Bot, error) {
+	// Plugins only receive interactions and subscribed events, so disable
+	// BaseBot's prefix commands to keep it from answering arbitrary messages.
+	cfg.CommandPrefix = "/plugins_disabled"
+
+	baseBot, err := shareddiscord.NewBaseBot(cfg)
+	if err != nil {
+		return nil, errors.NewConfigError("failed to create base bot", err)
+	}
+
+	recorder, err := audit.OpenRecorder(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bot{
+		BaseBot: baseBot,
+		host:    plugin.NewHost(baseBot.GetSession(), baseBot.Outbound(), cfg, baseBot.Guilds(), recorder),
+		audit:   recorder,
+	}, nil
+}
+
+// Start connects to Discord and launches the plugins.
+func (b *Bot) Start() error {
+	if err := b.BaseBot.Start(); err != nil {
+		return err
+	}
+
+	b.audit.Start()
+
+	if err := b.host.Start(); err != nil {
+		b.audit.Stop()
+		_ = b.BaseBot.Stop()
+		return err
+	}
+
+	logger := withComponent("plugin-host")
+	logger.Info("Plugin host started successfully", "plugins", len(b.host.Plugins()))
+
+	return nil
+}
+
+// Stop shuts the plugins down and disconnects from Discord.
+func (b *Bot) Stop() error {
+	b.host.Stop()
+	b.audit.Stop()
+	return b.BaseBot.Stop()
+}
+
+// ApplyConfig applies hot-reloadable settings from a configuration change.
+func (b *Bot) ApplyConfig(change *config.Change) {
+	b.Guilds().Update(change.New)
+
+	if change.Has("presence") || change.Has("presence_interval") {
+		if err := b.Presence().Update(change.New); err != nil {
+			logger := withComponent("plugin-host")
+			logging.LogError(logger, err, "Failed to apply presence configuration")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package discord
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+// Run runs the plugin host until ctx is cancelled, applying changes to
+// cfg.ConfigFile through ApplyConfig. The shared logger must be initialized;
+// metrics are initialized here unless another bot in the process already did.
+func Run(ctx context.Context, cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	metrics.Initialize(cfg.BotName, string(cfg.BotType))
+
+	bot, err := NewBot(cfg)
+	if err != nil {
+		return err
+	}
+
+	watcher := config.NewWatcher(cfg.ConfigFile, config.BotTypePlugins, cfg)
+	return shareddiscord.RunWithWatcher(ctx, bot, watcher, cfg.ShutdownTimeout.Std())
+}
+
+// withComponent returns the plugin host's logger with a component field.
+func withComponent(component string) *slog.Logger {
+	return logging.BotComponent(string(config.BotTypePlugins), component)
+}
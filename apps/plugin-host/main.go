@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sawyer/go-discord-bots/apps/plugin-host/discord"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+func main() {
+	// Load environment variables from .env file
+	if err := config.LoadEnvFile(".env"); err != nil {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+
+	// Load configuration: defaults < config file < environment < flags
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := config.LoadWith(config.BotTypePlugins, flags.Options())
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if flags.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Initialize logging
+	logging.InitializeLogger(cfg.LogLevel, cfg.JSONLogging)
+	defer shareddiscord.WatchLogLevel(cfg)()
+	logger := logging.WithComponent("main")
+
+	logger.Info("Starting Plugin Host", "version", "1.0.0")
+
+	// Run until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := discord.Run(ctx, cfg); err != nil {
+		logging.LogError(logger, err, "Plugin Host stopped with an error")
+		os.Exit(1)
+	}
+
+	logger.Info("Plugin Host shutdown completed successfully")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditDBFlag      string
+	auditBotFlag     string
+	auditGuildFlag   string
+	auditUserFlag    string
+	auditCommandFlag string
+	auditSinceFlag   string
+	auditUntilFlag   string
+	auditLimitFlag   int
+	auditJSONFlag    bool
+)
+
+// newAuditCmd returns the command for querying the command audit log.
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Query the command audit log",
+		Long:  "Show recorded command invocations from all bots, newest first",
+		Args:  cobra.NoArgs,
+		RunE:  runAudit,
+	}
+
+	cmd.Flags().StringVar(&auditDBFlag, "db", config.GetString("AUDIT_DATABASE_URL", "audit.db"), "Audit database path")
+	cmd.Flags().StringVar(&auditBotFlag, "bot", "", "Only show commands handled by this bot")
+	cmd.Flags().StringVar(&auditGuildFlag, "guild", "", "Only show commands used in this guild")
+	cmd.Flags().StringVar(&auditUserFlag, "user", "", "Only show commands used by this user ID")
+	cmd.Flags().StringVar(&auditCommandFlag, "command", "", "Only show this command")
+	cmd.Flags().StringVar(&auditSinceFlag, "since", "24h", "Start of the time window (duration ago or RFC3339 time)")
+	cmd.Flags().StringVar(&auditUntilFlag, "until", "", "End of the time window (duration ago or RFC3339 time)")
+	cmd.Flags().IntVar(&auditLimitFlag, "limit", 50, "Maximum number of entries to show (0 for no limit)")
+	cmd.Flags().BoolVar(&auditJSONFlag, "json", false, "Print entries as JSON lines")
+
+	return cmd
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	filter := audit.Filter{
+		Bot:     auditBotFlag,
+		GuildID: auditGuildFlag,
+		UserID:  auditUserFlag,
+		Command: strings.TrimPrefix(auditCommandFlag, "/"),
+		Limit:   auditLimitFlag,
+	}
+
+	var err error
+	if filter.Since, err = parseTimeFlag(auditSinceFlag); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseTimeFlag(auditUntilFlag); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	if _, err := os.Stat(auditDBFlag); err != nil {
+		return fmt.Errorf("audit database not found: %s", auditDBFlag)
+	}
+
+	store, err := audit.Open(auditDBFlag)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	entries, err := store.Query(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if auditJSONFlag {
+		encoder := json.NewEncoder(out)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No matching commands.")
+		return nil
+	}
+	for _, entry := range entries {
+		fmt.Fprintln(out, audit.FormatLine(entry, false))
+	}
+	return nil
+}
+
+// parseTimeFlag parses either a duration before now (e.g. "2h") or an RFC3339
+// timestamp. An empty value yields the zero time.
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/features"
+	"github.com/spf13/cobra"
+)
+
+var (
+	commandsBotFlag   string
+	commandsGuildFlag string
+)
+
+// newCommandsCmd returns the command for managing registered application
+// commands. It talks to the Discord REST API only; no gateway connection is
+// opened, so it can be used while the bots are stopped.
+func newCommandsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "commands",
+		Short: "Manage the application commands registered with Discord",
+	}
+	cmd.PersistentFlags().StringVarP(&commandsBotFlag, "bot", "b", "all", "Bots to manage, comma-separated (clippy, music, mtg, plugins, all)")
+	cmd.PersistentFlags().StringVar(&commandsGuildFlag, "guild", "", "Guild to manage instead of the configured guild_id")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "sync",
+		Short: "Replace the registered commands with the ones each bot defines",
+		Long:  "Overwrite the registered commands of each bot with its current definitions, removing commands it no longer defines. Dark-launched commands are synced to the guilds where their feature flag is on.",
+		Args:  cobra.NoArgs,
+		RunE:  runCommandsSync,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the commands registered for each bot",
+		Args:  cobra.NoArgs,
+		RunE:  runCommandsList,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "purge",
+		Short: "Delete every command registered for each bot",
+		Long:  "Delete the global commands and the guild commands of the configured guild (or --guild) for each bot",
+		Args:  cobra.NoArgs,
+		RunE:  runCommandsPurge,
+	})
+
+	return cmd
+}
+
+// commandsTarget is a bot whose commands are managed through the REST API.
+type commandsTarget struct {
+	app     botApp
+	cfg     *config.Config
+	session *discordgo.Session
+	appID   string
+	guildID string
+}
+
+// commandTargets loads the configuration of the selected bots and opens a
+// REST-only session for each of them.
+func commandTargets() ([]*commandsTarget, error) {
+	apps, err := selectBots(commandsBotFlag)
+	if err != nil {
+		return nil, err
+	}
+	if commandsGuildFlag != "" && !shareddiscord.IsValidGuildID(commandsGuildFlag) {
+		return nil, fmt.Errorf("invalid --guild: %s is not a guild ID", commandsGuildFlag)
+	}
+
+	var targets []*commandsTarget
+	for _, app := range apps {
+		cfg, err := loadBotConfig(app)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+
+		session, err := discordgo.New("Bot " + cfg.DiscordToken.Value())
+		if err != nil {
+			return nil, errors.NewDiscordError("failed to create Discord session", err)
+		}
+
+		// A bot's application ID is its user ID
+		user, err := session.User("@me")
+		if err != nil {
+			return nil, errors.NewDiscordError(fmt.Sprintf("%s: failed to look up the bot user", app.name), err)
+		}
+
+		target := &commandsTarget{app: app, cfg: cfg, session: session, appID: user.ID}
+		switch {
+		case commandsGuildFlag != "":
+			target.guildID = commandsGuildFlag
+		case !app.globalCommands && shareddiscord.IsValidGuildID(cfg.GuildID):
+			target.guildID = cfg.GuildID
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// scopes returns the scopes list and purge look at: global commands and, if
+// one is set, the target's guild.
+func (t *commandsTarget) scopes() []string {
+	if t.guildID == "" {
+		return []string{""}
+	}
+	return []string{"", t.guildID}
+}
+
+func runCommandsSync(cmd *cobra.Command, args []string) error {
+	targets, err := commandTargets()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, t := range targets {
+		if t.app.commands == nil {
+			fmt.Fprintf(out, "%s: commands are registered by the bot when it starts, skipping\n", t.app.name)
+			continue
+		}
+
+		flags, err := features.NewManager(t.cfg)
+		if err != nil {
+			return err
+		}
+
+		// Group the commands by the scope they are registered in. The default
+		// scope is always overwritten so removed commands disappear; global
+		// ("") sorts first.
+		byScope := map[string][]*discordgo.ApplicationCommand{t.guildID: {}}
+		for _, command := range t.app.commands(t.cfg) {
+			for _, guildID := range flags.CommandGuilds(command.Name, t.guildID) {
+				byScope[guildID] = append(byScope[guildID], command)
+			}
+		}
+
+		for _, guildID := range sortedKeys(byScope) {
+			registered, err := t.session.ApplicationCommandBulkOverwrite(t.appID, guildID, byScope[guildID])
+			if err != nil {
+				return errors.NewDiscordError(fmt.Sprintf("%s: failed to sync commands in %s", t.app.name, scopeName(guildID)), err)
+			}
+			fmt.Fprintf(out, "%s: synced %d command(s) in %s\n", t.app.name, len(registered), scopeName(guildID))
+		}
+	}
+
+	return nil
+}
+
+func runCommandsList(cmd *cobra.Command, args []string) error {
+	targets, err := commandTargets()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BOT\tSCOPE\tNAME\tID\tDESCRIPTION")
+	for _, t := range targets {
+		for _, guildID := range t.scopes() {
+			registered, err := t.session.ApplicationCommands(t.appID, guildID)
+			if err != nil {
+				return errors.NewDiscordError(fmt.Sprintf("%s: failed to list commands in %s", t.app.name, scopeName(guildID)), err)
+			}
+			printCommands(w, t.app.name, guildID, registered)
+		}
+	}
+	return w.Flush()
+}
+
+func runCommandsPurge(cmd *cobra.Command, args []string) error {
+	targets, err := commandTargets()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	for _, t := range targets {
+		for _, guildID := range t.scopes() {
+			registered, err := t.session.ApplicationCommands(t.appID, guildID)
+			if err != nil {
+				return errors.NewDiscordError(fmt.Sprintf("%s: failed to list commands in %s", t.app.name, scopeName(guildID)), err)
+			}
+			if len(registered) == 0 {
+				continue
+			}
+
+			if _, err := t.session.ApplicationCommandBulkOverwrite(t.appID, guildID, []*discordgo.ApplicationCommand{}); err != nil {
+				return errors.NewDiscordError(fmt.Sprintf("%s: failed to purge commands in %s", t.app.name, scopeName(guildID)), err)
+			}
+			fmt.Fprintf(out, "%s: deleted %d command(s) in %s\n", t.app.name, len(registered), scopeName(guildID))
+		}
+	}
+	return nil
+}
+
+// printCommands writes one tab-separated row per command.
+func printCommands(w io.Writer, bot, guildID string, commands []*discordgo.ApplicationCommand) {
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	for _, command := range commands {
+		fmt.Fprintf(w, "%s\t%s\t/%s\t%s\t%s\n", bot, scopeName(guildID), command.Name, command.ID, command.Description)
+	}
+}
+
+// scopeName describes a command scope for output.
+func scopeName(guildID string) string {
+	if guildID == "" {
+		return "global"
+	}
+	return "guild " + guildID
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configBotFlag   string
+	configForceFlag bool
+)
+
+// newConfigCmd returns the command for working with bot configuration.
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and create bot configuration",
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration of each bot",
+		Long:  "Load the configuration of each bot from --config and the environment and print every problem found",
+		Args:  cobra.NoArgs,
+		RunE:  runConfigValidate,
+	}
+	validateCmd.Flags().StringVarP(&configBotFlag, "bot", "b", "all", "Bots to validate, comma-separated (clippy, music, mtg, plugins, all)")
+
+	initCmd := &cobra.Command{
+		Use:   "init [file]",
+		Short: "Write an annotated example config file",
+		Long:  "Write an example config file with the default settings of every bot. The format follows the extension: .yaml or .yml, .toml, or JSON otherwise. YAML and TOML files explain each setting in comments. The default file is config.yaml.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runConfigInit,
+	}
+	initCmd.Flags().BoolVar(&configForceFlag, "force", false, "Overwrite an existing file")
+
+	cmd.AddCommand(validateCmd, initCmd)
+	return cmd
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path := "config.yaml"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if _, err := os.Stat(path); err == nil && !configForceFlag {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+
+	var buf bytes.Buffer
+	if err := config.WriteExample(&buf, config.FormatOf(path)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "wrote %s\n", path)
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	apps, err := selectBots(configBotFlag)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	total := 0
+	for _, app := range apps {
+		cfg, err := loadBotConfig(app)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			total++
+			continue
+		}
+
+		problems := cfg.Problems()
+		if len(problems) == 0 {
+			fmt.Fprintf(out, "%s bot: ok\n", cfg.BotType)
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(out, problem)
+		}
+		total += len(problems)
+	}
+
+	if total > 0 {
+		return fmt.Errorf("found %d configuration problem(s)", total)
+	}
+	return nil
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	musicdb "github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/internal/storage"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbBackupDirFlag    string
+	dbBackupEveryFlag  time.Duration
+	dbBackupKeepFlag   int
+	dbBackupMaxAgeFlag time.Duration
+	dbMigrateToFlag    int
+	dbMigrateStepsFlag int
+	dbExportGuildFlag  string
+	dbExportUserFlag   string
+	dbExportOutFlag    string
+	dbRestoreForceFlag bool
+)
+
+// managedDatabase is a SQLite database used by the bots.
+type managedDatabase struct {
+	// name is the value used on the command line, e.g. "audit".
+	name string
+	path string
+	// migrate creates or upgrades the schema.
+	migrate func(path string) error
+	// open opens the database without migrating it, for databases with
+	// versioned migrations; nil if the schema is created on open.
+	open func(path string) (*musicdb.DB, error)
+	// checkBackup checks that a backup has a schema this build can run and
+	// describes it.
+	checkBackup func(ctx context.Context, path string) (string, error)
+}
+
+// newDBCmd returns the command for maintaining the bots' databases.
+func newDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Maintain the bots' SQLite databases",
+		Long:  "Maintain the SQLite databases configured in --config and the environment: audit (audit_database_url) and music (database_url)",
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate [database...]",
+		Short: "Create or upgrade the database schemas",
+		Long:  "Apply every pending migration, like migrate up. The music database has numbered migrations that can also be reverted and listed; the audit schema is created when it is opened.",
+		RunE:  runDBMigrateUp,
+	}
+
+	upCmd := &cobra.Command{
+		Use:   "up [database...]",
+		Short: "Apply pending migrations",
+		RunE:  runDBMigrateUp,
+	}
+	upCmd.Flags().IntVar(&dbMigrateToFlag, "to", 0, "Stop after this version (0 applies every pending migration)")
+	migrateCmd.AddCommand(upCmd)
+
+	downCmd := &cobra.Command{
+		Use:   "down <database>",
+		Short: "Revert the latest migrations",
+		Long:  "Revert the latest applied migrations, newest first. Back up the database first; reverting can drop data.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runDBMigrateDown,
+	}
+	downCmd.Flags().IntVar(&dbMigrateStepsFlag, "steps", 1, "Number of migrations to revert")
+	migrateCmd.AddCommand(downCmd)
+
+	migrateCmd.AddCommand(&cobra.Command{
+		Use:   "status [database...]",
+		Short: "List applied and pending migrations",
+		RunE:  runDBMigrateStatus,
+	})
+	cmd.AddCommand(migrateCmd)
+
+	backupCmd := &cobra.Command{
+		Use:   "backup [database...]",
+		Short: "Write a consistent copy of each database",
+		Long: "Write a copy of each database to --dir as <name>-<timestamp>.db. Bots may keep running. " +
+			"With --every the command keeps running and backs up on that schedule; --keep and --max-age remove old backups after each run.",
+		RunE: runDBBackup,
+	}
+	backupCmd.Flags().StringVar(&dbBackupDirFlag, "dir", "backups", "Directory to write backups to")
+	backupCmd.Flags().DurationVar(&dbBackupEveryFlag, "every", 0, "Back up on this schedule until interrupted, e.g. 6h (0 backs up once)")
+	backupCmd.Flags().IntVar(&dbBackupKeepFlag, "keep", 0, "Backups kept per database (0 keeps every backup)")
+	backupCmd.Flags().DurationVar(&dbBackupMaxAgeFlag, "max-age", 0, "Remove backups older than this, e.g. 720h; the newest is always kept (0 disables)")
+	cmd.AddCommand(backupCmd)
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore <database> <backup file>",
+		Short: "Replace a database with a backup",
+		Long: "Replace a database with a backup after checking its integrity and that this build can run its schema. " +
+			"The current database is checkpointed and kept as <file>.<timestamp>.bak. Stop the bots first: a database that is still open is refused.",
+		Args: cobra.ExactArgs(2),
+		RunE: runDBRestore,
+	}
+	restoreCmd.Flags().BoolVar(&dbRestoreForceFlag, "force", false, "Restore even if the current database is open or cannot be checkpointed; its journal files are kept with the .bak")
+	cmd.AddCommand(restoreCmd)
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the data stored about a guild or user as JSON",
+		Long: "Write the playlists, settings, play history and audit log entries of a guild as JSON, for data requests. " +
+			"With --user only that user's playlists, requested tracks and commands are exported.",
+		Args: cobra.NoArgs,
+		RunE: runDBExport,
+	}
+	exportCmd.Flags().StringVar(&dbExportGuildFlag, "guild", "", "Guild ID to export")
+	exportCmd.Flags().StringVar(&dbExportUserFlag, "user", "", "Only export the data of this user ID")
+	exportCmd.Flags().StringVarP(&dbExportOutFlag, "out", "o", "", "File to write the export to (default stdout)")
+	_ = exportCmd.MarkFlagRequired("guild")
+	cmd.AddCommand(exportCmd)
+
+	return cmd
+}
+
+// managedDatabases returns the databases configured for the bots, or only
+// the named ones.
+func managedDatabases(names []string) ([]managedDatabase, error) {
+	auditCfg, err := config.LoadWith(config.BotTypeClipper, loadOptions())
+	if err != nil {
+		return nil, err
+	}
+	musicCfg, err := config.LoadWith(config.BotTypeMusic, loadOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	all := []managedDatabase{
+		{name: "audit", path: auditCfg.AuditDatabaseURL, migrate: migrateAudit, checkBackup: checkAuditBackup},
+		{name: "music", path: musicCfg.DatabaseURL, migrate: migrateMusic, open: musicdb.Open, checkBackup: checkMusicBackup},
+	}
+
+	var selected []managedDatabase
+	for _, db := range all {
+		if db.path != "" && (len(names) == 0 || contains(names, db.name)) {
+			selected = append(selected, db)
+		}
+	}
+	for _, name := range names {
+		if !containsDatabase(all, name) {
+			return nil, fmt.Errorf("unknown database: %s (valid options: audit, music)", name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no database configured")
+	}
+
+	return selected, nil
+}
+
+func migrateAudit(path string) error {
+	store, err := audit.Open(path)
+	if err != nil {
+		return err
+	}
+	return store.Close()
+}
+
+func migrateMusic(path string) error {
+	db, err := musicdb.NewDB(path)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+func checkAuditBackup(ctx context.Context, path string) (string, error) {
+	if err := audit.CheckBackup(ctx, path); err != nil {
+		return "", err
+	}
+	return "audit log", nil
+}
+
+func checkMusicBackup(ctx context.Context, path string) (string, error) {
+	version, err := musicdb.CheckBackup(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	migrations, err := musicdb.Migrations()
+	if err != nil {
+		return "", err
+	}
+	latest := migrations[len(migrations)-1].Version
+	if version < latest {
+		return fmt.Sprintf("schema version %d, upgraded to %d when the music bot next starts", version, latest), nil
+	}
+	return fmt.Sprintf("schema version %d", version), nil
+}
+
+func runDBMigrateUp(cmd *cobra.Command, args []string) error {
+	databases, err := managedDatabases(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	out := cmd.OutOrStdout()
+	for _, db := range databases {
+		if db.open == nil {
+			if err := db.migrate(db.path); err != nil {
+				return fmt.Errorf("%s: %w", db.name, err)
+			}
+			fmt.Fprintf(out, "%s: %s is up to date\n", db.name, db.path)
+			continue
+		}
+
+		err := withVersionedDatabase(db, func(conn *musicdb.DB) error {
+			applied, err := conn.MigrateUp(ctx, dbMigrateToFlag)
+			for _, m := range applied {
+				fmt.Fprintf(out, "%s: applied %d %s\n", db.name, m.Version, m.Name)
+			}
+			if err != nil {
+				return err
+			}
+
+			version, err := conn.SchemaVersion(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s: %s is at version %d\n", db.name, db.path, version)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", db.name, err)
+		}
+	}
+	return nil
+}
+
+func runDBMigrateDown(cmd *cobra.Command, args []string) error {
+	databases, err := managedDatabases(args)
+	if err != nil {
+		return err
+	}
+	db := databases[0]
+	if db.open == nil {
+		return fmt.Errorf("%s: schema is not versioned, nothing to revert", db.name)
+	}
+	if dbMigrateStepsFlag < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	out := cmd.OutOrStdout()
+	err = withVersionedDatabase(db, func(conn *musicdb.DB) error {
+		reverted, err := conn.MigrateDown(ctx, dbMigrateStepsFlag)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "%s: reverted %d %s\n", db.name, m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		version, err := conn.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s: %s is at version %d\n", db.name, db.path, version)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.name, err)
+	}
+	return nil
+}
+
+func runDBMigrateStatus(cmd *cobra.Command, args []string) error {
+	databases, err := managedDatabases(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DATABASE\tVERSION\tNAME\tSTATUS")
+	for _, db := range databases {
+		if db.open == nil {
+			fmt.Fprintf(w, "%s\t-\t-\tnot versioned, created on open\n", db.name)
+			continue
+		}
+
+		err := withVersionedDatabase(db, func(conn *musicdb.DB) error {
+			statuses, err := conn.MigrationStatus(ctx)
+			if err != nil {
+				return err
+			}
+			for _, status := range statuses {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", db.name, status.Version, status.Name, migrationState(status))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", db.name, err)
+		}
+	}
+	return w.Flush()
+}
+
+// withVersionedDatabase opens a database with versioned migrations for fn.
+func withVersionedDatabase(db managedDatabase, fn func(conn *musicdb.DB) error) error {
+	conn, err := db.open(db.path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return fn(conn)
+}
+
+// migrationState describes a migration in db migrate status.
+func migrationState(status musicdb.MigrationStatus) string {
+	switch {
+	case status.Unknown:
+		return "applied " + status.AppliedAt.Local().Format(time.DateTime) + ", unknown to this build"
+	case status.Modified:
+		return "applied " + status.AppliedAt.Local().Format(time.DateTime) + ", modified since"
+	case status.Applied:
+		return "applied " + status.AppliedAt.Local().Format(time.DateTime)
+	default:
+		return "pending"
+	}
+}
+
+func runDBBackup(cmd *cobra.Command, args []string) error {
+	databases, err := managedDatabases(args)
+	if err != nil {
+		return err
+	}
+	if dbBackupEveryFlag < 0 || dbBackupKeepFlag < 0 || dbBackupMaxAgeFlag < 0 {
+		return fmt.Errorf("--every, --keep and --max-age cannot be negative")
+	}
+
+	if err := os.MkdirAll(dbBackupDirFlag, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	if dbBackupEveryFlag == 0 {
+		return backupDatabases(cmd, databases)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(dbBackupEveryFlag)
+	defer ticker.Stop()
+
+	out := cmd.OutOrStdout()
+	for {
+		// A failed run is reported and retried on the next tick
+		if err := backupDatabases(cmd, databases); err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupDatabases backs up each database to --dir and applies --keep and
+// --max-age to its backups.
+func backupDatabases(cmd *cobra.Command, databases []managedDatabase) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	out := cmd.OutOrStdout()
+	now := time.Now()
+	for _, db := range databases {
+		if _, err := os.Stat(db.path); os.IsNotExist(err) {
+			fmt.Fprintf(out, "%s: %s does not exist, skipping\n", db.name, db.path)
+			continue
+		}
+
+		dest := database.BackupPath(dbBackupDirFlag, db.name, now)
+		if err := database.Backup(ctx, db.path, dest); err != nil {
+			return fmt.Errorf("%s: %w", db.name, err)
+		}
+		fmt.Fprintf(out, "%s: backed up %s to %s\n", db.name, db.path, dest)
+
+		if dbBackupKeepFlag == 0 && dbBackupMaxAgeFlag == 0 {
+			continue
+		}
+		removed, err := database.PruneBackups(dbBackupDirFlag, db.name, dbBackupKeepFlag, dbBackupMaxAgeFlag)
+		for _, path := range removed {
+			fmt.Fprintf(out, "%s: removed old backup %s\n", db.name, path)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", db.name, err)
+		}
+	}
+	return nil
+}
+
+func runDBRestore(cmd *cobra.Command, args []string) error {
+	databases, err := managedDatabases(args[:1])
+	if err != nil {
+		return err
+	}
+	db := databases[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if _, err := os.Stat(args[1]); err != nil {
+		return fmt.Errorf("%s: backup not found: %s", db.name, args[1])
+	}
+	schema, err := db.checkBackup(ctx, args[1])
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.name, err)
+	}
+
+	if err := database.Restore(ctx, args[1], db.path, dbRestoreForceFlag); err != nil {
+		return fmt.Errorf("%s: %w", db.name, err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s: restored %s from %s (%s)\n", db.name, db.path, args[1], schema)
+	return nil
+}
+
+func runDBExport(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadWith(config.BotTypeMusic, loadOptions())
+	if err != nil {
+		return err
+	}
+	if cfg.Storage == config.StorageMemory {
+		return fmt.Errorf("storage is %s, there is no stored data to export", config.StorageMemory)
+	}
+	if _, err := os.Stat(cfg.DatabaseURL); err != nil {
+		return fmt.Errorf("music database not found: %s", cfg.DatabaseURL)
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	export, err := store.ExportGuild(ctx, dbExportGuildFlag, dbExportUserFlag)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if dbExportOutFlag == "" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := os.WriteFile(dbExportOutFlag, data, 0600); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Exported %d playlists, %d plays and %d commands to %s\n",
+		len(export.Playlists), len(export.History), len(export.Audit), dbExportOutFlag)
+	return nil
+}
+
+// contains reports whether values contains value, ignoring case.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsDatabase reports whether databases has one with the given name.
+func containsDatabase(databases []managedDatabase, name string) bool {
+	for _, db := range databases {
+		if strings.EqualFold(db.name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,13 @@ module github.com/sawyer/go-discord-bots
 go 1.23.0
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/bwmarrin/discordgo v0.29.0
 	github.com/magefile/mage v1.15.0
 	github.com/mattn/go-sqlite3 v1.14.31
 	github.com/spf13/cobra v1.8.0
 	golang.org/x/text v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 	layeh.com/gopus v0.0.0-20210501142526-1ee02d434e32
 )
 
@@ -2,10 +2,12 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
+
+	sharedconfig "github.com/sawyer/go-discord-bots/pkg/config"
 )
 
 // Config represents the main configuration for the bot framework.
@@ -17,27 +19,27 @@ type Config struct {
 // BotConfig represents configuration for a specific bot instance.
 type BotConfig struct {
 	// Bot identification
-	BotName       string `json:"bot_name"`
-	DiscordToken  string `json:"discord_token"`
-	CommandPrefix string `json:"command_prefix"`
+	BotName       string              `json:"bot_name"`
+	DiscordToken  sharedconfig.Secret `json:"discord_token,omitempty"`
+	CommandPrefix string              `json:"command_prefix"`
 
 	// Server configuration
 	GuildID string `json:"guild_id,omitempty"`
 
 	// Behavior settings
-	DebugMode       bool          `json:"debug_mode"`
-	LogLevel        string        `json:"log_level"`
-	JSONLogging     bool          `json:"json_logging"`
-	CommandCooldown time.Duration `json:"command_cooldown"`
-	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	DebugMode       bool                  `json:"debug_mode"`
+	LogLevel        string                `json:"log_level"`
+	JSONLogging     bool                  `json:"json_logging"`
+	CommandCooldown sharedconfig.Duration `json:"command_cooldown"`
+	ShutdownTimeout sharedconfig.Duration `json:"shutdown_timeout"`
 
 	// Feature flags
 	RandomResponses bool `json:"random_responses"`
 
 	// Music bot specific settings
-	MaxQueueSize      int           `json:"max_queue_size,omitempty"`
-	InactivityTimeout time.Duration `json:"inactivity_timeout,omitempty"`
-	VolumeLevel       float64       `json:"volume_level,omitempty"`
+	MaxQueueSize      int                   `json:"max_queue_size,omitempty"`
+	InactivityTimeout sharedconfig.Duration `json:"inactivity_timeout,omitempty"`
+	VolumeLevel       float64               `json:"volume_level,omitempty"`
 
 	// Database settings (for music bot)
 	DatabaseURL string `json:"database_url,omitempty"`
@@ -52,8 +54,8 @@ func DefaultConfig() *Config {
 			LogLevel:        "INFO",
 			JSONLogging:     false,
 			DebugMode:       false,
-			CommandCooldown: 5 * time.Second,
-			ShutdownTimeout: 10 * time.Second,
+			CommandCooldown: sharedconfig.Duration(5 * time.Second),
+			ShutdownTimeout: sharedconfig.Duration(10 * time.Second),
 			RandomResponses: true,
 		},
 		Music: &BotConfig{
@@ -62,73 +64,50 @@ func DefaultConfig() *Config {
 			LogLevel:          "INFO",
 			JSONLogging:       false,
 			DebugMode:         false,
-			CommandCooldown:   3 * time.Second,
-			ShutdownTimeout:   10 * time.Second,
+			CommandCooldown:   sharedconfig.Duration(3 * time.Second),
+			ShutdownTimeout:   sharedconfig.Duration(10 * time.Second),
 			MaxQueueSize:      100,
-			InactivityTimeout: 5 * time.Minute,
+			InactivityTimeout: sharedconfig.Duration(5 * time.Minute),
 			VolumeLevel:       0.5,
 		},
 	}
 }
 
-// Load loads configuration from a JSON file.
+// Load loads the clippy and music configuration through the shared loader
+// (defaults < config file < environment), so the file uses the same layout
+// as pkg/config: shared settings at the top level and "clippy" and "music"
+// sections.
 func Load(configPath string) (*Config, error) {
-	// Start with defaults
-	cfg := DefaultConfig()
-
-	// Load from config file if provided and exists
-	if configPath != "" {
-		if _, err := os.Stat(configPath); err == nil {
-			data, err := os.ReadFile(configPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read config file: %w", err)
-			}
-
-			if err := json.Unmarshal(data, cfg); err != nil {
-				return nil, fmt.Errorf("failed to parse config file: %w", err)
-			}
-		}
+	clippy, err := sharedconfig.LoadFromFile(configPath, sharedconfig.BotTypeClipper)
+	if err != nil {
+		return nil, err
 	}
 
-	// Override with environment variables if set
-	cfg.loadFromEnvironment()
+	music, err := sharedconfig.LoadFromFile(configPath, sharedconfig.BotTypeMusic)
+	if err != nil {
+		return nil, err
+	}
 
-	return cfg, nil
+	return &Config{Clippy: fromShared(clippy), Music: fromShared(music)}, nil
 }
 
-// loadFromEnvironment loads configuration from environment variables.
-func (c *Config) loadFromEnvironment() {
-	// Clippy bot environment variables
-	if token := os.Getenv("CLIPPY_DISCORD_TOKEN"); token != "" {
-		c.Clippy.DiscordToken = token
-	}
-	if guildID := os.Getenv("CLIPPY_GUILD_ID"); guildID != "" {
-		c.Clippy.GuildID = guildID
-	}
-	if prefix := os.Getenv("CLIPPY_COMMAND_PREFIX"); prefix != "" {
-		c.Clippy.CommandPrefix = prefix
-	}
-	if os.Getenv("CLIPPY_DEBUG") == "true" {
-		c.Clippy.DebugMode = true
-		c.Clippy.LogLevel = "DEBUG"
-	}
-
-	// Music bot environment variables
-	if token := os.Getenv("MUSIC_DISCORD_TOKEN"); token != "" {
-		c.Music.DiscordToken = token
-	}
-	if guildID := os.Getenv("MUSIC_GUILD_ID"); guildID != "" {
-		c.Music.GuildID = guildID
-	}
-	if prefix := os.Getenv("MUSIC_COMMAND_PREFIX"); prefix != "" {
-		c.Music.CommandPrefix = prefix
-	}
-	if os.Getenv("MUSIC_DEBUG") == "true" {
-		c.Music.DebugMode = true
-		c.Music.LogLevel = "DEBUG"
-	}
-	if dbURL := os.Getenv("MUSIC_DATABASE_URL"); dbURL != "" {
-		c.Music.DatabaseURL = dbURL
+// fromShared converts a shared bot configuration.
+func fromShared(shared *sharedconfig.Config) *BotConfig {
+	return &BotConfig{
+		BotName:           shared.BotName,
+		DiscordToken:      shared.DiscordToken,
+		CommandPrefix:     shared.CommandPrefix,
+		GuildID:           shared.GuildID,
+		DebugMode:         shared.DebugMode,
+		LogLevel:          strings.ToUpper(shared.LogLevel),
+		JSONLogging:       shared.JSONLogging,
+		CommandCooldown:   shared.CommandCooldown,
+		ShutdownTimeout:   shared.ShutdownTimeout,
+		RandomResponses:   shared.RandomResponses,
+		MaxQueueSize:      shared.MaxQueueSize,
+		InactivityTimeout: shared.InactivityTimeout,
+		VolumeLevel:       shared.VolumeLevel,
+		DatabaseURL:       shared.DatabaseURL,
 	}
 }
 
@@ -190,11 +169,11 @@ func (bc *BotConfig) validate(botName string) error {
 
 	// Set defaults for durations if not set
 	if bc.CommandCooldown == 0 {
-		bc.CommandCooldown = 5 * time.Second
+		bc.CommandCooldown = sharedconfig.Duration(5 * time.Second)
 	}
 
 	if bc.ShutdownTimeout == 0 {
-		bc.ShutdownTimeout = 10 * time.Second
+		bc.ShutdownTimeout = sharedconfig.Duration(10 * time.Second)
 	}
 
 	// Music bot specific validation
@@ -204,7 +183,7 @@ func (bc *BotConfig) validate(botName string) error {
 		}
 
 		if bc.InactivityTimeout == 0 {
-			bc.InactivityTimeout = 5 * time.Minute
+			bc.InactivityTimeout = sharedconfig.Duration(5 * time.Minute)
 		}
 
 		if bc.VolumeLevel <= 0 || bc.VolumeLevel > 1 {
@@ -215,9 +194,11 @@ func (bc *BotConfig) validate(botName string) error {
 	return nil
 }
 
-// Save saves the configuration to a JSON file.
+// Save saves the configuration to a file in the format of its extension.
+// Tokens are never written; they are left to the environment.
 func (c *Config) Save(configPath string) error {
-	data, err := json.MarshalIndent(c, "", "  ")
+	saved := &Config{Clippy: c.Clippy.withoutToken(), Music: c.Music.withoutToken()}
+	data, err := sharedconfig.Marshal(sharedconfig.FormatOf(configPath), saved)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -228,3 +209,13 @@ func (c *Config) Save(configPath string) error {
 
 	return nil
 }
+
+// withoutToken returns a copy of the bot configuration without its token.
+func (bc *BotConfig) withoutToken() *BotConfig {
+	if bc == nil {
+		return nil
+	}
+	saved := *bc
+	saved.DiscordToken = ""
+	return &saved
+}
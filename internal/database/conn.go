@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+// conn runs statements on a connection pool. Each distinct statement is
+// prepared once and reused, and the latency of every execution is reported
+// to the metrics collector as a "database" performance metric named after
+// the statement, such as select_playlists or insert_playlist_tracks.
+type conn struct {
+	db *sql.DB
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// newConn wraps a connection pool.
+func newConn(db *sql.DB) *conn {
+	return &conn{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// prepare returns the prepared statement for a query, preparing it on first
+// use.
+func (c *conn) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// close closes the prepared statements. The pool itself is closed by its
+// owner.
+func (c *conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for query, stmt := range c.stmts {
+		_ = stmt.Close()
+		delete(c.stmts, query)
+	}
+}
+
+// ExecContext executes a statement that returns no rows.
+func (c *conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer recordLatency(query, time.Now())
+	return stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext executes a statement that returns rows.
+func (c *conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer recordLatency(query, time.Now())
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowContext executes a statement that returns at most one row. Errors
+// are deferred until the row is scanned.
+func (c *conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		// Let database/sql report the error when the row is scanned
+		return c.db.QueryRowContext(ctx, query, args...)
+	}
+	defer recordLatency(query, time.Now())
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// BeginTx starts a transaction whose statements use the prepared statements
+// of c.
+func (c *conn) BeginTx(ctx context.Context) (*Tx, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, conn: c}, nil
+}
+
+// Tx is a transaction. Its ExecContext, QueryContext and QueryRowContext run
+// the prepared statements of the connection inside the transaction; the
+// methods of the embedded *sql.Tx run unprepared SQL, such as the multi
+// statement scripts of migrations.
+type Tx struct {
+	*sql.Tx
+	conn *conn
+}
+
+// ExecContext executes a statement that returns no rows.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := tx.conn.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer recordLatency(query, time.Now())
+	return tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+}
+
+// QueryContext executes a statement that returns rows.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := tx.conn.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer recordLatency(query, time.Now())
+	return tx.StmtContext(ctx, stmt).QueryContext(ctx, args...)
+}
+
+// QueryRowContext executes a statement that returns at most one row.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	stmt, err := tx.conn.prepare(ctx, query)
+	if err != nil {
+		return tx.Tx.QueryRowContext(ctx, query, args...)
+	}
+	defer recordLatency(query, time.Now())
+	return tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+}
+
+// recordLatency reports how long a statement started at start took.
+func recordLatency(query string, start time.Time) {
+	elapsed := time.Since(start)
+	metrics.RecordPerformanceMetric("database", statementName(query), float64(elapsed.Microseconds())/1000, "ms")
+}
+
+// statementName names a statement after its verb and the table it works on,
+// e.g. "select_playlist_tracks" for a SELECT ... FROM playlist_tracks.
+func statementName(query string) string {
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+
+	verb := fields[0]
+	var after string
+	switch verb {
+	case "select", "delete":
+		after = "from"
+	case "insert":
+		after = "into"
+	case "update":
+		return verb + "_" + tableName(fields, 1)
+	default:
+		return verb
+	}
+
+	for i, field := range fields {
+		if field == after {
+			return verb + "_" + tableName(fields, i+1)
+		}
+	}
+	return verb
+}
+
+// tableName returns the table name at fields[i], skipping "or replace" and
+// "or ignore" clauses.
+func tableName(fields []string, i int) string {
+	for i < len(fields) && (fields[i] == "or" || fields[i] == "ignore" || fields[i] == "replace") {
+		i++
+	}
+	if i >= len(fields) {
+		return "unknown"
+	}
+	return strings.Trim(fields[i], "`\"(;")
+}
@@ -3,27 +3,31 @@ package database
 
 import (
 	"context"
-	"database/sql"
-	"encoding/json"
-	"fmt"
-	"time"
 
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 	"github.com/sawyer/go-discord-bots/internal/errors"
+	shareddb "github.com/sawyer/go-discord-bots/pkg/database"
 )
 
 // DB represents a database connection with music bot functionality.
 type DB struct {
-	conn *sql.DB
+	conn       *conn
+	handle     *shareddb.Handle
+	migrations []Migration
 }
 
 // Playlist represents a music playlist.
 type Playlist struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	OwnerID string `json:"owner_id"`
-	GuildID string `json:"guild_id"`
-	Songs   []Song `json:"songs"`
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    string     `json:"owner_id"`
+	GuildID    string     `json:"guild_id"`
+	Visibility Visibility `json:"visibility"`
+	// Server is set for server playlists, which the guild's DJs manage
+	// together with the user who created them.
+	Server        bool     `json:"server"`
+	Collaborators []string `json:"collaborators"`
+	Songs         []Song   `json:"songs"`
 }
 
 // Song represents a song in a playlist.
@@ -32,61 +36,59 @@ type Song struct {
 	URL        string `json:"url"`
 	WebpageURL string `json:"webpage_url"`
 	Duration   *int   `json:"duration,omitempty"`
-	AddedAt    string `json:"added_at"`
+	// Source is the site the song was found on, such as "Youtube".
+	Source  string `json:"source,omitempty"`
+	AddedBy string `json:"added_by,omitempty"`
+	AddedAt string `json:"added_at"`
 }
 
-// NewDB creates a new database connection.
+// NewDB creates a new database connection and applies pending migrations.
 func NewDB(databaseURL string) (*DB, error) {
+	db, err := Open(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.MigrateUp(context.Background(), 0); err != nil {
+		_ = db.Close()
+		return nil, errors.NewDatabaseError("failed to migrate database", err)
+	}
+
+	return db, nil
+}
+
+// Open opens a database connection without migrating it, for managing the
+// schema with MigrateUp and MigrateDown.
+func Open(databaseURL string) (*DB, error) {
 	if databaseURL == "" {
 		databaseURL = "bot.db" // Default SQLite database
 	}
 
-	conn, err := sql.Open("sqlite3", databaseURL)
+	migrations, err := Migrations()
 	if err != nil {
-		return nil, errors.NewDatabaseError("failed to open database", err)
+		return nil, errors.NewInternalError("failed to load migrations", err)
 	}
 
-	db := &DB{conn: conn}
-
-	if err := db.migrate(); err != nil {
-		return nil, errors.NewDatabaseError("failed to migrate database", err)
+	handle, err := shareddb.Open(databaseURL)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to open database", err)
 	}
 
-	return db, nil
+	return &DB{conn: newConn(handle.DB), handle: handle, migrations: migrations}, nil
 }
 
-// Close closes the database connection.
+// Close closes the prepared statements and releases the connection pool.
 func (db *DB) Close() error {
 	if db.conn != nil {
-		return db.conn.Close()
+		db.conn.close()
 	}
-	return nil
-}
-
-// migrate creates the necessary database tables.
-func (db *DB) migrate() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS playlists (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		owner_id TEXT NOT NULL,
-		guild_id TEXT NOT NULL,
-		songs TEXT DEFAULT '[]',
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_playlists_owner_guild ON playlists(owner_id, guild_id);
-	`
-
-	_, err := db.conn.Exec(query)
-	if err != nil {
-		return fmt.Errorf("migration failed: %w", err)
+	if db.handle != nil {
+		return db.handle.Close()
 	}
-
 	return nil
 }
 
-// CreatePlaylist creates a new playlist.
+// CreatePlaylist creates a new private playlist.
 func (db *DB) CreatePlaylist(ctx context.Context, name, ownerID, guildID string) (int, error) {
 	query := `INSERT INTO playlists (name, owner_id, guild_id) VALUES (?, ?, ?)`
 
@@ -103,166 +105,93 @@ func (db *DB) CreatePlaylist(ctx context.Context, name, ownerID, guildID string)
 	return int(id), nil
 }
 
-// GetPlaylist retrieves a playlist by ID.
+// GetPlaylist retrieves a playlist with its collaborators and songs by ID.
+// It returns nil if there is no such playlist. No permissions are checked;
+// use ViewPlaylist on behalf of a user.
 func (db *DB) GetPlaylist(ctx context.Context, playlistID int) (*Playlist, error) {
-	query := `SELECT id, name, owner_id, guild_id, songs FROM playlists WHERE id = ?`
-
-	var playlist Playlist
-	var songsJSON string
-
-	err := db.conn.QueryRowContext(ctx, query, playlistID).Scan(
-		&playlist.ID,
-		&playlist.Name,
-		&playlist.OwnerID,
-		&playlist.GuildID,
-		&songsJSON,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, errors.NewDatabaseError("failed to get playlist", err)
+	playlist, err := loadPlaylist(ctx, db.conn, playlistID)
+	if err != nil || playlist == nil {
+		return nil, err
 	}
 
-	// Parse songs JSON
-	if err := json.Unmarshal([]byte(songsJSON), &playlist.Songs); err != nil {
-		return nil, errors.NewDatabaseError("failed to parse songs JSON", err)
+	playlist.Songs, err = loadSongs(ctx, db.conn, playlist.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	return &playlist, nil
+	return playlist, nil
 }
 
-// GetUserPlaylists retrieves all playlists for a user in a guild.
+// GetUserPlaylists retrieves all playlists a user owns in a guild.
 func (db *DB) GetUserPlaylists(ctx context.Context, ownerID, guildID string) ([]*Playlist, error) {
-	query := `SELECT id, name, owner_id, guild_id, songs FROM playlists WHERE owner_id = ? AND guild_id = ? ORDER BY created_at DESC`
+	query := `SELECT ` + playlistColumns + ` FROM playlists WHERE owner_id = ? AND guild_id = ? ORDER BY created_at DESC, id DESC`
+
+	return db.queryPlaylists(ctx, query, ownerID, guildID)
+}
+
+// GetGuildPlaylists retrieves every playlist of a guild, newest first. No
+// permissions are checked; it is meant for exports and maintenance.
+func (db *DB) GetGuildPlaylists(ctx context.Context, guildID string) ([]*Playlist, error) {
+	query := `SELECT ` + playlistColumns + ` FROM playlists WHERE guild_id = ? ORDER BY created_at DESC, id DESC`
+
+	return db.queryPlaylists(ctx, query, guildID)
+}
 
-	rows, err := db.conn.QueryContext(ctx, query, ownerID, guildID)
+// queryPlaylists runs a query selecting playlistColumns and loads the
+// collaborators and songs of every playlist found.
+func (db *DB) queryPlaylists(ctx context.Context, query string, args ...interface{}) ([]*Playlist, error) {
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, errors.NewDatabaseError("failed to get user playlists", err)
+		return nil, errors.NewDatabaseError("failed to get playlists", err)
 	}
 	defer func() { _ = rows.Close() }()
 
 	var playlists []*Playlist
 
 	for rows.Next() {
-		var playlist Playlist
-		var songsJSON string
-
-		err := rows.Scan(
-			&playlist.ID,
-			&playlist.Name,
-			&playlist.OwnerID,
-			&playlist.GuildID,
-			&songsJSON,
-		)
-
+		playlist, err := scanPlaylist(rows)
 		if err != nil {
 			return nil, errors.NewDatabaseError("failed to scan playlist row", err)
 		}
 
-		// Parse songs JSON
-		if err := json.Unmarshal([]byte(songsJSON), &playlist.Songs); err != nil {
-			return nil, errors.NewDatabaseError("failed to parse songs JSON", err)
-		}
-
-		playlists = append(playlists, &playlist)
+		playlists = append(playlists, playlist)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, errors.NewDatabaseError("error iterating playlist rows", err)
 	}
+	_ = rows.Close()
 
-	return playlists, nil
-}
-
-// AddSongToPlaylist adds a song to a playlist.
-func (db *DB) AddSongToPlaylist(ctx context.Context, playlistID int, song Song) error {
-	// Get current playlist
-	playlist, err := db.GetPlaylist(ctx, playlistID)
-	if err != nil {
-		return err
-	}
-
-	if playlist == nil {
-		return errors.NewNotFoundError("playlist not found")
-	}
-
-	// Add timestamp
-	song.AddedAt = time.Now().Format(time.RFC3339)
-
-	// Add song to list
-	playlist.Songs = append(playlist.Songs, song)
-
-	// Convert back to JSON
-	songsJSON, err := json.Marshal(playlist.Songs)
-	if err != nil {
-		return errors.NewDatabaseError("failed to marshal songs JSON", err)
-	}
-
-	// Update database
-	query := `UPDATE playlists SET songs = ? WHERE id = ?`
-	_, err = db.conn.ExecContext(ctx, query, string(songsJSON), playlistID)
-	if err != nil {
-		return errors.NewDatabaseError("failed to update playlist", err)
-	}
-
-	return nil
-}
-
-// RemoveSongFromPlaylist removes a song from a playlist by index.
-func (db *DB) RemoveSongFromPlaylist(ctx context.Context, playlistID, songIndex int) error {
-	// Get current playlist
-	playlist, err := db.GetPlaylist(ctx, playlistID)
-	if err != nil {
-		return err
-	}
-
-	if playlist == nil {
-		return errors.NewNotFoundError("playlist not found")
-	}
-
-	// Check index bounds
-	if songIndex < 0 || songIndex >= len(playlist.Songs) {
-		return errors.NewValidationError("invalid song index")
-	}
-
-	// Remove song from list
-	playlist.Songs = append(playlist.Songs[:songIndex], playlist.Songs[songIndex+1:]...)
-
-	// Convert back to JSON
-	songsJSON, err := json.Marshal(playlist.Songs)
-	if err != nil {
-		return errors.NewDatabaseError("failed to marshal songs JSON", err)
-	}
-
-	// Update database
-	query := `UPDATE playlists SET songs = ? WHERE id = ?`
-	_, err = db.conn.ExecContext(ctx, query, string(songsJSON), playlistID)
-	if err != nil {
-		return errors.NewDatabaseError("failed to update playlist", err)
+	for _, playlist := range playlists {
+		if playlist.Collaborators, err = loadCollaborators(ctx, db.conn, playlist.ID); err != nil {
+			return nil, err
+		}
+		if playlist.Songs, err = loadSongs(ctx, db.conn, playlist.ID); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return playlists, nil
 }
 
-// DeletePlaylist deletes a playlist.
-func (db *DB) DeletePlaylist(ctx context.Context, playlistID int, ownerID string) error {
-	query := `DELETE FROM playlists WHERE id = ? AND owner_id = ?`
-
-	result, err := db.conn.ExecContext(ctx, query, playlistID, ownerID)
-	if err != nil {
-		return errors.NewDatabaseError("failed to delete playlist", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return errors.NewDatabaseError("failed to get rows affected", err)
-	}
+// DeletePlaylist deletes a playlist with its songs and collaborators. Only
+// users who may manage the playlist can delete it.
+func (db *DB) DeletePlaylist(ctx context.Context, playlistID int, actor Actor) error {
+	return db.inTransaction(ctx, func(tx *Tx) error {
+		if _, err := playlistForAccess(ctx, tx, playlistID, actor, accessManage); err != nil {
+			return err
+		}
 
-	if rowsAffected == 0 {
-		return errors.NewNotFoundError("playlist not found or not owned by user")
-	}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM playlists WHERE id = ?`, playlistID); err != nil {
+			return errors.NewDatabaseError("failed to delete playlist", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM playlist_tracks WHERE playlist_id = ?`, playlistID); err != nil {
+			return errors.NewDatabaseError("failed to delete playlist songs", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM playlist_collaborators WHERE playlist_id = ?`, playlistID); err != nil {
+			return errors.NewDatabaseError("failed to delete playlist collaborators", err)
+		}
 
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,28 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/internal/storage"
+	"github.com/sawyer/go-discord-bots/internal/storage/storagetest"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storage.Store {
+		dir := t.TempDir()
+
+		db, err := database.NewDB(filepath.Join(dir, "music.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		auditLog, err := audit.Open(filepath.Join(dir, "audit.db"))
+		if err != nil {
+			_ = db.Close()
+			t.Fatal(err)
+		}
+		return storage.New(db, auditLog)
+	})
+}
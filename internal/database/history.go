@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// Play is a track played in a guild.
+type Play struct {
+	ID          int64  `json:"id"`
+	GuildID     string `json:"guild_id"`
+	RequesterID string `json:"requester_id,omitempty"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	// Duration is the length of the track in seconds, if known.
+	Duration  *int      `json:"duration,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// EndedAt is nil while the track is playing.
+	EndedAt *time.Time `json:"ended_at,omitempty"`
+	Skipped bool       `json:"skipped"`
+}
+
+// RecordPlay stores a track that started playing and returns its ID. A zero
+// StartedAt is set to now; ID, EndedAt and Skipped are ignored.
+func (db *DB) RecordPlay(ctx context.Context, play Play) (int64, error) {
+	if play.GuildID == "" {
+		return 0, errors.NewValidationError("plays need a guild ID")
+	}
+	if play.StartedAt.IsZero() {
+		play.StartedAt = time.Now()
+	}
+
+	query := `
+	INSERT INTO play_history (guild_id, requester_id, title, url, duration, started_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.ExecContext(ctx, query,
+		play.GuildID, play.RequesterID, play.Title, play.URL, play.Duration, play.StartedAt.UTC())
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to record play", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to get play ID", err)
+	}
+
+	return id, nil
+}
+
+// FinishPlay records when a play ended and whether it was skipped.
+func (db *DB) FinishPlay(ctx context.Context, playID int64, endedAt time.Time, skipped bool) error {
+	result, err := db.conn.ExecContext(ctx,
+		`UPDATE play_history SET ended_at = ?, skipped = ? WHERE id = ?`, endedAt.UTC(), skipped, playID)
+	if err != nil {
+		return errors.NewDatabaseError("failed to finish play", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.NewDatabaseError("failed to get rows affected", err)
+	}
+	if rowsAffected == 0 {
+		return errors.NewNotFoundError("play not found")
+	}
+
+	return nil
+}
+
+// RecentPlays returns the latest plays in a guild, newest first. A non-empty
+// requesterID limits them to the tracks that user requested; a limit of 0
+// returns every play.
+func (db *DB) RecentPlays(ctx context.Context, guildID, requesterID string, limit int) ([]Play, error) {
+	query := `
+	SELECT id, guild_id, requester_id, title, url, duration, started_at, ended_at, skipped
+	FROM play_history WHERE guild_id = ?
+	`
+	args := []interface{}{guildID}
+
+	if requesterID != "" {
+		query += ` AND requester_id = ?`
+		args = append(args, requesterID)
+	}
+	query += ` ORDER BY started_at DESC, id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get play history", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	plays := []Play{}
+	for rows.Next() {
+		var play Play
+		var duration sql.NullInt64
+		var endedAt sql.NullTime
+
+		err := rows.Scan(&play.ID, &play.GuildID, &play.RequesterID, &play.Title, &play.URL,
+			&duration, &play.StartedAt, &endedAt, &play.Skipped)
+		if err != nil {
+			return nil, errors.NewDatabaseError("failed to scan play history row", err)
+		}
+
+		if duration.Valid {
+			seconds := int(duration.Int64)
+			play.Duration = &seconds
+		}
+		if endedAt.Valid {
+			play.EndedAt = &endedAt.Time
+		}
+
+		plays = append(plays, play)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating play history rows", err)
+	}
+
+	return plays, nil
+}
+
+// TrackPlays is how often a track was played.
+type TrackPlays struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Plays int    `json:"plays"`
+}
+
+// RequesterPlays is how many tracks a user requested.
+type RequesterPlays struct {
+	RequesterID string `json:"requester_id"`
+	Plays       int    `json:"plays"`
+}
+
+// PlayStats summarizes the plays in a guild.
+type PlayStats struct {
+	Plays   int `json:"plays"`
+	Skipped int `json:"skipped"`
+	// Listened is the number of seconds spent playing finished plays.
+	Listened int64 `json:"listened"`
+	// TopTracks are the most played tracks, most played first.
+	TopTracks []TrackPlays `json:"top_tracks"`
+	// TopRequesters are the users who requested the most tracks.
+	TopRequesters []RequesterPlays `json:"top_requesters"`
+}
+
+// PlayStats summarizes the plays in a guild started at or after since; a
+// zero since covers every play. Tracks are told apart by URL and listed with
+// their latest title. At most limit top tracks and requesters are returned,
+// or all of them for a limit of 0; ties go to the one played most recently.
+func (db *DB) PlayStats(ctx context.Context, guildID string, since time.Time, limit int) (*PlayStats, error) {
+	stats := &PlayStats{TopTracks: []TrackPlays{}, TopRequesters: []RequesterPlays{}}
+	since = since.UTC()
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var listened sql.NullFloat64
+	err := db.conn.QueryRowContext(ctx, `
+	SELECT COUNT(*), COALESCE(SUM(skipped), 0),
+		SUM(CASE WHEN ended_at IS NOT NULL AND ended_at > started_at
+			THEN (julianday(ended_at) - julianday(started_at)) * 86400 END)
+	FROM play_history WHERE guild_id = ? AND started_at >= ?
+	`, guildID, since).Scan(&stats.Plays, &stats.Skipped, &listened)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get play totals", err)
+	}
+	stats.Listened = int64(math.Round(listened.Float64))
+
+	rows, err := db.conn.QueryContext(ctx, `
+	SELECT url, COUNT(*) AS plays, MAX(started_at) AS last_played,
+		(SELECT title FROM play_history latest
+		 WHERE latest.guild_id = ? AND latest.url = play_history.url
+		 ORDER BY latest.started_at DESC, latest.id DESC LIMIT 1)
+	FROM play_history WHERE guild_id = ? AND started_at >= ?
+	GROUP BY url ORDER BY plays DESC, last_played DESC, url LIMIT ?
+	`, guildID, guildID, since, limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get top tracks", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var track TrackPlays
+		var lastPlayed string
+		if err := rows.Scan(&track.URL, &track.Plays, &lastPlayed, &track.Title); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan top track row", err)
+		}
+		stats.TopTracks = append(stats.TopTracks, track)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating top track rows", err)
+	}
+
+	rows, err = db.conn.QueryContext(ctx, `
+	SELECT requester_id, COUNT(*) AS plays, MAX(started_at) AS last_played
+	FROM play_history WHERE guild_id = ? AND started_at >= ? AND requester_id != ''
+	GROUP BY requester_id ORDER BY plays DESC, last_played DESC, requester_id LIMIT ?
+	`, guildID, since, limit)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get top requesters", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var requester RequesterPlays
+		var lastPlayed string
+		if err := rows.Scan(&requester.RequesterID, &requester.Plays, &lastPlayed); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan top requester row", err)
+		}
+		stats.TopRequesters = append(stats.TopRequesters, requester)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating top requester rows", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// migrationFiles holds the schema migrations. Each version has an up file,
+// <version>_<name>.up.sql, and optionally a down file that reverts it,
+// <version>_<name>.down.sql. Versions are applied in ascending order.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches the name of a migration file.
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum returns a hash of the up migration. It is stored when the
+// migration is applied so that later edits to applied migrations are detected.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationStatus describes a migration and whether it has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when an applied migration has changed since.
+	Modified bool
+	// Unknown is set for a version applied by a newer build that this build
+	// has no file for.
+	Unknown bool
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrations returns the embedded migrations in version order.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles)
+}
+
+// loadMigrations reads the migrations in the migrations directory of fsys.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(fsys, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the table recording applied migrations.
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);
+	`
+
+	if _, err := db.conn.ExecContext(ctx, query); err != nil {
+		return errors.NewDatabaseError("failed to create schema_migrations table", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied migrations keyed by version.
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to read schema_migrations", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var m appliedMigration
+		if err := rows.Scan(&m.version, &m.name, &m.checksum, &m.appliedAt); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan schema_migrations row", err)
+		}
+		applied[m.version] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating schema_migrations rows", err)
+	}
+
+	return applied, nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an
+// empty database.
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// MigrationStatus returns every known migration with its state, followed by
+// applied versions this build does not know.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(db.migrations))
+	for _, m := range db.migrations {
+		status := MigrationStatus{Migration: m}
+		if a, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.appliedAt
+			status.Modified = a.checksum != m.Checksum()
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, a := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: a.version, Name: a.name},
+			Applied:   true,
+			AppliedAt: a.appliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// MigrateUp applies pending migrations up to and including version target,
+// or all of them if target is 0, and returns the migrations applied. It
+// refuses to run if an applied migration was modified or the database has a
+// version this build does not know.
+func (db *DB) MigrateUp(ctx context.Context, target int) ([]Migration, error) {
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		switch {
+		case status.Unknown:
+			return nil, errors.NewDatabaseError(fmt.Sprintf("database has migration %d (%s), which this build does not know; upgrade the bot", status.Version, status.Name), nil)
+		case status.Modified:
+			return nil, errors.NewDatabaseError(fmt.Sprintf("migration %d (%s) was modified after it was applied", status.Version, status.Name), nil)
+		case !status.Applied && (target == 0 || status.Version <= target):
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	for i, m := range pending {
+		err := db.inTransaction(ctx, func(tx *Tx) error {
+			if _, err := tx.Tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+				m.Version, m.Name, m.Checksum(), time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return pending[:i], errors.NewDatabaseError(fmt.Sprintf("migration %d (%s) failed", m.Version, m.Name), err)
+		}
+	}
+
+	return pending, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns the migrations reverted. Every one of them needs a down file.
+func (db *DB) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var revert []Migration
+	for i := len(statuses) - 1; i >= 0 && len(revert) < steps; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if status.Unknown {
+			return nil, errors.NewDatabaseError(fmt.Sprintf("cannot revert migration %d (%s), which this build does not know", status.Version, status.Name), nil)
+		}
+		if status.Down == "" {
+			return nil, errors.NewValidationError(fmt.Sprintf("migration %d (%s) has no down migration", status.Version, status.Name))
+		}
+		revert = append(revert, status.Migration)
+	}
+
+	for i, m := range revert {
+		err := db.inTransaction(ctx, func(tx *Tx) error {
+			if _, err := tx.Tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+			return err
+		})
+		if err != nil {
+			return revert[:i], errors.NewDatabaseError(fmt.Sprintf("reverting migration %d (%s) failed", m.Version, m.Name), err)
+		}
+	}
+
+	return revert, nil
+}
+
+// inTransaction runs fn in a transaction, committing if it succeeds. Errors
+// returned by fn are passed through unchanged.
+func (db *DB) inTransaction(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx)
+	if err != nil {
+		return errors.NewDatabaseError("failed to begin transaction", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.NewDatabaseError("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// CheckBackup checks that the database at path, usually a backup about to be
+// restored, has a schema this build can run: every applied migration must be
+// known and unmodified. It returns the schema version; older versions are
+// upgraded when the restored database is next opened. The file is opened
+// read-only.
+func CheckBackup(ctx context.Context, path string) (int, error) {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to open backup", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var tables int
+	err = conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&tables)
+	if err != nil {
+		return 0, errors.NewDatabaseError("backup is not a valid SQLite database", err)
+	}
+	if tables == 0 {
+		return 0, errors.NewValidationError("backup has no schema_migrations table; it is not a music database or predates versioned migrations")
+	}
+
+	db := &DB{conn: newConn(conn)}
+	defer db.conn.close()
+	if db.migrations, err = Migrations(); err != nil {
+		return 0, errors.NewInternalError("failed to load migrations", err)
+	}
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for _, status := range statuses {
+		switch {
+		case status.Unknown:
+			return 0, errors.NewValidationError(fmt.Sprintf("backup has migration %d (%s), which this build does not know; restore it with a newer build", status.Version, status.Name))
+		case status.Modified:
+			return 0, errors.NewValidationError(fmt.Sprintf("migration %d (%s) of the backup differs from this build", status.Version, status.Name))
+		case status.Applied:
+			version = status.Version
+		}
+	}
+	return version, nil
+}
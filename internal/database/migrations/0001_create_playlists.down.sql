@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_playlists_owner_guild;
+DROP TABLE IF EXISTS playlists;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS playlists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	owner_id TEXT NOT NULL,
+	guild_id TEXT NOT NULL,
+	songs TEXT DEFAULT '[]',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlists_owner_guild ON playlists(owner_id, guild_id);
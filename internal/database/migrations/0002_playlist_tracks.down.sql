@@ -0,0 +1,17 @@
+ALTER TABLE playlists ADD COLUMN songs TEXT DEFAULT '[]';
+
+-- Fold the tracks back into JSON blobs. Added-by users and sources are lost.
+UPDATE playlists SET songs = COALESCE((
+	SELECT json_group_array(json_object(
+		'title', t.title,
+		'url', t.url,
+		'webpage_url', t.webpage_url,
+		'duration', t.duration,
+		'added_at', t.added_at
+	) ORDER BY t.position)
+	FROM playlist_tracks t
+	WHERE t.playlist_id = playlists.id
+), '[]');
+
+DROP INDEX idx_playlist_tracks_playlist_position;
+DROP TABLE playlist_tracks;
@@ -0,0 +1,37 @@
+CREATE TABLE playlist_tracks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	url TEXT NOT NULL,
+	webpage_url TEXT NOT NULL DEFAULT '',
+	duration INTEGER,
+	source TEXT NOT NULL DEFAULT '',
+	added_by TEXT NOT NULL DEFAULT '',
+	added_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_playlist_tracks_playlist_position ON playlist_tracks(playlist_id, position);
+
+-- Move the songs of every playlist out of its JSON blob, keeping their order.
+-- The blobs did not record who added a song or where it came from, so the
+-- source is derived from the web page URL.
+INSERT INTO playlist_tracks (playlist_id, position, title, url, webpage_url, duration, source, added_by, added_at)
+SELECT
+	p.id,
+	CAST(song.key AS INTEGER),
+	COALESCE(json_extract(song.value, '$.title'), ''),
+	COALESCE(json_extract(song.value, '$.url'), ''),
+	COALESCE(json_extract(song.value, '$.webpage_url'), ''),
+	json_extract(song.value, '$.duration'),
+	CASE
+		WHEN json_extract(song.value, '$.webpage_url') LIKE '%youtube.com/%'
+			OR json_extract(song.value, '$.webpage_url') LIKE '%youtu.be/%' THEN 'Youtube'
+		WHEN json_extract(song.value, '$.webpage_url') LIKE '%soundcloud.com/%' THEN 'Soundcloud'
+		ELSE ''
+	END,
+	'',
+	COALESCE(NULLIF(json_extract(song.value, '$.added_at'), ''), strftime('%Y-%m-%dT%H:%M:%SZ', p.created_at))
+FROM playlists p, json_each(CASE WHEN json_valid(p.songs) THEN p.songs ELSE '[]' END) song;
+
+ALTER TABLE playlists DROP COLUMN songs;
@@ -0,0 +1,6 @@
+DROP INDEX idx_playlist_collaborators_user;
+DROP TABLE playlist_collaborators;
+
+DROP INDEX idx_playlists_guild_visibility;
+ALTER TABLE playlists DROP COLUMN server_playlist;
+ALTER TABLE playlists DROP COLUMN visibility;
@@ -0,0 +1,15 @@
+ALTER TABLE playlists ADD COLUMN visibility TEXT NOT NULL DEFAULT 'private'
+	CHECK (visibility IN ('private', 'guild', 'public'));
+ALTER TABLE playlists ADD COLUMN server_playlist INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_playlists_guild_visibility ON playlists(guild_id, visibility);
+
+CREATE TABLE playlist_collaborators (
+	playlist_id INTEGER NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	added_by TEXT NOT NULL,
+	added_at TEXT NOT NULL,
+	PRIMARY KEY (playlist_id, user_id)
+);
+
+CREATE INDEX idx_playlist_collaborators_user ON playlist_collaborators(user_id);
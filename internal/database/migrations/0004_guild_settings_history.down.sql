@@ -0,0 +1,5 @@
+DROP INDEX idx_play_history_requester_started;
+DROP INDEX idx_play_history_guild_started;
+DROP TABLE play_history;
+
+DROP TABLE guild_settings;
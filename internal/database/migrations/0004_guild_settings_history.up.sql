@@ -0,0 +1,22 @@
+CREATE TABLE guild_settings (
+	guild_id TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	updated_at DATETIME NOT NULL,
+	PRIMARY KEY (guild_id, key)
+);
+
+CREATE TABLE play_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guild_id TEXT NOT NULL,
+	requester_id TEXT NOT NULL DEFAULT '',
+	title TEXT NOT NULL,
+	url TEXT NOT NULL,
+	duration INTEGER,
+	started_at DATETIME NOT NULL,
+	ended_at DATETIME,
+	skipped INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_play_history_guild_started ON play_history(guild_id, started_at);
+CREATE INDEX idx_play_history_requester_started ON play_history(requester_id, started_at);
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// GuildSetting returns the value of a guild setting and whether it is set.
+func (db *DB) GuildSetting(ctx context.Context, guildID, key string) (string, bool, error) {
+	var value string
+	err := db.conn.QueryRowContext(ctx,
+		`SELECT value FROM guild_settings WHERE guild_id = ? AND key = ?`, guildID, key).Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, nil
+		}
+		return "", false, errors.NewDatabaseError("failed to get guild setting", err)
+	}
+
+	return value, true, nil
+}
+
+// GuildSettings returns every setting of a guild keyed by name.
+func (db *DB) GuildSettings(ctx context.Context, guildID string) (map[string]string, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT key, value FROM guild_settings WHERE guild_id = ?`, guildID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get guild settings", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan guild setting row", err)
+		}
+		settings[key] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating guild setting rows", err)
+	}
+
+	return settings, nil
+}
+
+// SetGuildSetting stores the value of a guild setting, replacing any
+// previous value.
+func (db *DB) SetGuildSetting(ctx context.Context, guildID, key, value string) error {
+	if guildID == "" || key == "" {
+		return errors.NewValidationError("guild settings need a guild ID and a key")
+	}
+
+	query := `
+	INSERT INTO guild_settings (guild_id, key, value, updated_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT (guild_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`
+
+	if _, err := db.conn.ExecContext(ctx, query, guildID, key, value, time.Now().UTC()); err != nil {
+		return errors.NewDatabaseError("failed to set guild setting", err)
+	}
+
+	return nil
+}
+
+// DeleteGuildSetting removes a guild setting. Removing a setting that is not
+// set has no effect.
+func (db *DB) DeleteGuildSetting(ctx context.Context, guildID, key string) error {
+	if _, err := db.conn.ExecContext(ctx,
+		`DELETE FROM guild_settings WHERE guild_id = ? AND key = ?`, guildID, key); err != nil {
+		return errors.NewDatabaseError("failed to delete guild setting", err)
+	}
+
+	return nil
+}
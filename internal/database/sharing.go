@@ -0,0 +1,378 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// Visibility controls who can see and play a playlist.
+type Visibility string
+
+const (
+	// VisibilityPrivate limits a playlist to its owner and collaborators.
+	VisibilityPrivate Visibility = "private"
+	// VisibilityGuild shares a playlist with everyone in its guild.
+	VisibilityGuild Visibility = "guild"
+	// VisibilityPublic shares a playlist with everyone in every guild.
+	VisibilityPublic Visibility = "public"
+)
+
+// Valid reports whether v is a known visibility.
+func (v Visibility) Valid() bool {
+	switch v {
+	case VisibilityPrivate, VisibilityGuild, VisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// Actor is the user a playlist operation is performed for.
+type Actor struct {
+	UserID string
+	// GuildID is the guild the user acts in, empty in DMs.
+	GuildID string
+	// DJ is set if the user manages the server playlists of GuildID.
+	DJ bool
+}
+
+// access is a level of access to a playlist.
+type access int
+
+const (
+	accessView access = iota
+	accessEdit
+	accessManage
+)
+
+// playlistColumns are the playlists columns read by scanPlaylist.
+const playlistColumns = `id, name, owner_id, guild_id, visibility, server_playlist`
+
+// querier runs queries on a connection or in a transaction.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// IsCollaborator reports whether a user is a collaborator of the playlist.
+func (p *Playlist) IsCollaborator(userID string) bool {
+	for _, collaborator := range p.Collaborators {
+		if collaborator == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanView reports whether an actor may see, play and clone the playlist.
+func (p *Playlist) CanView(actor Actor) bool {
+	switch {
+	case p.CanEdit(actor):
+		return true
+	case p.Visibility == VisibilityPublic:
+		return true
+	case p.Visibility == VisibilityGuild:
+		return actor.GuildID == p.GuildID
+	}
+	return false
+}
+
+// CanEdit reports whether an actor may add, remove and reorder songs: the
+// owner, collaborators and, for server playlists, the guild's DJs.
+func (p *Playlist) CanEdit(actor Actor) bool {
+	return p.CanManage(actor) || p.IsCollaborator(actor.UserID)
+}
+
+// CanManage reports whether an actor may change the visibility and the
+// collaborators of the playlist and delete it: the owner and, for server
+// playlists, the guild's DJs.
+func (p *Playlist) CanManage(actor Actor) bool {
+	if p.OwnerID == actor.UserID {
+		return true
+	}
+	return p.Server && actor.DJ && actor.GuildID == p.GuildID
+}
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPlaylist scans a row of playlistColumns.
+func scanPlaylist(row rowScanner) (*Playlist, error) {
+	var playlist Playlist
+
+	err := row.Scan(
+		&playlist.ID,
+		&playlist.Name,
+		&playlist.OwnerID,
+		&playlist.GuildID,
+		&playlist.Visibility,
+		&playlist.Server,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &playlist, nil
+}
+
+// loadPlaylist returns a playlist with its collaborators but without songs,
+// or nil if there is no such playlist.
+func loadPlaylist(ctx context.Context, q querier, playlistID int) (*Playlist, error) {
+	query := `SELECT ` + playlistColumns + ` FROM playlists WHERE id = ?`
+
+	playlist, err := scanPlaylist(q.QueryRowContext(ctx, query, playlistID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.NewDatabaseError("failed to get playlist", err)
+	}
+
+	playlist.Collaborators, err = loadCollaborators(ctx, q, playlist.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return playlist, nil
+}
+
+// loadCollaborators returns the user IDs of a playlist's collaborators in
+// the order they were added.
+func loadCollaborators(ctx context.Context, q querier, playlistID int) ([]string, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT user_id FROM playlist_collaborators WHERE playlist_id = ? ORDER BY added_at, user_id`, playlistID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get playlist collaborators", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	collaborators := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan playlist collaborator row", err)
+		}
+		collaborators = append(collaborators, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating playlist collaborator rows", err)
+	}
+
+	return collaborators, nil
+}
+
+// playlistForAccess loads a playlist and checks that an actor has the given
+// access to it. Playlists the actor cannot see are reported as not found.
+func playlistForAccess(ctx context.Context, q querier, playlistID int, actor Actor, level access) (*Playlist, error) {
+	playlist, err := loadPlaylist(ctx, q, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	if playlist == nil || !playlist.CanView(actor) {
+		return nil, errors.NewNotFoundError("playlist not found")
+	}
+
+	switch {
+	case level == accessEdit && !playlist.CanEdit(actor):
+		return nil, errors.NewPermissionError("only the owner and collaborators can edit this playlist")
+	case level == accessManage && !playlist.CanManage(actor):
+		return nil, errors.NewPermissionError("only the owner can manage this playlist")
+	}
+
+	return playlist, nil
+}
+
+// ViewPlaylist retrieves a playlist with its songs for an actor. It fails
+// with a not found error if the playlist does not exist or the actor may not
+// see it.
+func (db *DB) ViewPlaylist(ctx context.Context, playlistID int, actor Actor) (*Playlist, error) {
+	playlist, err := playlistForAccess(ctx, db.conn, playlistID, actor, accessView)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist.Songs, err = loadSongs(ctx, db.conn, playlist.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return playlist, nil
+}
+
+// GetPlaylistsFor retrieves the playlists an actor works with in its guild:
+// the actor's own playlists, those it collaborates on and the server
+// playlists it can see. Server playlists come first.
+func (db *DB) GetPlaylistsFor(ctx context.Context, actor Actor) ([]*Playlist, error) {
+	query := `
+	SELECT ` + playlistColumns + ` FROM playlists p
+	WHERE guild_id = ? AND (
+		owner_id = ?
+		OR server_playlist = 1
+		OR EXISTS (SELECT 1 FROM playlist_collaborators c WHERE c.playlist_id = p.id AND c.user_id = ?)
+	)
+	ORDER BY server_playlist DESC, created_at DESC, id DESC
+	`
+
+	playlists, err := db.queryPlaylists(ctx, query, actor.GuildID, actor.UserID, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := playlists[:0]
+	for _, playlist := range playlists {
+		if playlist.CanView(actor) {
+			visible = append(visible, playlist)
+		}
+	}
+
+	return visible, nil
+}
+
+// CreateServerPlaylist creates a server playlist in the actor's guild. Only
+// DJs can create them; they are visible to the whole guild.
+func (db *DB) CreateServerPlaylist(ctx context.Context, name string, actor Actor) (int, error) {
+	if actor.GuildID == "" || !actor.DJ {
+		return 0, errors.NewPermissionError("only DJs can create server playlists")
+	}
+
+	query := `INSERT INTO playlists (name, owner_id, guild_id, visibility, server_playlist) VALUES (?, ?, ?, ?, 1)`
+
+	result, err := db.conn.ExecContext(ctx, query, name, actor.UserID, actor.GuildID, VisibilityGuild)
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to create server playlist", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to get playlist ID", err)
+	}
+
+	return int(id), nil
+}
+
+// SetPlaylistVisibility changes who can see a playlist.
+func (db *DB) SetPlaylistVisibility(ctx context.Context, playlistID int, visibility Visibility, actor Actor) error {
+	if !visibility.Valid() {
+		return errors.NewValidationError("invalid playlist visibility")
+	}
+
+	return db.inTransaction(ctx, func(tx *Tx) error {
+		if _, err := playlistForAccess(ctx, tx, playlistID, actor, accessManage); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE playlists SET visibility = ? WHERE id = ?`, visibility, playlistID); err != nil {
+			return errors.NewDatabaseError("failed to update playlist visibility", err)
+		}
+
+		return nil
+	})
+}
+
+// AddPlaylistCollaborator lets a user edit the songs of a playlist. Adding a
+// collaborator twice has no effect.
+func (db *DB) AddPlaylistCollaborator(ctx context.Context, playlistID int, userID string, actor Actor) error {
+	return db.inTransaction(ctx, func(tx *Tx) error {
+		playlist, err := playlistForAccess(ctx, tx, playlistID, actor, accessManage)
+		if err != nil {
+			return err
+		}
+		if userID == "" || userID == playlist.OwnerID {
+			return errors.NewValidationError("the owner cannot be a collaborator")
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO playlist_collaborators (playlist_id, user_id, added_by, added_at) VALUES (?, ?, ?, ?)`,
+			playlistID, userID, actor.UserID, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return errors.NewDatabaseError("failed to add playlist collaborator", err)
+		}
+
+		return nil
+	})
+}
+
+// RemovePlaylistCollaborator takes away a user's edit rights. Collaborators
+// may remove themselves; anyone else needs to manage the playlist.
+func (db *DB) RemovePlaylistCollaborator(ctx context.Context, playlistID int, userID string, actor Actor) error {
+	level := accessManage
+	if userID == actor.UserID {
+		level = accessEdit
+	}
+
+	return db.inTransaction(ctx, func(tx *Tx) error {
+		if _, err := playlistForAccess(ctx, tx, playlistID, actor, level); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM playlist_collaborators WHERE playlist_id = ? AND user_id = ?`, playlistID, userID)
+		if err != nil {
+			return errors.NewDatabaseError("failed to remove playlist collaborator", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return errors.NewDatabaseError("failed to get rows affected", err)
+		}
+		if rowsAffected == 0 {
+			return errors.NewNotFoundError("user is not a collaborator")
+		}
+
+		return nil
+	})
+}
+
+// ClonePlaylist copies a playlist the actor can see into a new private
+// playlist the actor owns in its guild, and returns the new playlist's ID.
+// An empty name keeps the original name.
+func (db *DB) ClonePlaylist(ctx context.Context, playlistID int, name string, actor Actor) (int, error) {
+	if actor.GuildID == "" {
+		return 0, errors.NewValidationError("playlists can only be cloned into a guild")
+	}
+
+	var cloneID int
+	err := db.inTransaction(ctx, func(tx *Tx) error {
+		playlist, err := playlistForAccess(ctx, tx, playlistID, actor, accessView)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = playlist.Name
+		}
+
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO playlists (name, owner_id, guild_id) VALUES (?, ?, ?)`, name, actor.UserID, actor.GuildID)
+		if err != nil {
+			return errors.NewDatabaseError("failed to create playlist", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return errors.NewDatabaseError("failed to get playlist ID", err)
+		}
+		cloneID = int(id)
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO playlist_tracks (playlist_id, position, title, url, webpage_url, duration, source, added_by, added_at)
+		SELECT ?, position, title, url, webpage_url, duration, source, added_by, added_at
+		FROM playlist_tracks WHERE playlist_id = ? ORDER BY position
+		`, cloneID, playlistID)
+		if err != nil {
+			return errors.NewDatabaseError("failed to copy playlist songs", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return cloneID, nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// Playlist songs are rows of the playlist_tracks table. A song's position is
+// its 0-based index in the playlist; every change that moves songs renumbers
+// the positions inside one transaction, so positions stay contiguous even
+// when two users edit a playlist at once.
+
+// loadSongs returns the songs of a playlist in order.
+func loadSongs(ctx context.Context, q querier, playlistID int) ([]Song, error) {
+	query := `
+	SELECT title, url, webpage_url, duration, source, added_by, added_at
+	FROM playlist_tracks WHERE playlist_id = ? ORDER BY position
+	`
+
+	rows, err := q.QueryContext(ctx, query, playlistID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to get playlist songs", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	songs := []Song{}
+	for rows.Next() {
+		var song Song
+		var duration sql.NullInt64
+
+		err := rows.Scan(&song.Title, &song.URL, &song.WebpageURL, &duration, &song.Source, &song.AddedBy, &song.AddedAt)
+		if err != nil {
+			return nil, errors.NewDatabaseError("failed to scan playlist song row", err)
+		}
+
+		if duration.Valid {
+			seconds := int(duration.Int64)
+			song.Duration = &seconds
+		}
+
+		songs = append(songs, song)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("error iterating playlist song rows", err)
+	}
+
+	return songs, nil
+}
+
+// AddSongToPlaylist appends a song added by the actor to a playlist.
+func (db *DB) AddSongToPlaylist(ctx context.Context, playlistID int, song Song, actor Actor) error {
+	return db.editTracks(ctx, playlistID, actor, func(tx *Tx, count int) error {
+		song.AddedBy = actor.UserID
+		return insertTrack(ctx, tx, playlistID, count, song)
+	})
+}
+
+// InsertSongIntoPlaylist inserts a song at index, moving the songs from index
+// on down by one. An index equal to the number of songs appends the song.
+func (db *DB) InsertSongIntoPlaylist(ctx context.Context, playlistID, index int, song Song, actor Actor) error {
+	return db.editTracks(ctx, playlistID, actor, func(tx *Tx, count int) error {
+		if index < 0 || index > count {
+			return errors.NewValidationError("invalid song index")
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE playlist_tracks SET position = position + 1 WHERE playlist_id = ? AND position >= ?`,
+			playlistID, index); err != nil {
+			return errors.NewDatabaseError("failed to move playlist songs", err)
+		}
+
+		song.AddedBy = actor.UserID
+		return insertTrack(ctx, tx, playlistID, index, song)
+	})
+}
+
+// RemoveSongFromPlaylist removes a song from a playlist by index.
+func (db *DB) RemoveSongFromPlaylist(ctx context.Context, playlistID, songIndex int, actor Actor) error {
+	return db.editTracks(ctx, playlistID, actor, func(tx *Tx, count int) error {
+		if songIndex < 0 || songIndex >= count {
+			return errors.NewValidationError("invalid song index")
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM playlist_tracks WHERE playlist_id = ? AND position = ?`,
+			playlistID, songIndex); err != nil {
+			return errors.NewDatabaseError("failed to remove playlist song", err)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE playlist_tracks SET position = position - 1 WHERE playlist_id = ? AND position > ?`,
+			playlistID, songIndex); err != nil {
+			return errors.NewDatabaseError("failed to move playlist songs", err)
+		}
+
+		return nil
+	})
+}
+
+// MoveSongInPlaylist moves the song at index from to index to. The songs in
+// between shift by one to close the gap.
+func (db *DB) MoveSongInPlaylist(ctx context.Context, playlistID, from, to int, actor Actor) error {
+	return db.editTracks(ctx, playlistID, actor, func(tx *Tx, count int) error {
+		if from < 0 || from >= count || to < 0 || to >= count {
+			return errors.NewValidationError("invalid song index")
+		}
+		if from == to {
+			return nil
+		}
+
+		var trackID int
+		err := tx.QueryRowContext(ctx,
+			`SELECT id FROM playlist_tracks WHERE playlist_id = ? AND position = ?`,
+			playlistID, from).Scan(&trackID)
+		if err != nil {
+			return errors.NewDatabaseError("failed to get playlist song", err)
+		}
+
+		shift := `UPDATE playlist_tracks SET position = position - 1 WHERE playlist_id = ? AND position > ? AND position <= ?`
+		low, high := from, to
+		if to < from {
+			shift = `UPDATE playlist_tracks SET position = position + 1 WHERE playlist_id = ? AND position >= ? AND position < ?`
+			low, high = to, from
+		}
+		if _, err := tx.ExecContext(ctx, shift, playlistID, low, high); err != nil {
+			return errors.NewDatabaseError("failed to move playlist songs", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE playlist_tracks SET position = ? WHERE id = ?`, to, trackID); err != nil {
+			return errors.NewDatabaseError("failed to move playlist song", err)
+		}
+
+		return nil
+	})
+}
+
+// editTracks runs fn in a transaction with the number of songs in a playlist,
+// after checking that the actor may edit the playlist.
+func (db *DB) editTracks(ctx context.Context, playlistID int, actor Actor, fn func(tx *Tx, count int) error) error {
+	return db.inTransaction(ctx, func(tx *Tx) error {
+		if _, err := playlistForAccess(ctx, tx, playlistID, actor, accessEdit); err != nil {
+			return err
+		}
+
+		var count int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM playlist_tracks WHERE playlist_id = ?`, playlistID).Scan(&count)
+		if err != nil {
+			return errors.NewDatabaseError("failed to count playlist songs", err)
+		}
+
+		return fn(tx, count)
+	})
+}
+
+// insertTrack stores a song at a position. The added time is set to now.
+func insertTrack(ctx context.Context, tx *Tx, playlistID, position int, song Song) error {
+	query := `
+	INSERT INTO playlist_tracks (playlist_id, position, title, url, webpage_url, duration, source, added_by, added_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		playlistID, position, song.Title, song.URL, song.WebpageURL, song.Duration,
+		song.Source, song.AddedBy, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return errors.NewDatabaseError("failed to add playlist song", err)
+	}
+
+	return nil
+}
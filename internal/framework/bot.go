@@ -13,6 +13,7 @@ import (
 	"github.com/sawyer/go-discord-bots/internal/config"
 	"github.com/sawyer/go-discord-bots/internal/errors"
 	"github.com/sawyer/go-discord-bots/internal/logging"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
 )
 
 // CommandHandler represents a function that handles Discord bot commands.
@@ -35,7 +36,7 @@ type Bot struct {
 
 // NewBot creates a new Discord bot instance with the framework.
 func NewBot(cfg *config.BotConfig) (*Bot, error) {
-	session, err := discordgo.New("Bot " + cfg.DiscordToken)
+	session, err := discordgo.New("Bot " + cfg.DiscordToken.Value())
 	if err != nil {
 		return nil, errors.NewDiscordError("failed to create Discord session", err)
 	}
@@ -171,7 +172,7 @@ func (b *Bot) removeCommands(ctx context.Context) error {
 
 // interactionCreate handles interaction events (slash commands).
 func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.ApplicationCommandData().Name == "" {
+	if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name == "" {
 		return
 	}
 
@@ -182,9 +183,16 @@ func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCr
 		return
 	}
 
+	// Member is only set in guilds; DMs and group DMs carry the user directly
+	user := shareddiscord.InteractionUser(i.Interaction)
+	if user == nil {
+		b.logger.Warn("Interaction without a user", "command", commandName)
+		return
+	}
+
 	// Check cooldown
-	if b.isOnCooldown(i.Member.User.ID, commandName) {
-		remaining := b.getCooldownRemaining(i.Member.User.ID, commandName)
+	if b.isOnCooldown(user.ID, commandName) {
+		remaining := b.getCooldownRemaining(user.ID, commandName)
 		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -199,14 +207,14 @@ func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCr
 	}
 
 	// Set cooldown
-	b.setCooldown(i.Member.User.ID, commandName)
+	b.setCooldown(user.ID, commandName)
 
 	// Execute command
 	if err := handler(s, i); err != nil {
 		b.logger.Error("Command execution failed",
 			"command", commandName,
-			"user_id", i.Member.User.ID,
-			"username", i.Member.User.Username,
+			"user_id", user.ID,
+			"username", user.Username,
 			"error", err,
 		)
 
@@ -227,7 +235,7 @@ func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCr
 			b.logger.Error("Failed to respond to interaction with error", "error", respondErr)
 		}
 	} else {
-		logging.LogDiscordCommand(i.Member.User.ID, i.Member.User.Username, commandName, true)
+		logging.LogDiscordCommand(user.ID, user.Username, commandName, true)
 	}
 }
 
@@ -271,7 +279,7 @@ func (b *Bot) isOnCooldown(userID, commandName string) bool {
 		return false
 	}
 
-	return time.Since(lastUsed) < b.config.CommandCooldown
+	return time.Since(lastUsed) < b.config.CommandCooldown.Std()
 }
 
 // setCooldown sets a cooldown for a user and command.
@@ -302,7 +310,7 @@ func (b *Bot) getCooldownRemaining(userID, commandName string) time.Duration {
 	}
 
 	elapsed := time.Since(lastUsed)
-	remaining := b.config.CommandCooldown - elapsed
+	remaining := b.config.CommandCooldown.Std() - elapsed
 
 	if remaining < 0 {
 		return 0
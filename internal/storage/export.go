@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+)
+
+// Export format identifiers, written into every export.
+const (
+	ExportFormat  = "go-discord-bots/guild-export"
+	ExportVersion = 1
+)
+
+// GuildExport is the data a store holds about a guild, or about one user in
+// a guild, as answered to a data request.
+type GuildExport struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	GuildID    string    `json:"guild_id"`
+	UserID     string    `json:"user_id,omitempty"`
+
+	Playlists []*database.Playlist `json:"playlists"`
+	// Settings are only exported for the whole guild.
+	Settings map[string]string `json:"settings,omitempty"`
+	History  []database.Play   `json:"history"`
+	Audit    []audit.Entry     `json:"audit"`
+}
+
+// ExportGuild collects everything stored about a guild: its playlists with
+// their songs, its settings, its play history and its audit log entries.
+// With a userID the export is limited to that user: the playlists they own,
+// the tracks they requested and the commands they used.
+func (s *Store) ExportGuild(ctx context.Context, guildID, userID string) (*GuildExport, error) {
+	export := &GuildExport{
+		Format:     ExportFormat,
+		Version:    ExportVersion,
+		ExportedAt: time.Now().UTC(),
+		GuildID:    guildID,
+		UserID:     userID,
+	}
+
+	var err error
+	if userID == "" {
+		export.Playlists, err = s.backend.GetGuildPlaylists(ctx, guildID)
+		if err == nil {
+			export.Settings, err = s.backend.GuildSettings(ctx, guildID)
+		}
+	} else {
+		export.Playlists, err = s.backend.GetUserPlaylists(ctx, userID, guildID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if export.Playlists == nil {
+		export.Playlists = []*database.Playlist{}
+	}
+
+	if export.History, err = s.backend.RecentPlays(ctx, guildID, userID, 0); err != nil {
+		return nil, err
+	}
+
+	if export.Audit, err = s.audit.Query(ctx, audit.Filter{GuildID: guildID, UserID: userID}); err != nil {
+		return nil, err
+	}
+	if export.Audit == nil {
+		export.Audit = []audit.Entry{}
+	}
+
+	return export, nil
+}
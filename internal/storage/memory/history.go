@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// RecordPlay stores a track that started playing and returns its ID. A zero
+// StartedAt is set to now; ID, EndedAt and Skipped are ignored.
+func (s *Store) RecordPlay(ctx context.Context, play database.Play) (int64, error) {
+	if play.GuildID == "" {
+		return 0, errors.NewValidationError("plays need a guild ID")
+	}
+	if play.StartedAt.IsZero() {
+		play.StartedAt = time.Now()
+	}
+	play.StartedAt = play.StartedAt.UTC()
+	play.EndedAt = nil
+	play.Skipped = false
+	if play.Duration != nil {
+		duration := *play.Duration
+		play.Duration = &duration
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	play.ID = s.nextPlayID
+	s.nextPlayID++
+	s.plays = append(s.plays, play)
+	return play.ID, nil
+}
+
+// FinishPlay records when a play ended and whether it was skipped.
+func (s *Store) FinishPlay(ctx context.Context, playID int64, endedAt time.Time, skipped bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.plays {
+		if s.plays[i].ID == playID {
+			ended := endedAt.UTC()
+			s.plays[i].EndedAt = &ended
+			s.plays[i].Skipped = skipped
+			return nil
+		}
+	}
+
+	return errors.NewNotFoundError("play not found")
+}
+
+// RecentPlays returns the latest plays in a guild, newest first. A non-empty
+// requesterID limits them to the tracks that user requested; a limit of 0
+// returns every play.
+func (s *Store) RecentPlays(ctx context.Context, guildID, requesterID string, limit int) ([]database.Play, error) {
+	s.mu.RLock()
+	plays := []database.Play{}
+	for _, play := range s.plays {
+		if play.GuildID == guildID && (requesterID == "" || play.RequesterID == requesterID) {
+			plays = append(plays, copyPlay(play))
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(plays, func(i, j int) bool {
+		if !plays[i].StartedAt.Equal(plays[j].StartedAt) {
+			return plays[i].StartedAt.After(plays[j].StartedAt)
+		}
+		return plays[i].ID > plays[j].ID
+	})
+
+	if limit > 0 && len(plays) > limit {
+		plays = plays[:limit]
+	}
+	return plays, nil
+}
+
+// PlayStats summarizes the plays in a guild started at or after since; a
+// zero since covers every play. Tracks are told apart by URL and listed with
+// their latest title. At most limit top tracks and requesters are returned,
+// or all of them for a limit of 0; ties go to the one played most recently.
+func (s *Store) PlayStats(ctx context.Context, guildID string, since time.Time, limit int) (*database.PlayStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &database.PlayStats{TopTracks: []database.TrackPlays{}, TopRequesters: []database.RequesterPlays{}}
+	tracks := make(map[string]*ranked[database.TrackPlays])
+	requesters := make(map[string]*ranked[database.RequesterPlays])
+	var listened time.Duration
+
+	for _, play := range s.plays {
+		if play.GuildID != guildID || play.StartedAt.Before(since) {
+			continue
+		}
+
+		stats.Plays++
+		if play.Skipped {
+			stats.Skipped++
+		}
+		if play.EndedAt != nil && play.EndedAt.After(play.StartedAt) {
+			listened += play.EndedAt.Sub(play.StartedAt)
+		}
+
+		track, ok := tracks[play.URL]
+		if !ok {
+			track = &ranked[database.TrackPlays]{key: play.URL, item: database.TrackPlays{URL: play.URL}}
+			tracks[play.URL] = track
+		}
+		track.item.Plays++
+		if track.add(play) {
+			track.item.Title = play.Title
+		}
+
+		if play.RequesterID == "" {
+			continue
+		}
+		requester, ok := requesters[play.RequesterID]
+		if !ok {
+			requester = &ranked[database.RequesterPlays]{key: play.RequesterID, item: database.RequesterPlays{RequesterID: play.RequesterID}}
+			requesters[play.RequesterID] = requester
+		}
+		requester.item.Plays++
+		requester.add(play)
+	}
+
+	stats.Listened = int64(listened.Round(time.Second) / time.Second)
+	stats.TopTracks = append(stats.TopTracks, top(tracks, limit, func(t database.TrackPlays) int { return t.Plays })...)
+	stats.TopRequesters = append(stats.TopRequesters, top(requesters, limit, func(r database.RequesterPlays) int { return r.Plays })...)
+	return stats, nil
+}
+
+// ranked is a track or requester counted in play statistics.
+type ranked[T any] struct {
+	key  string
+	item T
+	// last is the latest play counted, which breaks ties between items.
+	last database.Play
+}
+
+// add counts a play and reports whether it is the latest play so far.
+func (r *ranked[T]) add(play database.Play) bool {
+	if r.last.ID != 0 && (play.StartedAt.Before(r.last.StartedAt) ||
+		(play.StartedAt.Equal(r.last.StartedAt) && play.ID < r.last.ID)) {
+		return false
+	}
+	r.last = play
+	return true
+}
+
+// top returns at most limit items with the most plays, or all of them for a
+// limit of 0. Ties go to the item played most recently, then by key.
+func top[T any](items map[string]*ranked[T], limit int, plays func(T) int) []T {
+	sorted := make([]*ranked[T], 0, len(items))
+	for _, item := range items {
+		sorted = append(sorted, item)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if plays(a.item) != plays(b.item) {
+			return plays(a.item) > plays(b.item)
+		}
+		if !a.last.StartedAt.Equal(b.last.StartedAt) {
+			return a.last.StartedAt.After(b.last.StartedAt)
+		}
+		return a.key < b.key
+	})
+
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	result := make([]T, 0, len(sorted))
+	for _, item := range sorted {
+		result = append(result, item.item)
+	}
+	return result
+}
+
+// copyPlay returns a play that shares no pointers with the stored one.
+func copyPlay(play database.Play) database.Play {
+	if play.Duration != nil {
+		duration := *play.Duration
+		play.Duration = &duration
+	}
+	if play.EndedAt != nil {
+		ended := *play.EndedAt
+		play.EndedAt = &ended
+	}
+	return play
+}
@@ -0,0 +1,66 @@
+// Package memory keeps playlists, guild settings and play history in memory.
+//
+// Store implements the same repositories as the SQLite database in
+// internal/database with the same behavior, including permission checks and
+// error types, but nothing survives the process. It suits tests and
+// deployments that should not write to disk.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+)
+
+// Store is an in-memory backend. It is safe for concurrent use.
+type Store struct {
+	mu sync.RWMutex
+
+	playlists      map[int]*playlist
+	nextPlaylistID int
+
+	settings map[string]map[string]string // guild -> key -> value
+
+	plays      []database.Play
+	nextPlayID int64
+}
+
+// playlist is a stored playlist. Collaborators are kept with the time they
+// were added so they can be listed in the same order as in SQLite.
+type playlist struct {
+	database.Playlist
+	collaborators []collaborator
+}
+
+// collaborator is a user allowed to edit a playlist.
+type collaborator struct {
+	userID  string
+	addedAt string
+}
+
+// New creates an empty store.
+func New() *Store {
+	return &Store{
+		playlists:      make(map[int]*playlist),
+		nextPlaylistID: 1,
+		settings:       make(map[string]map[string]string),
+		nextPlayID:     1,
+	}
+}
+
+// Close discards the stored data.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.playlists = make(map[int]*playlist)
+	s.settings = make(map[string]map[string]string)
+	s.plays = nil
+	return nil
+}
+
+// now returns the current time in the format SQLite stores added times in.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/sawyer/go-discord-bots/internal/storage"
+	"github.com/sawyer/go-discord-bots/internal/storage/memory"
+	"github.com/sawyer/go-discord-bots/internal/storage/storagetest"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storage.Store {
+		return storage.New(memory.New(), audit.NewMemoryStore())
+	})
+}
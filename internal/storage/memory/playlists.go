@@ -0,0 +1,372 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// access is a level of access to a playlist.
+type access int
+
+const (
+	accessView access = iota
+	accessEdit
+	accessManage
+)
+
+// view returns a copy of a playlist with its collaborators and songs that
+// callers may modify.
+func (p *playlist) view() *database.Playlist {
+	result := p.Playlist
+	result.Collaborators = make([]string, 0, len(p.collaborators))
+	for _, c := range p.collaborators {
+		result.Collaborators = append(result.Collaborators, c.userID)
+	}
+	result.Songs = append([]database.Song{}, p.Songs...)
+	return &result
+}
+
+// playlistForAccess returns a playlist after checking that an actor has the
+// given access to it. Playlists the actor cannot see are reported as not
+// found. The caller must hold the lock.
+func (s *Store) playlistForAccess(playlistID int, actor database.Actor, level access) (*playlist, error) {
+	p, ok := s.playlists[playlistID]
+	if !ok {
+		return nil, errors.NewNotFoundError("playlist not found")
+	}
+
+	view := p.view()
+	if !view.CanView(actor) {
+		return nil, errors.NewNotFoundError("playlist not found")
+	}
+
+	switch {
+	case level == accessEdit && !view.CanEdit(actor):
+		return nil, errors.NewPermissionError("only the owner and collaborators can edit this playlist")
+	case level == accessManage && !view.CanManage(actor):
+		return nil, errors.NewPermissionError("only the owner can manage this playlist")
+	}
+
+	return p, nil
+}
+
+// addPlaylist stores a new empty playlist and returns its ID. The caller
+// must hold the write lock.
+func (s *Store) addPlaylist(name, ownerID, guildID string, visibility database.Visibility, server bool) int {
+	id := s.nextPlaylistID
+	s.nextPlaylistID++
+
+	s.playlists[id] = &playlist{Playlist: database.Playlist{
+		ID:         id,
+		Name:       name,
+		OwnerID:    ownerID,
+		GuildID:    guildID,
+		Visibility: visibility,
+		Server:     server,
+		Songs:      []database.Song{},
+	}}
+	return id
+}
+
+// CreatePlaylist creates a new private playlist.
+func (s *Store) CreatePlaylist(ctx context.Context, name, ownerID, guildID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addPlaylist(name, ownerID, guildID, database.VisibilityPrivate, false), nil
+}
+
+// CreateServerPlaylist creates a server playlist in the actor's guild. Only
+// DJs can create them; they are visible to the whole guild.
+func (s *Store) CreateServerPlaylist(ctx context.Context, name string, actor database.Actor) (int, error) {
+	if actor.GuildID == "" || !actor.DJ {
+		return 0, errors.NewPermissionError("only DJs can create server playlists")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addPlaylist(name, actor.UserID, actor.GuildID, database.VisibilityGuild, true), nil
+}
+
+// GetPlaylist retrieves a playlist with its collaborators and songs by ID.
+// It returns nil if there is no such playlist. No permissions are checked.
+func (s *Store) GetPlaylist(ctx context.Context, playlistID int) (*database.Playlist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.playlists[playlistID]
+	if !ok {
+		return nil, nil
+	}
+	return p.view(), nil
+}
+
+// ViewPlaylist retrieves a playlist with its songs for an actor.
+func (s *Store) ViewPlaylist(ctx context.Context, playlistID int, actor database.Actor) (*database.Playlist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, err := s.playlistForAccess(playlistID, actor, accessView)
+	if err != nil {
+		return nil, err
+	}
+	return p.view(), nil
+}
+
+// GetUserPlaylists retrieves all playlists a user owns in a guild, newest
+// first.
+func (s *Store) GetUserPlaylists(ctx context.Context, ownerID, guildID string) ([]*database.Playlist, error) {
+	return s.findPlaylists(func(p *database.Playlist) bool {
+		return p.OwnerID == ownerID && p.GuildID == guildID
+	}), nil
+}
+
+// GetGuildPlaylists retrieves every playlist of a guild, newest first. No
+// permissions are checked.
+func (s *Store) GetGuildPlaylists(ctx context.Context, guildID string) ([]*database.Playlist, error) {
+	return s.findPlaylists(func(p *database.Playlist) bool {
+		return p.GuildID == guildID
+	}), nil
+}
+
+// GetPlaylistsFor retrieves the playlists an actor works with in its guild:
+// the actor's own playlists, those it collaborates on and the server
+// playlists it can see. Server playlists come first.
+func (s *Store) GetPlaylistsFor(ctx context.Context, actor database.Actor) ([]*database.Playlist, error) {
+	playlists := s.findPlaylists(func(p *database.Playlist) bool {
+		if p.GuildID != actor.GuildID {
+			return false
+		}
+		if p.OwnerID != actor.UserID && !p.Server && !p.IsCollaborator(actor.UserID) {
+			return false
+		}
+		return p.CanView(actor)
+	})
+
+	sort.SliceStable(playlists, func(i, j int) bool {
+		return playlists[i].Server && !playlists[j].Server
+	})
+	return playlists, nil
+}
+
+// findPlaylists returns the playlists matching a condition, newest first.
+func (s *Store) findPlaylists(match func(*database.Playlist) bool) []*database.Playlist {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var playlists []*database.Playlist
+	for _, p := range s.playlists {
+		if view := p.view(); match(view) {
+			playlists = append(playlists, view)
+		}
+	}
+
+	sort.Slice(playlists, func(i, j int) bool {
+		return playlists[i].ID > playlists[j].ID
+	})
+	return playlists
+}
+
+// DeletePlaylist deletes a playlist. Only users who may manage the playlist
+// can delete it.
+func (s *Store) DeletePlaylist(ctx context.Context, playlistID int, actor database.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.playlistForAccess(playlistID, actor, accessManage); err != nil {
+		return err
+	}
+
+	delete(s.playlists, playlistID)
+	return nil
+}
+
+// SetPlaylistVisibility changes who can see a playlist.
+func (s *Store) SetPlaylistVisibility(ctx context.Context, playlistID int, visibility database.Visibility, actor database.Actor) error {
+	if !visibility.Valid() {
+		return errors.NewValidationError("invalid playlist visibility")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.playlistForAccess(playlistID, actor, accessManage)
+	if err != nil {
+		return err
+	}
+
+	p.Visibility = visibility
+	return nil
+}
+
+// AddPlaylistCollaborator lets a user edit the songs of a playlist. Adding a
+// collaborator twice has no effect.
+func (s *Store) AddPlaylistCollaborator(ctx context.Context, playlistID int, userID string, actor database.Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.playlistForAccess(playlistID, actor, accessManage)
+	if err != nil {
+		return err
+	}
+	if userID == "" || userID == p.OwnerID {
+		return errors.NewValidationError("the owner cannot be a collaborator")
+	}
+
+	for _, c := range p.collaborators {
+		if c.userID == userID {
+			return nil
+		}
+	}
+
+	p.collaborators = append(p.collaborators, collaborator{userID: userID, addedAt: now()})
+	sort.SliceStable(p.collaborators, func(i, j int) bool {
+		a, b := p.collaborators[i], p.collaborators[j]
+		if a.addedAt != b.addedAt {
+			return a.addedAt < b.addedAt
+		}
+		return a.userID < b.userID
+	})
+	return nil
+}
+
+// RemovePlaylistCollaborator takes away a user's edit rights. Collaborators
+// may remove themselves; anyone else needs to manage the playlist.
+func (s *Store) RemovePlaylistCollaborator(ctx context.Context, playlistID int, userID string, actor database.Actor) error {
+	level := accessManage
+	if userID == actor.UserID {
+		level = accessEdit
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.playlistForAccess(playlistID, actor, level)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range p.collaborators {
+		if c.userID == userID {
+			p.collaborators = append(p.collaborators[:i], p.collaborators[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.NewNotFoundError("user is not a collaborator")
+}
+
+// ClonePlaylist copies a playlist the actor can see into a new private
+// playlist the actor owns in its guild, and returns the new playlist's ID.
+// An empty name keeps the original name.
+func (s *Store) ClonePlaylist(ctx context.Context, playlistID int, name string, actor database.Actor) (int, error) {
+	if actor.GuildID == "" {
+		return 0, errors.NewValidationError("playlists can only be cloned into a guild")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.playlistForAccess(playlistID, actor, accessView)
+	if err != nil {
+		return 0, err
+	}
+	if name == "" {
+		name = p.Name
+	}
+
+	cloneID := s.addPlaylist(name, actor.UserID, actor.GuildID, database.VisibilityPrivate, false)
+	s.playlists[cloneID].Songs = append([]database.Song{}, p.Songs...)
+	return cloneID, nil
+}
+
+// AddSongToPlaylist appends a song added by the actor to a playlist.
+func (s *Store) AddSongToPlaylist(ctx context.Context, playlistID int, song database.Song, actor database.Actor) error {
+	return s.editSongs(playlistID, actor, func(p *playlist) error {
+		p.Songs = append(p.Songs, newSong(song, actor))
+		return nil
+	})
+}
+
+// InsertSongIntoPlaylist inserts a song at index, moving the songs from index
+// on down by one. An index equal to the number of songs appends the song.
+func (s *Store) InsertSongIntoPlaylist(ctx context.Context, playlistID, index int, song database.Song, actor database.Actor) error {
+	return s.editSongs(playlistID, actor, func(p *playlist) error {
+		if index < 0 || index > len(p.Songs) {
+			return errors.NewValidationError("invalid song index")
+		}
+
+		p.Songs = append(p.Songs, database.Song{})
+		copy(p.Songs[index+1:], p.Songs[index:])
+		p.Songs[index] = newSong(song, actor)
+		return nil
+	})
+}
+
+// RemoveSongFromPlaylist removes a song from a playlist by index.
+func (s *Store) RemoveSongFromPlaylist(ctx context.Context, playlistID, songIndex int, actor database.Actor) error {
+	return s.editSongs(playlistID, actor, func(p *playlist) error {
+		if songIndex < 0 || songIndex >= len(p.Songs) {
+			return errors.NewValidationError("invalid song index")
+		}
+
+		p.Songs = append(p.Songs[:songIndex], p.Songs[songIndex+1:]...)
+		return nil
+	})
+}
+
+// MoveSongInPlaylist moves the song at index from to index to. The songs in
+// between shift by one to close the gap.
+func (s *Store) MoveSongInPlaylist(ctx context.Context, playlistID, from, to int, actor database.Actor) error {
+	return s.editSongs(playlistID, actor, func(p *playlist) error {
+		count := len(p.Songs)
+		if from < 0 || from >= count || to < 0 || to >= count {
+			return errors.NewValidationError("invalid song index")
+		}
+
+		song := p.Songs[from]
+		if from < to {
+			copy(p.Songs[from:to], p.Songs[from+1:to+1])
+		} else {
+			copy(p.Songs[to+1:from+1], p.Songs[to:from])
+		}
+		p.Songs[to] = song
+		return nil
+	})
+}
+
+// editSongs runs fn with a playlist after checking that the actor may edit
+// it. The songs are only changed if fn succeeds.
+func (s *Store) editSongs(playlistID int, actor database.Actor, fn func(p *playlist) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.playlistForAccess(playlistID, actor, accessEdit)
+	if err != nil {
+		return err
+	}
+
+	edited := *p
+	edited.Songs = append([]database.Song{}, p.Songs...)
+	if err := fn(&edited); err != nil {
+		return err
+	}
+
+	p.Songs = edited.Songs
+	return nil
+}
+
+// newSong returns a song as stored when an actor adds it.
+func newSong(song database.Song, actor database.Actor) database.Song {
+	song.AddedBy = actor.UserID
+	song.AddedAt = now()
+	if song.Duration != nil {
+		duration := *song.Duration
+		song.Duration = &duration
+	}
+	return song
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/sawyer/go-discord-bots/internal/errors"
+)
+
+// GuildSetting returns the value of a guild setting and whether it is set.
+func (s *Store) GuildSetting(ctx context.Context, guildID, key string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.settings[guildID][key]
+	return value, ok, nil
+}
+
+// GuildSettings returns every setting of a guild keyed by name.
+func (s *Store) GuildSettings(ctx context.Context, guildID string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings := make(map[string]string, len(s.settings[guildID]))
+	for key, value := range s.settings[guildID] {
+		settings[key] = value
+	}
+	return settings, nil
+}
+
+// SetGuildSetting stores the value of a guild setting, replacing any
+// previous value.
+func (s *Store) SetGuildSetting(ctx context.Context, guildID, key, value string) error {
+	if guildID == "" || key == "" {
+		return errors.NewValidationError("guild settings need a guild ID and a key")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.settings[guildID] == nil {
+		s.settings[guildID] = make(map[string]string)
+	}
+	s.settings[guildID][key] = value
+	return nil
+}
+
+// DeleteGuildSetting removes a guild setting. Removing a setting that is not
+// set has no effect.
+func (s *Store) DeleteGuildSetting(ctx context.Context, guildID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.settings[guildID], key)
+	if len(s.settings[guildID]) == 0 {
+		delete(s.settings, guildID)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package storage defines the repositories the bots keep their data in and
+// opens the backend selected by the storage setting.
+//
+// The repositories are interfaces so that bot code does not depend on one
+// database. internal/database implements them on SQLite and
+// internal/storage/memory in memory, for tests and deployments that should
+// not write to disk. Both behave the same, down to the errors they return;
+// storagetest checks a backend against that behavior.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+	botErrors "github.com/sawyer/go-discord-bots/internal/errors"
+	"github.com/sawyer/go-discord-bots/internal/storage/memory"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+)
+
+// Playlists stores playlists with their songs and collaborators. Methods
+// taking an Actor check the actor's access and fail with a not found error
+// for playlists the actor cannot see and a permission error for playlists
+// it cannot change.
+type Playlists interface {
+	// CreatePlaylist creates a new private playlist and returns its ID.
+	CreatePlaylist(ctx context.Context, name, ownerID, guildID string) (int, error)
+	// CreateServerPlaylist creates a server playlist in the actor's guild.
+	CreateServerPlaylist(ctx context.Context, name string, actor database.Actor) (int, error)
+	// GetPlaylist returns a playlist without checking permissions, or nil
+	// if there is no such playlist.
+	GetPlaylist(ctx context.Context, playlistID int) (*database.Playlist, error)
+	// ViewPlaylist returns a playlist the actor can see.
+	ViewPlaylist(ctx context.Context, playlistID int, actor database.Actor) (*database.Playlist, error)
+	// GetUserPlaylists returns the playlists a user owns in a guild, newest
+	// first.
+	GetUserPlaylists(ctx context.Context, ownerID, guildID string) ([]*database.Playlist, error)
+	// GetGuildPlaylists returns every playlist of a guild, newest first,
+	// without checking permissions.
+	GetGuildPlaylists(ctx context.Context, guildID string) ([]*database.Playlist, error)
+	// GetPlaylistsFor returns the playlists an actor works with in its
+	// guild, server playlists first.
+	GetPlaylistsFor(ctx context.Context, actor database.Actor) ([]*database.Playlist, error)
+	DeletePlaylist(ctx context.Context, playlistID int, actor database.Actor) error
+	SetPlaylistVisibility(ctx context.Context, playlistID int, visibility database.Visibility, actor database.Actor) error
+	AddPlaylistCollaborator(ctx context.Context, playlistID int, userID string, actor database.Actor) error
+	RemovePlaylistCollaborator(ctx context.Context, playlistID int, userID string, actor database.Actor) error
+	ClonePlaylist(ctx context.Context, playlistID int, name string, actor database.Actor) (int, error)
+
+	AddSongToPlaylist(ctx context.Context, playlistID int, song database.Song, actor database.Actor) error
+	InsertSongIntoPlaylist(ctx context.Context, playlistID, index int, song database.Song, actor database.Actor) error
+	RemoveSongFromPlaylist(ctx context.Context, playlistID, songIndex int, actor database.Actor) error
+	MoveSongInPlaylist(ctx context.Context, playlistID, from, to int, actor database.Actor) error
+}
+
+// GuildSettings stores key/value settings per guild.
+type GuildSettings interface {
+	// GuildSetting returns the value of a setting and whether it is set.
+	GuildSetting(ctx context.Context, guildID, key string) (string, bool, error)
+	// GuildSettings returns every setting of a guild keyed by name.
+	GuildSettings(ctx context.Context, guildID string) (map[string]string, error)
+	SetGuildSetting(ctx context.Context, guildID, key, value string) error
+	// DeleteGuildSetting removes a setting; removing an unset setting has
+	// no effect.
+	DeleteGuildSetting(ctx context.Context, guildID, key string) error
+}
+
+// History stores the tracks played in each guild.
+type History interface {
+	// RecordPlay stores a track that started playing and returns its ID.
+	RecordPlay(ctx context.Context, play database.Play) (int64, error)
+	// FinishPlay records when a play ended and whether it was skipped.
+	FinishPlay(ctx context.Context, playID int64, endedAt time.Time, skipped bool) error
+	// RecentPlays returns the latest plays in a guild, newest first,
+	// optionally only those requested by one user.
+	RecentPlays(ctx context.Context, guildID, requesterID string, limit int) ([]database.Play, error)
+	// PlayStats summarizes the plays in a guild since a time: totals, the
+	// most played tracks and the users who requested the most tracks.
+	PlayStats(ctx context.Context, guildID string, since time.Time, limit int) (*database.PlayStats, error)
+}
+
+// Audit stores the command audit log.
+type Audit = audit.Repository
+
+// Backend implements the bot data repositories on one database.
+type Backend interface {
+	Playlists
+	GuildSettings
+	History
+	Close() error
+}
+
+var (
+	_ Backend = (*database.DB)(nil)
+	_ Backend = (*memory.Store)(nil)
+)
+
+// Store gives access to the repositories of a backend and the audit log.
+type Store struct {
+	backend Backend
+	audit   Audit
+}
+
+// New creates a store from a backend and an audit repository. Closing the
+// store closes both.
+func New(backend Backend, auditLog Audit) *Store {
+	return &Store{backend: backend, audit: auditLog}
+}
+
+// Open opens the storage backend configured for a bot: the SQLite databases
+// of database_url and audit_database_url, or memory.
+func Open(cfg *config.Config) (*Store, error) {
+	switch cfg.Storage {
+	case config.StorageMemory:
+		return New(memory.New(), audit.NewMemoryStore()), nil
+
+	case config.StorageSQLite, "":
+		db, err := database.NewDB(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		auditLog, err := audit.Open(cfg.AuditDatabaseURL)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+
+		return New(db, auditLog), nil
+	}
+
+	return nil, botErrors.NewValidationError(fmt.Sprintf("unknown storage backend '%s'", cfg.Storage))
+}
+
+// Playlists returns the playlist repository.
+func (s *Store) Playlists() Playlists {
+	return s.backend
+}
+
+// GuildSettings returns the guild settings repository.
+func (s *Store) GuildSettings() GuildSettings {
+	return s.backend
+}
+
+// History returns the play history repository.
+func (s *Store) History() History {
+	return s.backend
+}
+
+// Audit returns the audit log repository.
+func (s *Store) Audit() Audit {
+	return s.audit
+}
+
+// Close closes the backend and the audit log.
+func (s *Store) Close() error {
+	return errors.Join(s.backend.Close(), s.audit.Close())
+}
@@ -0,0 +1,780 @@
+// Package storagetest checks that a storage backend behaves like the others.
+//
+// Run exercises every repository of a store against the behavior of the
+// SQLite backend: ordering, permission checks and the types of the errors
+// returned. New backends should pass it from a test of their own:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, openStore)
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/internal/database"
+	botErrors "github.com/sawyer/go-discord-bots/internal/errors"
+	"github.com/sawyer/go-discord-bots/internal/storage"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+)
+
+// Users and guilds the checks act as.
+const (
+	guildA = "100000000000000001"
+	guildB = "100000000000000002"
+	owner  = "200000000000000001"
+	friend = "200000000000000002"
+	other  = "200000000000000003"
+	dj     = "200000000000000004"
+)
+
+// check is one conformance check, run on a fresh store.
+type check struct {
+	name string
+	run  func(ctx context.Context, store *storage.Store) error
+}
+
+var checks = []check{
+	{"playlists/create", checkCreate},
+	{"playlists/songs", checkSongs},
+	{"playlists/visibility", checkVisibility},
+	{"playlists/collaborators", checkCollaborators},
+	{"playlists/server", checkServerPlaylists},
+	{"playlists/clone", checkClone},
+	{"playlists/delete", checkDelete},
+	{"guild_settings", checkGuildSettings},
+	{"history", checkHistory},
+	{"history/stats", checkPlayStats},
+	{"audit", checkAudit},
+}
+
+// Run runs every check as a subtest of t, named after the check, on a new
+// store from open. open fails the subtest if the store cannot be opened. Each
+// store is closed after its check.
+func Run(t *testing.T, open func(t *testing.T) *storage.Store) {
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			store := open(t)
+			t.Cleanup(func() {
+				if err := store.Close(); err != nil {
+					t.Errorf("closing store: %v", err)
+				}
+			})
+
+			if err := c.run(context.Background(), store); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// expectError fails unless err is a *BotError of the given type.
+func expectError(what string, err error, errorType botErrors.ErrorType) error {
+	if !botErrors.IsErrorType(err, errorType) {
+		return fmt.Errorf("%s: got error %v, want a %s error", what, err, errorType)
+	}
+	return nil
+}
+
+// songTitles returns the titles of a playlist's songs.
+func songTitles(playlist *database.Playlist) []string {
+	titles := make([]string, len(playlist.Songs))
+	for i, song := range playlist.Songs {
+		titles[i] = song.Title
+	}
+	return titles
+}
+
+// equal reports whether two string slices have the same elements in order.
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkCreate(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+
+	first, err := playlists.CreatePlaylist(ctx, "First", owner, guildA)
+	if err != nil {
+		return err
+	}
+	second, err := playlists.CreatePlaylist(ctx, "Second", owner, guildA)
+	if err != nil {
+		return err
+	}
+	if _, err := playlists.CreatePlaylist(ctx, "Elsewhere", owner, guildB); err != nil {
+		return err
+	}
+	if first == second {
+		return fmt.Errorf("two playlists got ID %d", first)
+	}
+
+	playlist, err := playlists.GetPlaylist(ctx, first)
+	if err != nil {
+		return err
+	}
+	switch {
+	case playlist == nil:
+		return fmt.Errorf("created playlist %d not found", first)
+	case playlist.ID != first || playlist.Name != "First" || playlist.OwnerID != owner || playlist.GuildID != guildA:
+		return fmt.Errorf("GetPlaylist returned %+v", playlist)
+	case playlist.Visibility != database.VisibilityPrivate || playlist.Server:
+		return fmt.Errorf("new playlist is %s, server %t; want private", playlist.Visibility, playlist.Server)
+	case playlist.Songs == nil || len(playlist.Songs) != 0:
+		return fmt.Errorf("new playlist has songs %v, want an empty list", playlist.Songs)
+	case playlist.Collaborators == nil || len(playlist.Collaborators) != 0:
+		return fmt.Errorf("new playlist has collaborators %v, want an empty list", playlist.Collaborators)
+	}
+
+	if missing, err := playlists.GetPlaylist(ctx, second+100); err != nil || missing != nil {
+		return fmt.Errorf("GetPlaylist of a missing playlist returned %v, %v; want nil, nil", missing, err)
+	}
+
+	owned, err := playlists.GetUserPlaylists(ctx, owner, guildA)
+	if err != nil {
+		return err
+	}
+	if len(owned) != 2 || owned[0].ID != second || owned[1].ID != first {
+		return fmt.Errorf("GetUserPlaylists returned %d playlists, want Second then First", len(owned))
+	}
+
+	if _, err := playlists.CreatePlaylist(ctx, "Theirs", other, guildA); err != nil {
+		return err
+	}
+	inGuild, err := playlists.GetGuildPlaylists(ctx, guildA)
+	if err != nil {
+		return err
+	}
+	if len(inGuild) != 3 || inGuild[0].OwnerID != other || inGuild[2].ID != first {
+		return fmt.Errorf("GetGuildPlaylists returned %d playlists, want the guild's three newest first", len(inGuild))
+	}
+
+	return nil
+}
+
+func checkSongs(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+	actor := database.Actor{UserID: owner, GuildID: guildA}
+
+	id, err := playlists.CreatePlaylist(ctx, "Mix", owner, guildA)
+	if err != nil {
+		return err
+	}
+
+	duration := 215
+	for _, title := range []string{"a", "b", "c"} {
+		song := database.Song{Title: title, URL: "https://example.com/" + title, Duration: &duration, Source: "Youtube", AddedBy: other}
+		if err := playlists.AddSongToPlaylist(ctx, id, song, actor); err != nil {
+			return err
+		}
+	}
+	if err := playlists.InsertSongIntoPlaylist(ctx, id, 0, database.Song{Title: "first"}, actor); err != nil {
+		return err
+	}
+	if err := playlists.InsertSongIntoPlaylist(ctx, id, 4, database.Song{Title: "last"}, actor); err != nil {
+		return err
+	}
+	if err := playlists.MoveSongInPlaylist(ctx, id, 1, 3, actor); err != nil {
+		return err
+	}
+	if err := playlists.MoveSongInPlaylist(ctx, id, 4, 0, actor); err != nil {
+		return err
+	}
+	if err := playlists.RemoveSongFromPlaylist(ctx, id, 2, actor); err != nil {
+		return err
+	}
+
+	playlist, err := playlists.GetPlaylist(ctx, id)
+	if err != nil {
+		return err
+	}
+	if want := []string{"last", "first", "c", "a"}; !equal(songTitles(playlist), want) {
+		return fmt.Errorf("songs are %v, want %v", songTitles(playlist), want)
+	}
+
+	song := playlist.Songs[3]
+	switch {
+	case song.AddedBy != owner:
+		return fmt.Errorf("song added by %q, want the actor %q", song.AddedBy, owner)
+	case song.AddedAt == "":
+		return fmt.Errorf("song has no added time")
+	case song.Duration == nil || *song.Duration != duration || song.Source != "Youtube" || song.URL != "https://example.com/a":
+		return fmt.Errorf("song fields were not kept: %+v", song)
+	}
+
+	for what, err := range map[string]error{
+		"insert past the end": playlists.InsertSongIntoPlaylist(ctx, id, 5, database.Song{Title: "x"}, actor),
+		"remove past the end": playlists.RemoveSongFromPlaylist(ctx, id, 4, actor),
+		"move past the end":   playlists.MoveSongInPlaylist(ctx, id, 0, 4, actor),
+		"negative index":      playlists.RemoveSongFromPlaylist(ctx, id, -1, actor),
+	} {
+		if err := expectError(what, err, botErrors.ErrorTypeValidation); err != nil {
+			return err
+		}
+	}
+
+	err = playlists.AddSongToPlaylist(ctx, id+100, database.Song{Title: "x"}, actor)
+	return expectError("adding to a missing playlist", err, botErrors.ErrorTypeNotFound)
+}
+
+func checkVisibility(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+	ownerActor := database.Actor{UserID: owner, GuildID: guildA}
+	sameGuild := database.Actor{UserID: other, GuildID: guildA}
+	otherGuild := database.Actor{UserID: other, GuildID: guildB}
+
+	id, err := playlists.CreatePlaylist(ctx, "Secret", owner, guildA)
+	if err != nil {
+		return err
+	}
+
+	if _, err := playlists.ViewPlaylist(ctx, id, ownerActor); err != nil {
+		return fmt.Errorf("owner cannot view own playlist: %w", err)
+	}
+	_, err = playlists.ViewPlaylist(ctx, id, sameGuild)
+	if err := expectError("viewing a private playlist", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+
+	err = playlists.SetPlaylistVisibility(ctx, id, "everyone", ownerActor)
+	if err := expectError("setting an invalid visibility", err, botErrors.ErrorTypeValidation); err != nil {
+		return err
+	}
+	err = playlists.SetPlaylistVisibility(ctx, id, database.VisibilityPublic, sameGuild)
+	if err := expectError("sharing a private playlist of someone else", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+
+	if err := playlists.SetPlaylistVisibility(ctx, id, database.VisibilityGuild, ownerActor); err != nil {
+		return err
+	}
+	if _, err := playlists.ViewPlaylist(ctx, id, sameGuild); err != nil {
+		return fmt.Errorf("guild member cannot view a guild playlist: %w", err)
+	}
+	_, err = playlists.ViewPlaylist(ctx, id, otherGuild)
+	if err := expectError("viewing a guild playlist from another guild", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+	err = playlists.AddSongToPlaylist(ctx, id, database.Song{Title: "x"}, sameGuild)
+	if err := expectError("editing a guild playlist", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+	err = playlists.SetPlaylistVisibility(ctx, id, database.VisibilityPublic, sameGuild)
+	if err := expectError("sharing a guild playlist of someone else", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+
+	if err := playlists.SetPlaylistVisibility(ctx, id, database.VisibilityPublic, ownerActor); err != nil {
+		return err
+	}
+	playlist, err := playlists.ViewPlaylist(ctx, id, otherGuild)
+	if err != nil {
+		return fmt.Errorf("anyone cannot view a public playlist: %w", err)
+	}
+	if playlist.Visibility != database.VisibilityPublic {
+		return fmt.Errorf("visibility is %s, want public", playlist.Visibility)
+	}
+
+	return nil
+}
+
+func checkCollaborators(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+	ownerActor := database.Actor{UserID: owner, GuildID: guildA}
+	friendActor := database.Actor{UserID: friend, GuildID: guildA}
+	otherActor := database.Actor{UserID: other, GuildID: guildA}
+
+	id, err := playlists.CreatePlaylist(ctx, "Shared", owner, guildA)
+	if err != nil {
+		return err
+	}
+
+	err = playlists.AddPlaylistCollaborator(ctx, id, owner, ownerActor)
+	if err := expectError("adding the owner as collaborator", err, botErrors.ErrorTypeValidation); err != nil {
+		return err
+	}
+	for _, userID := range []string{friend, other, friend} {
+		if err := playlists.AddPlaylistCollaborator(ctx, id, userID, ownerActor); err != nil {
+			return err
+		}
+	}
+
+	playlist, err := playlists.GetPlaylist(ctx, id)
+	if err != nil {
+		return err
+	}
+	if want := []string{friend, other}; !equal(playlist.Collaborators, want) {
+		return fmt.Errorf("collaborators are %v, want %v", playlist.Collaborators, want)
+	}
+
+	if err := playlists.AddSongToPlaylist(ctx, id, database.Song{Title: "x"}, friendActor); err != nil {
+		return fmt.Errorf("collaborator cannot add songs: %w", err)
+	}
+	err = playlists.SetPlaylistVisibility(ctx, id, database.VisibilityGuild, friendActor)
+	if err := expectError("collaborator sharing the playlist", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+	err = playlists.DeletePlaylist(ctx, id, friendActor)
+	if err := expectError("collaborator deleting the playlist", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+	err = playlists.RemovePlaylistCollaborator(ctx, id, other, friendActor)
+	if err := expectError("collaborator removing someone else", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+
+	found, err := playlists.GetPlaylistsFor(ctx, friendActor)
+	if err != nil {
+		return err
+	}
+	if len(found) != 1 || found[0].ID != id {
+		return fmt.Errorf("GetPlaylistsFor a collaborator returned %d playlists, want the shared one", len(found))
+	}
+
+	if err := playlists.RemovePlaylistCollaborator(ctx, id, friend, friendActor); err != nil {
+		return fmt.Errorf("collaborator cannot leave: %w", err)
+	}
+	if err := playlists.RemovePlaylistCollaborator(ctx, id, other, ownerActor); err != nil {
+		return err
+	}
+	err = playlists.RemovePlaylistCollaborator(ctx, id, other, ownerActor)
+	if err := expectError("removing a user who is not a collaborator", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+
+	_, err = playlists.ViewPlaylist(ctx, id, otherActor)
+	return expectError("former collaborator viewing the playlist", err, botErrors.ErrorTypeNotFound)
+}
+
+func checkServerPlaylists(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+	djActor := database.Actor{UserID: dj, GuildID: guildA, DJ: true}
+	otherDJ := database.Actor{UserID: other, GuildID: guildA, DJ: true}
+	member := database.Actor{UserID: friend, GuildID: guildA}
+
+	_, err := playlists.CreateServerPlaylist(ctx, "Radio", member)
+	if err := expectError("non-DJ creating a server playlist", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+
+	own, err := playlists.CreatePlaylist(ctx, "Mine", friend, guildA)
+	if err != nil {
+		return err
+	}
+	radio, err := playlists.CreateServerPlaylist(ctx, "Radio", djActor)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := playlists.ViewPlaylist(ctx, radio, member)
+	if err != nil {
+		return fmt.Errorf("guild member cannot view a server playlist: %w", err)
+	}
+	if !playlist.Server || playlist.Visibility != database.VisibilityGuild || playlist.OwnerID != dj {
+		return fmt.Errorf("server playlist is %+v", playlist)
+	}
+
+	if err := playlists.AddSongToPlaylist(ctx, radio, database.Song{Title: "x"}, otherDJ); err != nil {
+		return fmt.Errorf("another DJ cannot edit a server playlist: %w", err)
+	}
+	if err := playlists.SetPlaylistVisibility(ctx, radio, database.VisibilityPublic, otherDJ); err != nil {
+		return fmt.Errorf("another DJ cannot manage a server playlist: %w", err)
+	}
+	err = playlists.AddSongToPlaylist(ctx, radio, database.Song{Title: "x"}, member)
+	if err := expectError("non-DJ editing a server playlist", err, botErrors.ErrorTypePermission); err != nil {
+		return err
+	}
+
+	found, err := playlists.GetPlaylistsFor(ctx, member)
+	if err != nil {
+		return err
+	}
+	if len(found) != 2 || found[0].ID != radio || found[1].ID != own {
+		return fmt.Errorf("GetPlaylistsFor returned %d playlists, want the server playlist first", len(found))
+	}
+
+	return nil
+}
+
+func checkClone(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+	ownerActor := database.Actor{UserID: owner, GuildID: guildA}
+	cloner := database.Actor{UserID: other, GuildID: guildB}
+
+	id, err := playlists.CreatePlaylist(ctx, "Original", owner, guildA)
+	if err != nil {
+		return err
+	}
+	for _, title := range []string{"a", "b"} {
+		if err := playlists.AddSongToPlaylist(ctx, id, database.Song{Title: title}, ownerActor); err != nil {
+			return err
+		}
+	}
+
+	_, err = playlists.ClonePlaylist(ctx, id, "", cloner)
+	if err := expectError("cloning a private playlist", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+	_, err = playlists.ClonePlaylist(ctx, id, "", database.Actor{UserID: owner})
+	if err := expectError("cloning outside a guild", err, botErrors.ErrorTypeValidation); err != nil {
+		return err
+	}
+
+	if err := playlists.SetPlaylistVisibility(ctx, id, database.VisibilityPublic, ownerActor); err != nil {
+		return err
+	}
+	cloneID, err := playlists.ClonePlaylist(ctx, id, "", cloner)
+	if err != nil {
+		return err
+	}
+
+	clone, err := playlists.GetPlaylist(ctx, cloneID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case clone == nil || cloneID == id:
+		return fmt.Errorf("clone %d not created", cloneID)
+	case clone.Name != "Original" || clone.OwnerID != other || clone.GuildID != guildB:
+		return fmt.Errorf("clone is %+v", clone)
+	case clone.Visibility != database.VisibilityPrivate || clone.Server:
+		return fmt.Errorf("clone is %s, server %t; want private", clone.Visibility, clone.Server)
+	case !equal(songTitles(clone), []string{"a", "b"}):
+		return fmt.Errorf("clone has songs %v", songTitles(clone))
+	case clone.Songs[0].AddedBy != owner:
+		return fmt.Errorf("clone song added by %q, want the original %q", clone.Songs[0].AddedBy, owner)
+	}
+
+	if err := playlists.RemoveSongFromPlaylist(ctx, cloneID, 0, cloner); err != nil {
+		return err
+	}
+	original, err := playlists.GetPlaylist(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(original.Songs) != 2 {
+		return fmt.Errorf("editing the clone changed the original")
+	}
+
+	return nil
+}
+
+func checkDelete(ctx context.Context, store *storage.Store) error {
+	playlists := store.Playlists()
+	ownerActor := database.Actor{UserID: owner, GuildID: guildA}
+
+	id, err := playlists.CreatePlaylist(ctx, "Doomed", owner, guildA)
+	if err != nil {
+		return err
+	}
+	if err := playlists.AddSongToPlaylist(ctx, id, database.Song{Title: "x"}, ownerActor); err != nil {
+		return err
+	}
+	if err := playlists.AddPlaylistCollaborator(ctx, id, friend, ownerActor); err != nil {
+		return err
+	}
+
+	err = playlists.DeletePlaylist(ctx, id, database.Actor{UserID: other, GuildID: guildA})
+	if err := expectError("deleting someone else's playlist", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+	if err := playlists.DeletePlaylist(ctx, id, ownerActor); err != nil {
+		return err
+	}
+
+	if playlist, err := playlists.GetPlaylist(ctx, id); err != nil || playlist != nil {
+		return fmt.Errorf("deleted playlist still found: %v, %v", playlist, err)
+	}
+	found, err := playlists.GetPlaylistsFor(ctx, database.Actor{UserID: friend, GuildID: guildA})
+	if err != nil {
+		return err
+	}
+	if len(found) != 0 {
+		return fmt.Errorf("deleted playlist still listed for its collaborator")
+	}
+
+	err = playlists.DeletePlaylist(ctx, id, ownerActor)
+	return expectError("deleting a deleted playlist", err, botErrors.ErrorTypeNotFound)
+}
+
+func checkGuildSettings(ctx context.Context, store *storage.Store) error {
+	settings := store.GuildSettings()
+
+	err := settings.SetGuildSetting(ctx, "", "dj_role", "DJ")
+	if err := expectError("setting without a guild", err, botErrors.ErrorTypeValidation); err != nil {
+		return err
+	}
+
+	if _, ok, err := settings.GuildSetting(ctx, guildA, "dj_role"); err != nil || ok {
+		return fmt.Errorf("unset setting reported as set (%v)", err)
+	}
+	for _, set := range [][3]string{
+		{guildA, "dj_role", "DJ"},
+		{guildA, "dj_role", "Music"},
+		{guildA, "volume", "0.3"},
+		{guildB, "volume", "0.8"},
+	} {
+		if err := settings.SetGuildSetting(ctx, set[0], set[1], set[2]); err != nil {
+			return err
+		}
+	}
+
+	value, ok, err := settings.GuildSetting(ctx, guildA, "dj_role")
+	if err != nil || !ok || value != "Music" {
+		return fmt.Errorf("GuildSetting returned %q, %t, %v; want the latest value", value, ok, err)
+	}
+
+	all, err := settings.GuildSettings(ctx, guildA)
+	if err != nil {
+		return err
+	}
+	if len(all) != 2 || all["dj_role"] != "Music" || all["volume"] != "0.3" {
+		return fmt.Errorf("GuildSettings returned %v", all)
+	}
+
+	if err := settings.DeleteGuildSetting(ctx, guildA, "volume"); err != nil {
+		return err
+	}
+	if err := settings.DeleteGuildSetting(ctx, guildA, "volume"); err != nil {
+		return fmt.Errorf("deleting an unset setting: %w", err)
+	}
+	if _, ok, _ := settings.GuildSetting(ctx, guildA, "volume"); ok {
+		return fmt.Errorf("deleted setting still set")
+	}
+	if value, _, _ := settings.GuildSetting(ctx, guildB, "volume"); value != "0.8" {
+		return fmt.Errorf("deleting a setting changed another guild")
+	}
+
+	empty, err := settings.GuildSettings(ctx, "100000000000000009")
+	if err != nil {
+		return err
+	}
+	if empty == nil || len(empty) != 0 {
+		return fmt.Errorf("GuildSettings of a guild without settings returned %v, want an empty map", empty)
+	}
+
+	return nil
+}
+
+func checkHistory(ctx context.Context, store *storage.Store) error {
+	history := store.History()
+	start := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	_, err := history.RecordPlay(ctx, database.Play{Title: "x"})
+	if err := expectError("recording without a guild", err, botErrors.ErrorTypeValidation); err != nil {
+		return err
+	}
+
+	duration := 180
+	var ids []int64
+	for i, play := range []database.Play{
+		{GuildID: guildA, RequesterID: owner, Title: "one", URL: "https://example.com/1", Duration: &duration},
+		{GuildID: guildA, RequesterID: friend, Title: "two", URL: "https://example.com/2"},
+		{GuildID: guildB, RequesterID: owner, Title: "elsewhere", URL: "https://example.com/3"},
+		{GuildID: guildA, RequesterID: owner, Title: "three", URL: "https://example.com/4"},
+	} {
+		play.StartedAt = start.Add(time.Duration(i) * time.Minute)
+		id, err := history.RecordPlay(ctx, play)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	ended := start.Add(2 * time.Minute)
+	if err := history.FinishPlay(ctx, ids[0], ended, true); err != nil {
+		return err
+	}
+	err = history.FinishPlay(ctx, ids[3]+100, ended, false)
+	if err := expectError("finishing a missing play", err, botErrors.ErrorTypeNotFound); err != nil {
+		return err
+	}
+
+	plays, err := history.RecentPlays(ctx, guildA, "", 0)
+	if err != nil {
+		return err
+	}
+	var titles []string
+	for _, play := range plays {
+		titles = append(titles, play.Title)
+	}
+	if want := []string{"three", "two", "one"}; !equal(titles, want) {
+		return fmt.Errorf("RecentPlays returned %v, want %v", titles, want)
+	}
+
+	first := plays[2]
+	switch {
+	case first.ID != ids[0] || first.RequesterID != owner || first.URL != "https://example.com/1":
+		return fmt.Errorf("play is %+v", first)
+	case first.Duration == nil || *first.Duration != duration:
+		return fmt.Errorf("play duration not kept")
+	case !first.StartedAt.Equal(start):
+		return fmt.Errorf("play started at %v, want %v", first.StartedAt, start)
+	case first.EndedAt == nil || !first.EndedAt.Equal(ended) || !first.Skipped:
+		return fmt.Errorf("finished play has end %v, skipped %t", first.EndedAt, first.Skipped)
+	case plays[0].EndedAt != nil || plays[0].Skipped || plays[1].Duration != nil:
+		return fmt.Errorf("unfinished play is %+v", plays[0])
+	}
+
+	mine, err := history.RecentPlays(ctx, guildA, owner, 1)
+	if err != nil {
+		return err
+	}
+	if len(mine) != 1 || mine[0].Title != "three" {
+		return fmt.Errorf("RecentPlays of one requester with limit 1 returned %d plays", len(mine))
+	}
+
+	none, err := history.RecentPlays(ctx, "100000000000000009", "", 10)
+	if err != nil {
+		return err
+	}
+	if none == nil || len(none) != 0 {
+		return fmt.Errorf("RecentPlays of a guild without plays returned %v, want an empty list", none)
+	}
+
+	return nil
+}
+
+func checkPlayStats(ctx context.Context, store *storage.Store) error {
+	history := store.History()
+	start := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	// Tracks 1 and 2 are both played twice; 2 was played last and renamed
+	plays := []struct {
+		requester, title, url string
+		listened              time.Duration
+		skipped               bool
+	}{
+		{owner, "one", "https://example.com/1", 3 * time.Minute, false},
+		{friend, "two", "https://example.com/2", 90*time.Second + 400*time.Millisecond, true},
+		{owner, "one", "https://example.com/1", 3 * time.Minute, false},
+		{friend, "three", "https://example.com/3", 2 * time.Minute, false},
+		{owner, "two (remastered)", "https://example.com/2", 0, false},
+	}
+	for i, p := range plays {
+		startedAt := start.Add(time.Duration(i) * 10 * time.Minute)
+		id, err := history.RecordPlay(ctx, database.Play{
+			GuildID: guildA, RequesterID: p.requester, Title: p.title, URL: p.url, StartedAt: startedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if p.listened > 0 {
+			if err := history.FinishPlay(ctx, id, startedAt.Add(p.listened), p.skipped); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := history.RecordPlay(ctx, database.Play{GuildID: guildB, RequesterID: other, Title: "elsewhere", URL: "https://example.com/1", StartedAt: start}); err != nil {
+		return err
+	}
+
+	stats, err := history.PlayStats(ctx, guildA, time.Time{}, 2)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stats.Plays != 5 || stats.Skipped != 1:
+		return fmt.Errorf("PlayStats counted %d plays, %d skipped, want 5 and 1", stats.Plays, stats.Skipped)
+	case stats.Listened != 3*60+90+3*60+2*60:
+		return fmt.Errorf("PlayStats listened %d seconds, want %d", stats.Listened, 3*60+90+3*60+2*60)
+	}
+
+	want := []database.TrackPlays{
+		{Title: "two (remastered)", URL: "https://example.com/2", Plays: 2},
+		{Title: "one", URL: "https://example.com/1", Plays: 2},
+	}
+	if len(stats.TopTracks) != len(want) || stats.TopTracks[0] != want[0] || stats.TopTracks[1] != want[1] {
+		return fmt.Errorf("PlayStats top tracks are %+v, want %+v", stats.TopTracks, want)
+	}
+	wantRequesters := []database.RequesterPlays{{RequesterID: owner, Plays: 3}, {RequesterID: friend, Plays: 2}}
+	if len(stats.TopRequesters) != 2 || stats.TopRequesters[0] != wantRequesters[0] || stats.TopRequesters[1] != wantRequesters[1] {
+		return fmt.Errorf("PlayStats top requesters are %+v, want %+v", stats.TopRequesters, wantRequesters)
+	}
+
+	recent, err := history.PlayStats(ctx, guildA, start.Add(25*time.Minute), 0)
+	if err != nil {
+		return err
+	}
+	if recent.Plays != 2 || len(recent.TopTracks) != 2 || recent.TopTracks[0].URL != "https://example.com/2" {
+		return fmt.Errorf("PlayStats since a time returned %+v", recent)
+	}
+
+	empty, err := history.PlayStats(ctx, "100000000000000009", time.Time{}, 10)
+	if err != nil {
+		return err
+	}
+	if empty.Plays != 0 || empty.Listened != 0 || empty.TopTracks == nil || len(empty.TopTracks) != 0 || empty.TopRequesters == nil {
+		return fmt.Errorf("PlayStats of a guild without plays returned %+v", empty)
+	}
+
+	return nil
+}
+
+func checkAudit(ctx context.Context, store *storage.Store) error {
+	log := store.Audit()
+	start := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	for i, entry := range []audit.Entry{
+		{Bot: "music", GuildID: guildA, UserID: owner, Command: "play", Success: true, Latency: 1500 * time.Microsecond},
+		{Bot: "music", GuildID: guildA, UserID: friend, Command: "skip", Success: false, ErrorType: "permission"},
+		{Bot: "mtg", GuildID: guildB, UserID: owner, Command: "card", Success: true},
+		{Bot: "music", GuildID: guildA, UserID: owner, Command: "play", Success: true},
+	} {
+		entry.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := log.Insert(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	entries, err := log.Query(ctx, audit.Filter{Bot: "music", UserID: owner})
+	if err != nil {
+		return err
+	}
+	if len(entries) != 2 || !entries[0].Time.Equal(start.Add(3*time.Hour)) || !entries[1].Time.Equal(start) {
+		return fmt.Errorf("Query returned %d entries, want the owner's two music commands newest first", len(entries))
+	}
+	if entries[1].Latency != time.Millisecond || entries[0].ID == entries[1].ID {
+		return fmt.Errorf("entry is %+v, want a latency of 1ms and distinct IDs", entries[1])
+	}
+
+	entries, err = log.Query(ctx, audit.Filter{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)})
+	if err != nil {
+		return err
+	}
+	if len(entries) != 2 || entries[0].Command != "card" || entries[1].ErrorType != "permission" {
+		return fmt.Errorf("Query of a time window returned %d entries", len(entries))
+	}
+
+	entries, err = log.Query(ctx, audit.Filter{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 || entries[0].Command != "play" {
+		return fmt.Errorf("Query with limit 1 returned %d entries", len(entries))
+	}
+
+	removed, err := log.Prune(ctx, start.Add(2*time.Hour))
+	if err != nil {
+		return err
+	}
+	entries, err = log.Query(ctx, audit.Filter{})
+	if err != nil {
+		return err
+	}
+	if removed != 2 || len(entries) != 2 {
+		return fmt.Errorf("Prune removed %d entries and kept %d, want 2 and 2", removed, len(entries))
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+// maxLineLength is the longest line buffered from a bot; longer lines are
+// split.
+const maxLineLength = 64 * 1024
+
+// logMux writes the output of the launcher and its bots to one writer a whole
+// line at a time, so lines of different bots never interleave.
+type logMux struct {
+	out   io.Writer
+	width int // width of the widest "[name]" prefix
+	mu    sync.Mutex
+}
+
+// newLogMux creates a mux writing to out with prefixes aligned for apps.
+func newLogMux(out io.Writer, apps []botApp) *logMux {
+	m := &logMux{out: out}
+	for _, app := range apps {
+		if width := len(app.name) + 2; width > m.width {
+			m.width = width
+		}
+	}
+	return m
+}
+
+// printf writes a launcher message.
+func (m *logMux) printf(format string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _ = fmt.Fprintf(m.out, format, args...)
+}
+
+// botLog attributes the output of one bot. In text mode lines are prefixed
+// with the bot name; in JSON mode a "bot" field is added to each record. If
+// file is set, the bot's lines are also written to it unchanged.
+type botLog struct {
+	mux  *logMux
+	name string
+	json bool
+	file *logging.RotatingFile
+}
+
+// newBotLog creates the log of a bot. With a non-empty dir its lines are also
+// written to dir/<name>.log, rotated at maxSize bytes keeping maxFiles files.
+func newBotLog(mux *logMux, name string, jsonFormat bool, dir string, maxSize int64, maxFiles int) (*botLog, error) {
+	l := &botLog{mux: mux, name: name, json: jsonFormat}
+	if dir != "" {
+		file, err := logging.NewRotatingFile(filepath.Join(dir, name+".log"), maxSize, maxFiles)
+		if err != nil {
+			return nil, err
+		}
+		l.file = file
+	}
+	return l, nil
+}
+
+// writer returns a writer for one output stream of the bot. Lines written to
+// stderr that are not JSON records are logged at error level in JSON mode.
+func (l *botLog) writer(stderr bool) *lineWriter {
+	return &lineWriter{log: l, stderr: stderr}
+}
+
+// writeLine writes one line without its newline.
+func (l *botLog) writeLine(line []byte, stderr bool) {
+	if l.file != nil {
+		data := make([]byte, 0, len(line)+1)
+		_, _ = l.file.Write(append(append(data, line...), '\n'))
+	}
+
+	var tagged []byte
+	switch {
+	case !l.json:
+		tagged = []byte(fmt.Sprintf("%-*s %s\n", l.mux.width, "["+l.name+"]", line))
+	case isJSONObject(line):
+		tagged = l.injectBot(line)
+	default:
+		tagged = l.wrap(line, stderr)
+	}
+
+	l.mux.mu.Lock()
+	defer l.mux.mu.Unlock()
+	_, _ = l.mux.out.Write(tagged)
+}
+
+// injectBot adds the bot field to a JSON record, keeping its other fields as
+// they are.
+func (l *botLog) injectBot(record []byte) []byte {
+	name, _ := json.Marshal(l.name)
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"bot":`)
+	buf.Write(name)
+	rest := bytes.TrimSpace(bytes.TrimSpace(record)[1:])
+	if !bytes.Equal(rest, []byte("}")) {
+		buf.WriteByte(',')
+	}
+	buf.Write(rest)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// wrap turns a plain line, such as a panic trace, into a JSON record.
+func (l *botLog) wrap(line []byte, stderr bool) []byte {
+	level := "INFO"
+	if stderr {
+		level = "ERROR"
+	}
+
+	record, _ := json.Marshal(struct {
+		Bot   string `json:"bot"`
+		Time  string `json:"time"`
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}{l.name, time.Now().Format("2006-01-02T15:04:05.000Z07:00"), level, string(line)})
+	return append(record, '\n')
+}
+
+// close closes the bot's log file.
+func (l *botLog) close() error {
+	if l.file != nil {
+		return l.file.Close()
+	}
+	return nil
+}
+
+// isJSONObject reports whether line is a complete JSON object.
+func isJSONObject(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	return len(trimmed) > 1 && trimmed[0] == '{' && json.Valid(trimmed)
+}
+
+// lineWriter splits a stream into lines for a botLog. It is used as the
+// Stdout or Stderr of a bot process.
+type lineWriter struct {
+	log    *botLog
+	stderr bool
+	buf    []byte
+}
+
+// Write buffers p and writes every complete line.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log.writeLine(bytes.TrimSuffix(w.buf[:i], []byte("\r")), w.stderr)
+		w.buf = w.buf[i+1:]
+	}
+
+	if len(w.buf) >= maxLineLength {
+		w.flush()
+	}
+
+	return len(p), nil
+}
+
+// flush writes a trailing partial line.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.log.writeLine(w.buf, w.stderr)
+		w.buf = nil
+	}
+}
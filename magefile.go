@@ -90,7 +90,7 @@ func Build() error {
 	}
 
 	// Build individual apps
-	apps := []string{"clippy", "music", "mtg-card-bot"}
+	apps := []string{"clippy", "music", "mtg-card-bot", "plugin-host"}
 	for _, app := range apps {
 		fmt.Printf("Building %s app...\n", app)
 		appBinaryPath := filepath.Join(buildDir, app)
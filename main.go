@@ -3,33 +3,78 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"time"
 
+	"github.com/sawyer/go-discord-bots/pkg/config"
 	"github.com/spf13/cobra"
 )
 
 var (
-	botFlag    string
-	configFlag string
-	debugFlag  bool
+	botFlag         string
+	configFlag      string
+	setFlag         config.Overrides
+	printConfigFlag bool
+	debugFlag       bool
+	isolateFlag     bool
+	monitorPortFlag int
+
+	restartFlag       string
+	maxRestartsFlag   int
+	restartWindowFlag time.Duration
+	backoffFlag       time.Duration
+	maxBackoffFlag    time.Duration
+	stopTimeoutFlag   time.Duration
+
+	logDirFlag      string
+	logMaxSizeFlag  int
+	logMaxFilesFlag int
 )
 
 func main() {
+	// Load environment variables from .env file
+	if err := config.LoadEnvFile(".env"); err != nil {
+		fmt.Printf("Warning: failed to load .env file: %v\n", err)
+	}
+
 	rootCmd := &cobra.Command{
 		Use:   "go-discord-bots",
 		Short: "A multi-bot Discord framework",
 		Long:  "Discord Bot Framework - Run multiple Discord bots from a single application",
 		Run:   runBot,
+		// Errors are printed below; usage is only shown with --help
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 
-	rootCmd.Flags().StringVarP(&botFlag, "bot", "b", "", "Bot to run (clippy, music, all)")
-	rootCmd.Flags().StringVarP(&configFlag, "config", "c", "config.json", "Configuration file path")
+	rootCmd.Flags().StringVarP(&botFlag, "bot", "b", "", "Bots to run, comma-separated (clippy, music, mtg, plugins, all)")
+	rootCmd.PersistentFlags().StringVarP(&configFlag, "config", "c", config.GetString("CONFIG_FILE", "config.json"), "Configuration file path")
+	rootCmd.PersistentFlags().Var(&setFlag, "set", "Override a config field for every bot (log_level=debug) or one bot (mtg.cache_size=2000); repeatable")
+	rootCmd.Flags().BoolVar(&printConfigFlag, "print-config", false, "Print the effective configuration of the selected bots with secrets redacted and exit")
 	rootCmd.Flags().BoolVarP(&debugFlag, "debug", "d", false, "Enable debug mode")
+	rootCmd.Flags().IntVar(&monitorPortFlag, "monitor-port", config.GetInt("MONITOR_PORT", 9090), "Port of the monitoring HTTP server (0 to disable)")
+
+	// Supervision of bots run as separate processes
+	rootCmd.Flags().BoolVar(&isolateFlag, "isolate", false, "Run each bot as a supervised process from bin/ instead of in this process")
+	rootCmd.Flags().StringVar(&restartFlag, "restart", "on-failure", "Restart policy (always, on-failure, never), optionally per bot: on-failure,music=always")
+	rootCmd.Flags().IntVar(&maxRestartsFlag, "max-restarts", 5, "Restarts allowed per bot within --restart-window before the launcher gives up")
+	rootCmd.Flags().DurationVar(&restartWindowFlag, "restart-window", 10*time.Minute, "Window in which restarts count against --max-restarts")
+	rootCmd.Flags().DurationVar(&backoffFlag, "restart-backoff", time.Second, "Delay before the first restart; doubles for each consecutive restart")
+	rootCmd.Flags().DurationVar(&maxBackoffFlag, "max-restart-backoff", time.Minute, "Longest delay between restarts")
+	rootCmd.Flags().DurationVar(&stopTimeoutFlag, "stop-timeout", 30*time.Second, "Time bots get to shut down after SIGINT/SIGTERM before they are killed")
+
+	rootCmd.Flags().StringVar(&logDirFlag, "log-dir", config.GetString("LOG_DIR", ""), "Also write each bot's output to <dir>/<bot>.log")
+	rootCmd.Flags().IntVar(&logMaxSizeFlag, "log-max-size", 10, "Size in MB at which --log-dir files are rotated")
+	rootCmd.Flags().IntVar(&logMaxFilesFlag, "log-max-files", 5, "Rotated --log-dir files kept per bot")
 
 	_ = rootCmd.MarkFlagRequired("bot")
 
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newCommandsCmd())
+	rootCmd.AddCommand(newDBCmd())
+	rootCmd.AddCommand(newStatusCmd())
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -37,87 +82,100 @@ func main() {
 }
 
 func runBot(cmd *cobra.Command, args []string) {
-	fmt.Printf("Starting Discord Bot Framework - Bot: %s\n", botFlag)
-
-	// Get the directory where the main binary is located
-	binaryDir := filepath.Dir(os.Args[0])
-	if binaryDir == "." {
-		// If running with 'go run', use current directory
-		binaryDir, _ = os.Getwd()
+	apps, err := selectBots(botFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	switch strings.ToLower(botFlag) {
-	case "clippy":
-		if err := runSingleApp("clippy", binaryDir); err != nil {
-			fmt.Printf("Error running Clippy bot: %v\n", err)
+	if printConfigFlag {
+		if err := printConfigs(apps); err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
-	case "music":
-		if err := runSingleApp("music", binaryDir); err != nil {
-			fmt.Printf("Error running Music bot: %v\n", err)
-			os.Exit(1)
-		}
-	case "mtg":
-		if err := runSingleApp("mtg-card-bot", binaryDir); err != nil {
-			fmt.Printf("Error running MTG Card bot: %v\n", err)
-			os.Exit(1)
-		}
-	case "all":
-		if err := runAllApps(binaryDir); err != nil {
-			fmt.Printf("Error running all bots: %v\n", err)
-			os.Exit(1)
-		}
-	default:
-		fmt.Printf("Invalid bot specified: %s\n", botFlag)
-		fmt.Println("Valid options: clippy, music, mtg, all")
+		return
+	}
+
+	opts, err := supervision()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-}
 
-func runSingleApp(appName, binaryDir string) error {
-	appPath := filepath.Join("bin", appName)
+	fmt.Printf("Starting Discord Bot Framework - Bot: %s\n", botFlag)
 
-	// Check if binary exists
-	if _, err := os.Stat(appPath); os.IsNotExist(err) {
-		return fmt.Errorf("app binary not found: %s (run 'mage build' first)", appPath)
+	if isolateFlag {
+		err = runIsolated(apps, opts)
+	} else {
+		err = runInProcess(apps, opts)
 	}
+	if err != nil {
+		fmt.Printf("Error running bots: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-	fmt.Printf("Starting %s bot...\n", appName)
-
-	cmd := exec.Command(appPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+// supervision returns the restart policies, crash budget and stop timeout
+// selected by the launcher flags. They apply in both modes, like the --log-*
+// flags it checks as well.
+func supervision() (supervisorOptions, error) {
+	opts := supervisorOptions{
+		maxRestarts:   maxRestartsFlag,
+		restartWindow: restartWindowFlag,
+		backoff:       backoffFlag,
+		maxBackoff:    maxBackoffFlag,
+		stopTimeout:   stopTimeoutFlag,
+	}
+	if err := parseRestartPolicies(restartFlag, &opts); err != nil {
+		return opts, err
+	}
 
-	return cmd.Run()
-}
+	for name := range opts.policies {
+		if _, ok := findBot(name); !ok {
+			return opts, fmt.Errorf("unknown bot '%s' in --restart, must be one of: clippy, music, mtg, plugins", name)
+		}
+	}
 
-func runAllApps(binaryDir string) error {
-	apps := []string{"clippy", "music", "mtg-card-bot"}
+	if logMaxSizeFlag < 1 || logMaxFilesFlag < 0 {
+		return opts, fmt.Errorf("--log-max-size must be at least 1 and --log-max-files cannot be negative")
+	}
 
-	fmt.Println("Starting all bots...")
+	return opts, nil
+}
 
-	// Start all apps concurrently
+// runIsolated runs each bot as a supervised child process from bin/.
+func runIsolated(apps []botApp, opts supervisorOptions) error {
+	var found []botApp
 	for _, app := range apps {
-		appPath := filepath.Join("bin", app)
-
 		// Check if binary exists
-		if _, err := os.Stat(appPath); os.IsNotExist(err) {
-			fmt.Printf("Warning: %s binary not found, skipping\n", app)
+		if _, err := os.Stat(filepath.Join("bin", app.binary)); os.IsNotExist(err) {
+			fmt.Printf("Warning: %s binary not found, skipping\n", app.binary)
 			continue
 		}
+		found = append(found, app)
+	}
 
-		go func(appName string) {
-			fmt.Printf("Starting %s...\n", appName)
-			cmd := exec.Command(filepath.Join("bin", appName))
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			if err := cmd.Run(); err != nil {
-				fmt.Printf("Error running %s: %v\n", appName, err)
-			}
-		}(app)
+	if len(found) == 0 {
+		return fmt.Errorf("no app binaries found in bin (run 'mage build' first)")
+	}
+
+	// Tag each bot's output with its name, using the log format the bot
+	// will be configured with
+	mux := newLogMux(os.Stdout, found)
+	logs := make(map[string]*botLog)
+	for _, app := range found {
+		cfg, err := loadBotConfig(app)
+		if err != nil {
+			return fmt.Errorf("%s: %w", app.name, err)
+		}
+
+		log, err := newBotLog(mux, app.name, cfg.JSONLogging, logDirFlag, int64(logMaxSizeFlag)<<20, logMaxFilesFlag)
+		if err != nil {
+			return fmt.Errorf("%s: %w", app.name, err)
+		}
+		defer log.close()
+		logs[app.name] = log
 	}
 
-	// Keep the main process running
-	select {}
+	return newSupervisor(opts, mux, logs).run(found)
 }
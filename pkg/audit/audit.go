@@ -0,0 +1,236 @@
+// Package audit provides a persistent log of command invocations across all bots.
+//
+// Bots hand entries to a Recorder, which writes them to a shared SQLite store in
+// the background and prunes entries older than the configured retention. The
+// store can be queried by guild, user, command and time window from Discord
+// (the audit command) or from the launcher CLI.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/database"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+)
+
+// Entry is a single recorded command invocation.
+type Entry struct {
+	ID           int64         `json:"id"`
+	Time         time.Time     `json:"time"`
+	Bot          string        `json:"bot"`
+	GuildID      string        `json:"guild_id,omitempty"`
+	ChannelID    string        `json:"channel_id,omitempty"`
+	UserID       string        `json:"user_id"`
+	Username     string        `json:"username,omitempty"`
+	Command      string        `json:"command"`
+	Options      string        `json:"options,omitempty"`
+	Success      bool          `json:"success"`
+	Latency      time.Duration `json:"latency"`
+	ErrorType    string        `json:"error_type,omitempty"`
+	ErrorMessage string        `json:"error_message,omitempty"`
+}
+
+// Filter selects audit entries. Zero values match everything.
+type Filter struct {
+	Bot     string
+	GuildID string
+	UserID  string
+	Command string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// Repository stores audit entries. Store keeps them in SQLite and MemoryStore
+// in memory.
+type Repository interface {
+	// Insert stores an entry, stamping it with the current time if it has
+	// none.
+	Insert(ctx context.Context, entry Entry) error
+	// Query returns entries matching the filter, newest first.
+	Query(ctx context.Context, filter Filter) ([]Entry, error)
+	// Prune deletes entries recorded before the given time and returns how
+	// many were removed.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+	// Close releases the repository.
+	Close() error
+}
+
+// Store persists audit entries in SQLite.
+type Store struct {
+	conn *database.Handle
+}
+
+// Open opens (and if necessary creates) the audit store at path. Bots in the
+// same process share the connection pool of one path.
+func Open(path string) (*Store, error) {
+	conn, err := database.Open(path)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to open audit database", err)
+	}
+
+	store := &Store{conn: conn}
+	if err := store.migrate(); err != nil {
+		_ = conn.Close()
+		return nil, errors.NewDatabaseError("failed to migrate audit database", err)
+	}
+
+	return store, nil
+}
+
+// Close closes the database connection.
+func (s *Store) Close() error {
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// CheckBackup checks that the database at path, usually a backup about to
+// be restored, holds an audit log. The file is opened read-only.
+func CheckBackup(ctx context.Context, path string) error {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.NewDatabaseError("failed to open backup", err)
+	}
+	defer conn.Close()
+
+	var tables int
+	err = conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'command_audit'").Scan(&tables)
+	if err != nil {
+		return errors.NewDatabaseError("backup is not a valid SQLite database", err)
+	}
+	if tables == 0 {
+		return errors.NewValidationError("backup has no command_audit table; it is not an audit database")
+	}
+	return nil
+}
+
+// migrate creates the audit table.
+func (s *Store) migrate() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS command_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME NOT NULL,
+		bot TEXT NOT NULL,
+		guild_id TEXT NOT NULL DEFAULT '',
+		channel_id TEXT NOT NULL DEFAULT '',
+		user_id TEXT NOT NULL,
+		username TEXT NOT NULL DEFAULT '',
+		command TEXT NOT NULL,
+		options TEXT NOT NULL DEFAULT '',
+		success INTEGER NOT NULL,
+		latency_ms INTEGER NOT NULL,
+		error_type TEXT NOT NULL DEFAULT '',
+		error_message TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_command_audit_created ON command_audit(created_at);
+	CREATE INDEX IF NOT EXISTS idx_command_audit_guild_created ON command_audit(guild_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_command_audit_user ON command_audit(user_id);
+	`
+
+	_, err := s.conn.Exec(query)
+	return err
+}
+
+// Insert stores an entry.
+func (s *Store) Insert(ctx context.Context, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	query := `
+	INSERT INTO command_audit (created_at, bot, guild_id, channel_id, user_id, username, command, options, success, latency_ms, error_type, error_message)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.conn.ExecContext(ctx, query,
+		entry.Time.UTC(), entry.Bot, entry.GuildID, entry.ChannelID, entry.UserID, entry.Username,
+		entry.Command, entry.Options, entry.Success, entry.Latency.Milliseconds(), entry.ErrorType, entry.ErrorMessage,
+	)
+	if err != nil {
+		return errors.NewDatabaseError("failed to insert audit entry", err)
+	}
+
+	return nil
+}
+
+// Query returns entries matching the filter, newest first.
+func (s *Store) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Bot != "" {
+		conditions = append(conditions, "bot = ?")
+		args = append(args, filter.Bot)
+	}
+	if filter.GuildID != "" {
+		conditions = append(conditions, "guild_id = ?")
+		args = append(args, filter.GuildID)
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Command != "" {
+		conditions = append(conditions, "command = ?")
+		args = append(args, filter.Command)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := `SELECT id, created_at, bot, guild_id, channel_id, user_id, username, command, options, success, latency_ms, error_type, error_message FROM command_audit`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to query audit log", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var latencyMS int64
+		if err := rows.Scan(&entry.ID, &entry.Time, &entry.Bot, &entry.GuildID, &entry.ChannelID, &entry.UserID,
+			&entry.Username, &entry.Command, &entry.Options, &entry.Success, &latencyMS, &entry.ErrorType, &entry.ErrorMessage); err != nil {
+			return nil, errors.NewDatabaseError("failed to scan audit entry", err)
+		}
+		entry.Latency = time.Duration(latencyMS) * time.Millisecond
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.NewDatabaseError("failed to read audit log", err)
+	}
+
+	return entries, nil
+}
+
+// Prune deletes entries recorded before the given time and returns how many were removed.
+func (s *Store) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, "DELETE FROM command_audit WHERE created_at < ?", before.UTC())
+	if err != nil {
+		return 0, errors.NewDatabaseError("failed to prune audit log", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/discord"
+)
+
+// defaultQueryLimit is the number of entries shown by the audit command.
+const defaultQueryLimit = 20
+
+// FromInteraction builds an entry describing an application command or
+// component interaction. Outcome fields are filled in by RecordResult.
+func FromInteraction(i *discordgo.InteractionCreate) Entry {
+	entry := Entry{
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+	}
+
+	if user := discord.InteractionUser(i.Interaction); user != nil {
+		entry.UserID = user.ID
+		entry.Username = user.Username
+	}
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		data := i.ApplicationCommandData()
+		entry.Command = data.Name
+		entry.Options = encodeOptions(data.Options)
+	case discordgo.InteractionMessageComponent:
+		entry.Command = "component:" + i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		entry.Command = "modal:" + i.ModalSubmitData().CustomID
+	}
+
+	return entry
+}
+
+// FromMessage builds an entry describing a prefix command.
+func FromMessage(m *discordgo.MessageCreate, command string, args []string) Entry {
+	entry := Entry{
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		Command:   command,
+	}
+
+	if m.Author != nil {
+		entry.UserID = m.Author.ID
+		entry.Username = m.Author.Username
+	}
+
+	if len(args) > 0 {
+		entry.Options = strings.Join(args, " ")
+	}
+
+	return entry
+}
+
+// encodeOptions flattens command options into JSON. Subcommands are recorded
+// under the "subcommand" key with their own options merged in.
+func encodeOptions(options []*discordgo.ApplicationCommandInteractionDataOption) string {
+	if len(options) == 0 {
+		return ""
+	}
+
+	values := make(map[string]interface{})
+	var walk func(opts []*discordgo.ApplicationCommandInteractionDataOption, path string)
+	walk = func(opts []*discordgo.ApplicationCommandInteractionDataOption, path string) {
+		for _, opt := range opts {
+			switch opt.Type {
+			case discordgo.ApplicationCommandOptionSubCommand, discordgo.ApplicationCommandOptionSubCommandGroup:
+				name := strings.TrimSpace(path + " " + opt.Name)
+				values["subcommand"] = name
+				walk(opt.Options, name)
+			default:
+				values[opt.Name] = opt.Value
+			}
+		}
+	}
+	walk(options, "")
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// AdminCommand returns the slash command for querying the audit log. Only
+// members with Manage Server can see it.
+func AdminCommand(name string) *discordgo.ApplicationCommand {
+	manageGuild := int64(discordgo.PermissionManageServer)
+	minHours := 1.0
+
+	return discord.GuildOnly(&discordgo.ApplicationCommand{
+		Name:                     name,
+		Description:              "Show recent command usage in this server",
+		DefaultMemberPermissions: &manageGuild,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Only show commands from this user",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "command",
+				Description: "Only show this command",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "hours",
+				Description: "How far back to look (default 24)",
+				MinValue:    &minHours,
+				MaxValue:    24 * 90,
+			},
+		},
+	})
+}
+
+// HandleAdminCommand serves the command created by AdminCommand. Results are
+// always limited to the guild the command was used in.
+func (r *Recorder) HandleAdminCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.GuildID == "" {
+		return respondEphemeral(s, i, "❌ The audit log can only be viewed in a server.")
+	}
+	if i.Member == nil || i.Member.Permissions&discordgo.PermissionManageServer == 0 {
+		return respondEphemeral(s, i, "🚫 You need the Manage Server permission to view the audit log.")
+	}
+
+	filter := Filter{
+		GuildID: i.GuildID,
+		Since:   time.Now().Add(-24 * time.Hour),
+		Limit:   defaultQueryLimit,
+	}
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "user":
+			if user := option.UserValue(nil); user != nil {
+				filter.UserID = user.ID
+			}
+		case "command":
+			filter.Command = strings.TrimPrefix(option.StringValue(), "/")
+		case "hours":
+			filter.Since = time.Now().Add(-time.Duration(option.IntValue()) * time.Hour)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entries, err := r.store.Query(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{formatEmbed(entries, filter)},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// formatEmbed renders audit entries for Discord.
+func formatEmbed(entries []Entry, filter Filter) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title: "📋 Command Audit Log",
+		Color: 0x3498DB,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Since %s • Showing up to %d entries", filter.Since.UTC().Format("2006-01-02 15:04 MST"), filter.Limit),
+		},
+	}
+
+	if len(entries) == 0 {
+		embed.Description = "No matching commands."
+		return embed
+	}
+
+	var sb strings.Builder
+	for _, entry := range entries {
+		line := FormatLine(entry, true) + "\n"
+		if sb.Len()+len(line) > 4000 {
+			break
+		}
+		sb.WriteString(line)
+	}
+	embed.Description = sb.String()
+
+	return embed
+}
+
+// FormatLine renders a single entry on one line. With mentions set, users and
+// timestamps use Discord markup.
+func FormatLine(entry Entry, mentions bool) string {
+	outcome := "✅"
+	if !entry.Success {
+		outcome = "❌ " + entry.ErrorType
+	}
+
+	when := entry.Time.UTC().Format(time.RFC3339)
+	user := entry.Username
+	if mentions {
+		when = fmt.Sprintf("<t:%d:f>", entry.Time.Unix())
+		user = "<@" + entry.UserID + ">"
+	}
+
+	line := fmt.Sprintf("%s %s [%s] `/%s`", when, user, entry.Bot, entry.Command)
+	if entry.Options != "" {
+		line += " " + entry.Options
+	}
+	return fmt.Sprintf("%s %s (%dms)", line, outcome, entry.Latency.Milliseconds())
+}
+
+// respondEphemeral sends a message only the invoking user can see.
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps audit entries in memory. It behaves like Store, including
+// the millisecond precision of latencies, and is meant for tests and
+// deployments that should not write to disk. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+	nextID  int64
+}
+
+// NewMemoryStore creates an empty in-memory audit store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+// Close discards the stored entries.
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+	return nil
+}
+
+// Insert stores an entry.
+func (s *MemoryStore) Insert(ctx context.Context, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.Latency = entry.Latency.Truncate(time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.ID = s.nextID
+	s.nextID++
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Query returns entries matching the filter, newest first.
+func (s *MemoryStore) Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	s.mu.RLock()
+	var entries []Entry
+	for _, entry := range s.entries {
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.After(entries[j].Time)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// Prune deletes entries recorded before the given time and returns how many were removed.
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		if !entry.Time.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	removed := int64(len(s.entries) - len(kept))
+	s.entries = kept
+	return removed, nil
+}
+
+// matches reports whether an entry is selected by the filter.
+func (f Filter) matches(entry Entry) bool {
+	switch {
+	case f.Bot != "" && entry.Bot != f.Bot:
+		return false
+	case f.GuildID != "" && entry.GuildID != f.GuildID:
+		return false
+	case f.UserID != "" && entry.UserID != f.UserID:
+		return false
+	case f.Command != "" && entry.Command != f.Command:
+		return false
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && entry.Time.After(f.Until):
+		return false
+	}
+	return true
+}
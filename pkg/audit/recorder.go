@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	botErrors "github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+// recorderBuffer is the number of entries that can wait to be written.
+const recorderBuffer = 1000
+
+// pruneInterval is how often expired entries are removed.
+const pruneInterval = time.Hour
+
+// Recorder writes audit entries in the background so command handling never
+// waits on the database.
+type Recorder struct {
+	store     Repository
+	bot       string
+	retention time.Duration
+	entries   chan Entry
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+}
+
+// NewRecorder creates a recorder for a bot. Entries older than retention are
+// pruned periodically; a retention of zero keeps entries forever.
+func NewRecorder(store Repository, bot string, retention time.Duration) *Recorder {
+	return &Recorder{
+		store:     store,
+		bot:       bot,
+		retention: retention,
+		entries:   make(chan Entry, recorderBuffer),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// OpenRecorder opens the audit store configured for a bot and returns a
+// recorder for it. With the memory storage backend entries are kept in
+// memory instead. The recorder closes the store when stopped.
+func OpenRecorder(cfg *config.Config) (*Recorder, error) {
+	var store Repository = NewMemoryStore()
+	if cfg.Storage != config.StorageMemory {
+		sqlStore, err := Open(cfg.AuditDatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		store = sqlStore
+	}
+
+	return NewRecorder(store, string(cfg.BotType), cfg.AuditRetention.Std()), nil
+}
+
+// Start starts the background writer and pruner.
+func (r *Recorder) Start() {
+	r.wg.Add(2)
+	go r.writeLoop()
+	go r.pruneLoop()
+}
+
+// Stop flushes pending entries, stops the background goroutines and closes
+// the store.
+func (r *Recorder) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+		r.wg.Wait()
+
+		if err := r.store.Close(); err != nil {
+			logger := logging.BotComponent(r.bot, "audit")
+			logger.Error("Failed to close audit database", "error", err)
+		}
+	})
+}
+
+// Store returns the underlying audit store.
+func (r *Recorder) Store() Repository {
+	return r.store
+}
+
+// Record queues an entry. If the queue is full the entry is dropped rather
+// than blocking the caller.
+func (r *Recorder) Record(entry Entry) {
+	if r == nil {
+		return
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Bot == "" {
+		entry.Bot = r.bot
+	}
+
+	select {
+	case r.entries <- entry:
+	default:
+		metrics.RecordPerformanceMetric("audit", "dropped_entries", 1, "count")
+	}
+}
+
+// RecordResult is a convenience for recording the outcome of a command.
+func (r *Recorder) RecordResult(entry Entry, start time.Time, err error) {
+	entry.Latency = time.Since(start)
+	entry.Success = err == nil
+	if err != nil {
+		entry.ErrorType = ErrorType(err)
+		entry.ErrorMessage = err.Error()
+	}
+	r.Record(entry)
+}
+
+// writeLoop writes queued entries until stopped, then drains the queue.
+func (r *Recorder) writeLoop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case entry := <-r.entries:
+			r.write(entry)
+		case <-r.stopCh:
+			for {
+				select {
+				case entry := <-r.entries:
+					r.write(entry)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write stores a single entry, logging failures.
+func (r *Recorder) write(entry Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.store.Insert(ctx, entry); err != nil {
+		logger := logging.BotComponent(r.bot, "audit")
+		logging.LogError(logger, err, "Failed to write audit entry")
+	}
+}
+
+// pruneLoop removes expired entries on startup and then periodically.
+func (r *Recorder) pruneLoop() {
+	defer r.wg.Done()
+
+	if r.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		r.prune()
+
+		select {
+		case <-ticker.C:
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// prune deletes entries older than the retention period.
+func (r *Recorder) prune() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	logger := logging.BotComponent(r.bot, "audit")
+	removed, err := r.store.Prune(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		logging.LogError(logger, err, "Failed to prune audit log")
+		return
+	}
+	if removed > 0 {
+		logger.Info("Pruned audit log", "removed", removed, "retention", r.retention)
+	}
+}
+
+// ErrorType returns the bot error type of err, or "unknown" for other errors.
+func ErrorType(err error) string {
+	if err == nil {
+		return ""
+	}
+	var botErr *botErrors.BotError
+	if errors.As(err, &botErr) {
+		return string(botErr.ErrorType)
+	}
+	return "unknown"
+}
@@ -2,11 +2,12 @@
 package config
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -20,119 +21,202 @@ const (
 	BotTypeMusic BotType = "music"
 	// BotTypeMTG represents the MTG Card bot.
 	BotTypeMTG BotType = "mtg"
+	// BotTypePlugins represents the plugin host bot.
+	BotTypePlugins BotType = "plugins"
 )
 
+// Storage backends.
+const (
+	// StorageSQLite keeps data in the SQLite databases of database_url and
+	// audit_database_url.
+	StorageSQLite = "sqlite"
+	// StorageMemory keeps data in memory; it is lost when the bot stops.
+	StorageMemory = "memory"
+)
+
+// PluginConfig describes an out-of-process plugin started by the plugin host.
+type PluginConfig struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Dir     string   `json:"dir,omitempty"`
+}
+
+// FeatureFlag configures a feature flag. A flag is on for a guild if the guild
+// is listed in Guilds, if Enabled is set, or if the guild falls inside the
+// Rollout percentage. DisabledGuilds always wins over the other settings.
+type FeatureFlag struct {
+	Enabled        bool     `json:"enabled"`
+	Rollout        int      `json:"rollout,omitempty"`
+	Guilds         []string `json:"guilds,omitempty"`
+	DisabledGuilds []string `json:"disabled_guilds,omitempty"`
+}
+
+// PresenceActivity is one entry in a bot's rotating Discord status. Type is
+// playing, listening, watching, competing or custom; Text is a text/template
+// rendered with live values such as {{.Guilds}}.
+type PresenceActivity struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// presenceActivityTypes lists the accepted PresenceActivity types.
+var presenceActivityTypes = map[string]bool{
+	"playing":   true,
+	"listening": true,
+	"watching":  true,
+	"competing": true,
+	"custom":    true,
+}
+
 // Config represents the unified configuration structure for all bots.
 type Config struct {
 	// Bot identification
 	BotType       BotType `json:"bot_type"`
 	BotName       string  `json:"bot_name"`
-	DiscordToken  string  `json:"discord_token"`
+	DiscordToken  Secret  `json:"discord_token,omitempty"`
 	CommandPrefix string  `json:"command_prefix"`
 
 	// Server configuration
 	GuildID string `json:"guild_id,omitempty"`
 
+	// Channels commands may be used in; empty allows every channel
+	AllowedChannels []string `json:"allowed_channels,omitempty"`
+
+	// Per-guild overrides, keyed by guild ID. Bots read them through ConfigFor.
+	Guilds map[string]GuildConfig `json:"guilds,omitempty"`
+
 	// Behavior settings
-	DebugMode       bool          `json:"debug_mode"`
-	LogLevel        string        `json:"log_level"`
-	JSONLogging     bool          `json:"json_logging"`
-	CommandCooldown time.Duration `json:"command_cooldown"`
-	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
-	RequestTimeout  time.Duration `json:"request_timeout"`
-	MaxRetries      int           `json:"max_retries"`
+	DebugMode       bool     `json:"debug_mode"`
+	LogLevel        string   `json:"log_level"`
+	JSONLogging     bool     `json:"json_logging"`
+	CommandCooldown Duration `json:"command_cooldown"`
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	RequestTimeout  Duration `json:"request_timeout"`
+	MaxRetries      int      `json:"max_retries"`
+
+	// Outbound messages queued per channel before new ones are dropped
+	OutboundQueueSize int `json:"outbound_queue_size"`
 
 	// Feature flags
-	RandomResponses    bool          `json:"random_responses,omitempty"`
-	RandomInterval     time.Duration `json:"random_interval,omitempty"`
-	RandomMessageDelay time.Duration `json:"random_message_delay,omitempty"`
+	RandomResponses    bool     `json:"random_responses,omitempty"`
+	RandomInterval     Duration `json:"random_interval,omitempty"`
+	RandomMessageDelay Duration `json:"random_message_delay,omitempty"`
 
 	// Cache settings
-	CacheTTL  time.Duration `json:"cache_ttl,omitempty"`
-	CacheSize int           `json:"cache_size,omitempty"`
+	CacheTTL  Duration `json:"cache_ttl,omitempty"`
+	CacheSize int      `json:"cache_size,omitempty"`
 
 	// Music bot specific settings
-	MaxQueueSize      int           `json:"max_queue_size,omitempty"`
-	InactivityTimeout time.Duration `json:"inactivity_timeout,omitempty"`
-	VolumeLevel       float64       `json:"volume_level,omitempty"`
+	MaxQueueSize      int      `json:"max_queue_size,omitempty"`
+	InactivityTimeout Duration `json:"inactivity_timeout,omitempty"`
+	VolumeLevel       float64  `json:"volume_level,omitempty"`
 
-	// Database settings
+	// Database settings. Storage selects the backend of playlists, guild
+	// settings, history and the audit log: StorageSQLite or StorageMemory.
 	DatabaseURL string `json:"database_url,omitempty"`
-}
+	Storage     string `json:"storage,omitempty"`
 
-// Load loads configuration for a specific bot type.
-func Load(botType BotType) (*Config, error) {
-	cfg := getDefaultConfig(botType)
+	// Command audit log
+	AuditDatabaseURL string   `json:"audit_database_url,omitempty"`
+	AuditRetention   Duration `json:"audit_retention,omitempty"`
 
-	// Load from environment variables
-	if err := cfg.loadFromEnvironment(); err != nil {
-		return nil, fmt.Errorf("failed to load environment configuration: %w", err)
-	}
+	// Feature flags, keyed by flag name, and the file storing guild overrides
+	Features     map[string]FeatureFlag `json:"features,omitempty"`
+	FeaturesFile string                 `json:"features_file,omitempty"`
 
-	return cfg, nil
-}
+	// Plugin host settings
+	Plugins   []PluginConfig `json:"plugins,omitempty"`
+	PluginDir string         `json:"plugin_dir,omitempty"`
 
-// LoadFromFile loads configuration from a JSON file and environment variables.
-func LoadFromFile(configPath string, botType BotType) (*Config, error) {
-	cfg := getDefaultConfig(botType)
-
-	// Load from config file if provided and exists
-	if configPath != "" {
-		if _, err := os.Stat(configPath); err == nil {
-			data, err := os.ReadFile(configPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read config file: %w", err)
-			}
+	// Rotating Discord status. With PresenceNowPlaying the music bot shows the
+	// current track instead while it is in a single guild.
+	Presence           []PresenceActivity `json:"presence,omitempty"`
+	PresenceInterval   Duration           `json:"presence_interval,omitempty"`
+	PresenceNowPlaying bool               `json:"presence_now_playing,omitempty"`
 
-			if err := json.Unmarshal(data, cfg); err != nil {
-				return nil, fmt.Errorf("failed to parse config file: %w", err)
-			}
-		}
-	}
+	// ConfigFile is the file the configuration was loaded from, if any. Bots
+	// watch it for changes.
+	ConfigFile string `json:"-"`
 
-	// Override with environment variables
-	if err := cfg.loadFromEnvironment(); err != nil {
-		return nil, fmt.Errorf("failed to load environment configuration: %w", err)
-	}
+	// flags are the command-line overrides the configuration was loaded
+	// with; reloads apply them again.
+	flags Overrides
+}
+
+// Load loads configuration for a specific bot type from defaults and
+// environment variables.
+func Load(botType BotType) (*Config, error) {
+	return LoadWith(botType, LoadOptions{})
+}
 
-	return cfg, nil
+// LoadFromFile loads configuration from a JSON, YAML or TOML file and
+// environment variables.
+func LoadFromFile(configPath string, botType BotType) (*Config, error) {
+	return LoadWith(botType, LoadOptions{Path: configPath})
 }
 
 // getDefaultConfig returns default configuration for a bot type.
 func getDefaultConfig(botType BotType) *Config {
 	base := &Config{
-		BotType:         botType,
-		CommandPrefix:   "!",
-		LogLevel:        "info",
-		JSONLogging:     false,
-		DebugMode:       false,
-		CommandCooldown: 3 * time.Second,
-		ShutdownTimeout: 30 * time.Second,
-		RequestTimeout:  30 * time.Second,
-		MaxRetries:      3,
+		BotType:           botType,
+		CommandPrefix:     "!",
+		LogLevel:          "info",
+		JSONLogging:       false,
+		DebugMode:         false,
+		CommandCooldown:   Duration(3 * time.Second),
+		ShutdownTimeout:   Duration(30 * time.Second),
+		RequestTimeout:    Duration(30 * time.Second),
+		MaxRetries:        3,
+		OutboundQueueSize: 50,
+		FeaturesFile:      string(botType) + "-features.json",
+		Storage:           StorageSQLite,
+		AuditDatabaseURL:  "audit.db",
+		AuditRetention:    Duration(90 * 24 * time.Hour),
+		PresenceInterval:  Duration(5 * time.Minute),
 	}
 
 	switch botType {
 	case BotTypeClipper:
 		base.BotName = "Clippy Bot"
-		base.CommandCooldown = 5 * time.Second
+		base.CommandCooldown = Duration(5 * time.Second)
 		base.RandomResponses = true
-		base.RandomInterval = 45 * time.Minute
-		base.RandomMessageDelay = 3 * time.Second
+		base.RandomInterval = Duration(45 * time.Minute)
+		base.RandomMessageDelay = Duration(3 * time.Second)
+		base.Presence = []PresenceActivity{
+			{Type: "custom", Text: "It looks like you're trying to use Discord 📎"},
+			{Type: "watching", Text: "{{.Guilds}} servers"},
+			{Type: "playing", Text: "{{.Commands}} commands answered"},
+		}
 
 	case BotTypeMusic:
 		base.BotName = "Music Bot"
-		base.CommandCooldown = 3 * time.Second
+		base.CommandCooldown = Duration(3 * time.Second)
 		base.MaxQueueSize = 100
-		base.InactivityTimeout = 5 * time.Minute
+		base.InactivityTimeout = Duration(5 * time.Minute)
 		base.VolumeLevel = 0.5
 		base.DatabaseURL = "music.db"
+		base.Presence = []PresenceActivity{
+			{Type: "listening", Text: "/play • {{.SongsToday}} songs today"},
+		}
+		base.PresenceNowPlaying = true
 
 	case BotTypeMTG:
 		base.BotName = "MTG Card Bot"
-		base.CommandCooldown = 2 * time.Second
-		base.CacheTTL = 1 * time.Hour
+		base.CommandCooldown = Duration(2 * time.Second)
+		base.CacheTTL = Duration(1 * time.Hour)
 		base.CacheSize = 1000
+		base.Presence = []PresenceActivity{
+			{Type: "playing", Text: "{{.Prefix}}help • {{.CardsLookedUp}} cards looked up"},
+			{Type: "watching", Text: "{{.Guilds}} servers"},
+		}
+
+	case BotTypePlugins:
+		base.BotName = "Plugin Host"
+		base.Presence = []PresenceActivity{
+			{Type: "watching", Text: "{{.Guilds}} servers"},
+		}
 
 	default:
 		base.BotName = "Discord Bot"
@@ -141,122 +225,41 @@ func getDefaultConfig(botType BotType) *Config {
 	return base
 }
 
-// loadFromEnvironment loads configuration from environment variables.
-func (c *Config) loadFromEnvironment() error {
-	// Common environment variables (no global token)
-	if prefix := os.Getenv("COMMAND_PREFIX"); prefix != "" {
-		c.CommandPrefix = prefix
-	}
-	if guildID := os.Getenv("GUILD_ID"); guildID != "" {
-		c.GuildID = guildID
-	}
-	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
-		c.LogLevel = strings.ToLower(logLevel)
-	}
-
-	// Debug mode
-	c.DebugMode = GetBool("DEBUG", c.DebugMode)
-	c.JSONLogging = GetBool("JSON_LOGGING", c.JSONLogging)
-
-	// Timeouts
-	if timeout := os.Getenv("SHUTDOWN_TIMEOUT"); timeout != "" {
-		if parsed, err := time.ParseDuration(timeout); err == nil {
-			c.ShutdownTimeout = parsed
-		}
-	}
-	if timeout := os.Getenv("REQUEST_TIMEOUT"); timeout != "" {
-		if parsed, err := time.ParseDuration(timeout); err == nil {
-			c.RequestTimeout = parsed
-		}
-	}
-
-	// Retry configuration
-	c.MaxRetries = GetInt("MAX_RETRIES", c.MaxRetries)
-
-	// Bot-specific environment variables
-	switch c.BotType {
-	case BotTypeClipper:
-		if token := os.Getenv("CLIPPY_DISCORD_TOKEN"); token != "" {
-			c.DiscordToken = token
-		}
-		if guildID := os.Getenv("CLIPPY_GUILD_ID"); guildID != "" {
-			c.GuildID = guildID
-		}
-		c.RandomResponses = GetBool("RANDOM_RESPONSES", c.RandomResponses)
-		if interval := os.Getenv("RANDOM_INTERVAL"); interval != "" {
-			if parsed, err := time.ParseDuration(interval); err == nil {
-				c.RandomInterval = parsed
-			}
-		}
-		if delay := os.Getenv("RANDOM_MESSAGE_DELAY"); delay != "" {
-			if parsed, err := time.ParseDuration(delay); err == nil {
-				c.RandomMessageDelay = parsed
-			}
-		}
-
-	case BotTypeMusic:
-		if token := os.Getenv("MUSIC_DISCORD_TOKEN"); token != "" {
-			c.DiscordToken = token
-		}
-		if guildID := os.Getenv("MUSIC_GUILD_ID"); guildID != "" {
-			c.GuildID = guildID
-		}
-		if dbURL := os.Getenv("MUSIC_DATABASE_URL"); dbURL != "" {
-			c.DatabaseURL = dbURL
-		}
-		c.MaxQueueSize = GetInt("MAX_QUEUE_SIZE", c.MaxQueueSize)
-		if timeout := os.Getenv("INACTIVITY_TIMEOUT"); timeout != "" {
-			if parsed, err := time.ParseDuration(timeout); err == nil {
-				c.InactivityTimeout = parsed
-			}
-		}
-		if volume := os.Getenv("VOLUME_LEVEL"); volume != "" {
-			if parsed, err := strconv.ParseFloat(volume, 64); err == nil && parsed >= 0 && parsed <= 1 {
-				c.VolumeLevel = parsed
-			}
-		}
-
-	case BotTypeMTG:
-		if token := os.Getenv("MTG_DISCORD_TOKEN"); token != "" {
-			c.DiscordToken = token
-		}
-		if guildID := os.Getenv("MTG_GUILD_ID"); guildID != "" {
-			c.GuildID = guildID
-		}
-		if ttl := os.Getenv("CACHE_TTL"); ttl != "" {
-			if parsed, err := time.ParseDuration(ttl); err == nil {
-				c.CacheTTL = parsed
-			}
-		}
-		c.CacheSize = GetInt("CACHE_SIZE", c.CacheSize)
+// Validate validates the configuration and returns the first problem found.
+func (c *Config) Validate() error {
+	if problems := c.Problems(); len(problems) > 0 {
+		return problems[0]
 	}
-
 	return nil
 }
 
-// Validate validates the configuration.
-func (c *Config) Validate() error {
+// Problems validates the configuration and returns every problem found as a
+// *FieldError. Missing music settings are replaced with their defaults.
+func (c *Config) Problems() []error {
+	var problems []error
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, &FieldError{
+			Path:    string(c.BotType) + "." + field,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
 	if c.DiscordToken == "" {
-		var tokenName string
-		switch c.BotType {
-		case BotTypeClipper:
-			tokenName = "CLIPPY_DISCORD_TOKEN"
-		case BotTypeMusic:
-			tokenName = "MUSIC_DISCORD_TOKEN"
-		case BotTypeMTG:
-			tokenName = "MTG_DISCORD_TOKEN"
-		default:
-			tokenName = "DISCORD_TOKEN"
+		tokenName := "DISCORD_TOKEN"
+		for _, binding := range botEnv[c.BotType] {
+			if binding.field == "discord_token" {
+				tokenName = binding.env
+			}
 		}
-		return fmt.Errorf("%s bot: %s is required", c.BotType, tokenName)
+		add("discord_token", "is required (set %s or %s_FILE, or discord_token in the %s section of the config file)", tokenName, tokenName, c.BotType)
 	}
 
 	if c.BotName == "" {
-		return fmt.Errorf("%s bot: bot_name is required", c.BotType)
+		add("bot_name", "is required")
 	}
 
 	if c.CommandPrefix == "" {
-		return fmt.Errorf("%s bot: command_prefix is required", c.BotType)
+		add("command_prefix", "is required")
 	}
 
 	// Validate log level
@@ -268,28 +271,65 @@ func (c *Config) Validate() error {
 	}
 
 	if !validLogLevels[strings.ToLower(c.LogLevel)] {
-		return fmt.Errorf("%s bot: invalid log_level '%s', must be one of: debug, info, warn, error", c.BotType, c.LogLevel)
+		add("log_level", "invalid value '%s', must be one of: debug, info, warn, error", c.LogLevel)
 	}
 
 	// Validate timeouts
 	if c.ShutdownTimeout <= 0 {
-		return fmt.Errorf("%s bot: shutdown_timeout must be positive", c.BotType)
+		add("shutdown_timeout", "must be positive")
 	}
 	if c.RequestTimeout <= 0 {
-		return fmt.Errorf("%s bot: request_timeout must be positive", c.BotType)
+		add("request_timeout", "must be positive")
 	}
 	if c.MaxRetries < 0 {
-		return fmt.Errorf("%s bot: max_retries cannot be negative", c.BotType)
+		add("max_retries", "cannot be negative")
+	}
+	if c.OutboundQueueSize < 1 {
+		add("outbound_queue_size", "must be at least 1")
+	}
+	if c.Storage != "" && c.Storage != StorageSQLite && c.Storage != StorageMemory {
+		add("storage", "invalid value '%s', must be one of: %s, %s", c.Storage, StorageSQLite, StorageMemory)
+	}
+	if c.AuditRetention < 0 {
+		add("audit_retention", "cannot be negative")
 	}
 
+	// Validate feature flags
+	for name, flag := range c.Features {
+		if flag.Rollout < 0 || flag.Rollout > 100 {
+			add("features."+name+".rollout", "must be between 0 and 100")
+		}
+	}
+
+	// Validate presence activities
+	if len(c.Presence) > 0 && c.PresenceInterval < Duration(time.Minute) {
+		add("presence_interval", "must be at least 1m")
+	}
+	for i, activity := range c.Presence {
+		if !presenceActivityTypes[activity.Type] {
+			add(fmt.Sprintf("presence[%d].type", i), "'%s' must be one of: playing, listening, watching, competing, custom", activity.Type)
+		}
+		if _, err := template.New("presence").Parse(activity.Text); err != nil {
+			add(fmt.Sprintf("presence[%d].text", i), "%v", err)
+		}
+	}
+
+	// Validate per-guild overrides
+	c.guildProblems(add)
+
 	// Bot-specific validation
 	switch c.BotType {
+	case BotTypeClipper:
+		if c.RandomResponses && c.RandomInterval <= 0 {
+			add("random_interval", "must be positive when random_responses is enabled")
+		}
+
 	case BotTypeMusic:
 		if c.MaxQueueSize <= 0 {
 			c.MaxQueueSize = 100
 		}
 		if c.InactivityTimeout <= 0 {
-			c.InactivityTimeout = 5 * time.Minute
+			c.InactivityTimeout = Duration(5 * time.Minute)
 		}
 		if c.VolumeLevel <= 0 || c.VolumeLevel > 1 {
 			c.VolumeLevel = 0.5
@@ -297,19 +337,35 @@ func (c *Config) Validate() error {
 
 	case BotTypeMTG:
 		if c.CacheTTL <= 0 {
-			return fmt.Errorf("MTG bot: cache_ttl must be positive")
+			add("cache_ttl", "must be positive")
 		}
 		if c.CacheSize <= 0 {
-			return fmt.Errorf("MTG bot: cache_size must be positive")
+			add("cache_size", "must be positive")
+		}
+
+	case BotTypePlugins:
+		if len(c.Plugins) == 0 && c.PluginDir == "" {
+			add("plugins", "no plugins configured (set plugins or plugin_dir)")
+		}
+		seen := make(map[string]bool)
+		for i, p := range c.Plugins {
+			if p.Name == "" || p.Command == "" {
+				add(fmt.Sprintf("plugins[%d]", i), "requires name and command")
+			}
+			if seen[p.Name] {
+				add(fmt.Sprintf("plugins[%d].name", i), "duplicate plugin name '%s'", p.Name)
+			}
+			seen[p.Name] = true
 		}
 	}
 
-	return nil
+	return problems
 }
 
-// Save saves the configuration to a JSON file.
+// Save saves the configuration to a file in the format of its extension.
+// Secrets are never written; they are left to the environment.
 func (c *Config) Save(configPath string) error {
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := Marshal(FormatOf(configPath), c.withoutSecrets())
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -373,3 +429,46 @@ func GetDuration(key string, defaultValue time.Duration) time.Duration {
 
 	return duration
 }
+
+// LoadEnvFile sets environment variables from a .env style file. Variables
+// already set in the environment take precedence. A missing file is not an
+// error.
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		// Remove quotes if present
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		// Only set if not already set by system environment
+		if os.Getenv(key) == "" {
+			if err := os.Setenv(key, value); err != nil {
+				return fmt.Errorf("failed to set environment variable %s: %w", key, err)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
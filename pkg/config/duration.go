@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that is written to config files as a Go
+// duration string such as "5s" or "1h30m". When reading, integers are also
+// accepted as nanoseconds, the format used by older config files.
+type Duration time.Duration
+
+// durationType is the reflect type of Duration.
+var durationType = reflect.TypeOf(Duration(0))
+
+// Std returns d as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// String returns d in the format of time.Duration.String without zero
+// trailing units, e.g. "1h" rather than "1h0m0s".
+func (d Duration) String() string {
+	s := time.Duration(d).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// MarshalJSON writes d as a duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a duration string or an integer number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return &json.UnmarshalTypeError{Value: string(data), Type: durationType}
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return &json.UnmarshalTypeError{Value: string(data), Type: durationType}
+	}
+	*d = Duration(n)
+	return nil
+}
@@ -0,0 +1,150 @@
+package config_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+)
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"seconds", `"30s"`, 30 * time.Second, false},
+		{"hours and minutes", `"1h30m"`, 90 * time.Minute, false},
+		{"zero", `"0s"`, 0, false},
+		{"integer nanoseconds", `1000000000`, time.Second, false},
+		{"no unit", `"30"`, 0, true},
+		{"not a duration", `"soon"`, 0, true},
+		{"fraction", `1.5`, 0, true},
+		{"boolean", `true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d config.Duration
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %v, want an error", tt.input, d.Std())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %v", tt.input, err)
+			}
+			if d.Std() != tt.want {
+				t.Errorf("Unmarshal(%s) = %v, want %v", tt.input, d.Std(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, `"30s"`},
+		{time.Hour, `"1h"`},
+		{90 * time.Minute, `"1h30m"`},
+		{time.Hour + 5*time.Second, `"1h0m5s"`},
+		{1500 * time.Millisecond, `"1.5s"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			data, err := json.Marshal(config.Duration(tt.d))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal(%v) = %s, want %s", tt.d, data, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"json string", "config.json", `{"mtg": {"cache_ttl": "30s"}}`, 30 * time.Second, false},
+		{"json nanoseconds", "config.json", `{"mtg": {"cache_ttl": 60000000000}}`, time.Minute, false},
+		{"json invalid", "config.json", `{"mtg": {"cache_ttl": "an hour"}}`, 0, true},
+		{"yaml string", "config.yaml", "mtg:\n  cache_ttl: 1h30m\n", 90 * time.Minute, false},
+		{"yaml nanoseconds", "config.yaml", "mtg:\n  cache_ttl: 1000000000\n", time.Second, false},
+		{"yaml invalid", "config.yaml", "mtg:\n  cache_ttl: later\n", 0, true},
+		{"toml string", "config.toml", "[mtg]\ncache_ttl = \"2h\"\n", 2 * time.Hour, false},
+		{"toml nanoseconds", "config.toml", "[mtg]\ncache_ttl = 5000000000\n", 5 * time.Second, false},
+		{"toml invalid", "config.toml", "[mtg]\ncache_ttl = \"5 minutes\"\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := config.LoadWith(config.BotTypeMTG, config.LoadOptions{Path: path})
+			if tt.wantErr {
+				var fieldErr *config.FieldError
+				if !errors.As(err, &fieldErr) {
+					t.Fatalf("LoadWith() error = %v, want a *FieldError", err)
+				}
+				if fieldErr.Path != "mtg.cache_ttl" {
+					t.Errorf("FieldError.Path = %q, want %q", fieldErr.Path, "mtg.cache_ttl")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadWith(): %v", err)
+			}
+			if cfg.CacheTTL.Std() != tt.want {
+				t.Errorf("CacheTTL = %v, want %v", cfg.CacheTTL.Std(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationSaveRoundTrip(t *testing.T) {
+	for _, file := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(file, func(t *testing.T) {
+			cfg, err := config.LoadWith(config.BotTypeMTG, config.LoadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.CacheTTL = config.Duration(90 * time.Minute)
+			cfg.RequestTimeout = config.Duration(1500 * time.Millisecond)
+
+			path := filepath.Join(t.TempDir(), file)
+			if err := cfg.Save(path); err != nil {
+				t.Fatalf("Save(): %v", err)
+			}
+
+			loaded, err := config.LoadWith(config.BotTypeMTG, config.LoadOptions{Path: path})
+			if err != nil {
+				t.Fatalf("LoadWith(): %v", err)
+			}
+			if loaded.CacheTTL != cfg.CacheTTL {
+				t.Errorf("CacheTTL = %v, want %v", loaded.CacheTTL, cfg.CacheTTL)
+			}
+			if loaded.RequestTimeout != cfg.RequestTimeout {
+				t.Errorf("RequestTimeout = %v, want %v", loaded.RequestTimeout, cfg.RequestTimeout)
+			}
+		})
+	}
+}
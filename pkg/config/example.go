@@ -0,0 +1,192 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// exampleField is a setting of the example config file. Its value is the
+// default of the section's bot type.
+type exampleField struct {
+	name    string
+	comment string
+}
+
+// exampleSection is a group of settings: the shared settings at the top level
+// or the section of one bot type.
+type exampleSection struct {
+	botType BotType
+	comment string
+	fields  []exampleField
+}
+
+// exampleSections describe the example config file written by WriteExample.
+var exampleSections = []exampleSection{
+	{
+		comment: "Settings shared by every bot. Each one can also be set in a bot's section.",
+		fields: []exampleField{
+			{"log_level", "Log level: debug, info, warn or error (LOG_LEVEL)"},
+			{"json_logging", "Write logs as JSON records instead of text (JSON_LOGGING)"},
+			{"shutdown_timeout", "How long a bot may take to shut down (SHUTDOWN_TIMEOUT)"},
+			{"request_timeout", "Timeout of requests to Discord and other APIs (REQUEST_TIMEOUT)"},
+			{"max_retries", "Attempts per outbound message before giving up (MAX_RETRIES)"},
+			{"storage", "Storage backend: sqlite, or memory to keep nothing on disk (STORAGE)"},
+			{"audit_database_url", "SQLite database of the command audit log (AUDIT_DATABASE_URL)"},
+			{"audit_retention", "How long audit entries are kept (AUDIT_RETENTION)"},
+			{"presence_interval", "How often the Discord status rotates (PRESENCE_INTERVAL)"},
+		},
+	},
+	{
+		botType: BotTypeClipper,
+		comment: "Clippy Bot",
+		fields: []exampleField{
+			{"discord_token", "Bot token. Prefer CLIPPY_DISCORD_TOKEN or CLIPPY_DISCORD_TOKEN_FILE, or a reference such as file:/run/secrets/clippy_token"},
+			{"guild_id", "Register commands in one guild for testing; empty registers them globally"},
+			{"command_cooldown", "Minimum time between two commands of one user"},
+			{"random_responses", "Post unprompted Clippy messages (RANDOM_RESPONSES)"},
+			{"random_interval", "Average time between random messages (RANDOM_INTERVAL)"},
+		},
+	},
+	{
+		botType: BotTypeMusic,
+		comment: "Music Bot",
+		fields: []exampleField{
+			{"discord_token", "Bot token. Prefer MUSIC_DISCORD_TOKEN or MUSIC_DISCORD_TOKEN_FILE, or a reference such as file:/run/secrets/music_token"},
+			{"guild_id", "Register commands in one guild for testing; empty registers them globally"},
+			{"database_url", "SQLite database of playlists; empty disables playlist commands (MUSIC_DATABASE_URL)"},
+			{"max_queue_size", "Maximum songs queued per guild (MAX_QUEUE_SIZE)"},
+			{"inactivity_timeout", "Leave the voice channel after this long without playing (INACTIVITY_TIMEOUT)"},
+			{"volume_level", "Playback volume between 0 and 1 (VOLUME_LEVEL)"},
+		},
+	},
+	{
+		botType: BotTypeMTG,
+		comment: "MTG Card Bot",
+		fields: []exampleField{
+			{"discord_token", "Bot token. Prefer MTG_DISCORD_TOKEN or MTG_DISCORD_TOKEN_FILE, or a reference such as file:/run/secrets/mtg_token"},
+			{"command_prefix", "Prefix of text commands such as !lightning bolt (MTG_COMMAND_PREFIX)"},
+			{"cache_ttl", "How long card lookups are cached (CACHE_TTL)"},
+			{"cache_size", "Maximum number of cached cards (CACHE_SIZE)"},
+		},
+	},
+	{
+		botType: BotTypePlugins,
+		comment: "Plugin Host",
+		fields: []exampleField{
+			{"discord_token", "Bot token. Prefer PLUGINS_DISCORD_TOKEN or PLUGINS_DISCORD_TOKEN_FILE, or a reference such as file:/run/secrets/plugins_token"},
+			{"plugin_dir", "Directory of plugin executables to start (PLUGIN_DIR)"},
+		},
+	},
+}
+
+// WriteExample writes an example config file with the default settings of
+// every bot. YAML and TOML files explain each setting in comments; JSON has
+// no comments, so the JSON example only lists the settings.
+func WriteExample(w io.Writer, format Format) error {
+	out := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case FormatYAML:
+		err = writeYAMLExample(out)
+	case FormatTOML:
+		err = writeTOMLExample(out)
+	default:
+		err = writeJSONExample(out)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// exampleValue returns the default of a field for a bot type as JSON, which
+// is also valid YAML and TOML for the scalar values used in the example.
+func exampleValue(botType BotType, name string) (string, error) {
+	if botType == "" {
+		botType = BotTypeClipper
+	}
+
+	field := getDefaultConfig(botType).field(name)
+	if !field.IsValid() {
+		return "", fmt.Errorf("unknown example field: %s", name)
+	}
+
+	data, err := json.Marshal(field.Interface())
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+func writeYAMLExample(w *bufio.Writer) error {
+	for i, section := range exampleSections {
+		indent := ""
+		if i > 0 {
+			indent = "  "
+		}
+		fmt.Fprintf(w, "# %s\n", section.comment)
+		if section.botType != "" {
+			fmt.Fprintf(w, "%s:\n", section.botType)
+		}
+		for _, field := range section.fields {
+			value, err := exampleValue(section.botType, field.name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s# %s\n%s%s: %s\n", indent, field.comment, indent, field.name, value)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func writeTOMLExample(w *bufio.Writer) error {
+	for _, section := range exampleSections {
+		fmt.Fprintf(w, "# %s\n", section.comment)
+		if section.botType != "" {
+			fmt.Fprintf(w, "[%s]\n", section.botType)
+		}
+		for _, field := range section.fields {
+			value, err := exampleValue(section.botType, field.name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "# %s\n%s = %s\n", field.comment, field.name, value)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func writeJSONExample(w *bufio.Writer) error {
+	fmt.Fprintln(w, "{")
+	for i, section := range exampleSections {
+		indent := "  "
+		if section.botType != "" {
+			fmt.Fprintf(w, "  %q: {\n", section.botType)
+			indent = "    "
+		}
+		for j, field := range section.fields {
+			value, err := exampleValue(section.botType, field.name)
+			if err != nil {
+				return err
+			}
+			comma := ","
+			if section.botType != "" && j == len(section.fields)-1 {
+				comma = ""
+			}
+			fmt.Fprintf(w, "%s%q: %s%s\n", indent, field.name, value, comma)
+		}
+		if section.botType != "" {
+			if i < len(exampleSections)-1 {
+				fmt.Fprintln(w, "  },")
+			} else {
+				fmt.Fprintln(w, "  }")
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import "flag"
+
+// CommandLine holds the configuration flags shared by the bot binaries.
+type CommandLine struct {
+	// ConfigFile is the --config path; it defaults to $CONFIG_FILE.
+	ConfigFile string
+	// Overrides are the --set key=value flags.
+	Overrides Overrides
+	// PrintConfig is set by --print-config.
+	PrintConfig bool
+}
+
+// RegisterFlags defines --config, --set and --print-config on fs. Call it
+// after loading .env so that CONFIG_FILE from there is the default.
+func RegisterFlags(fs *flag.FlagSet) *CommandLine {
+	cl := &CommandLine{}
+	fs.StringVar(&cl.ConfigFile, "config", GetString("CONFIG_FILE", ""), "Configuration file path")
+	fs.Var(&cl.Overrides, "set", "Override a config field, e.g. log_level=debug (repeatable)")
+	fs.BoolVar(&cl.PrintConfig, "print-config", false, "Print the effective configuration with secrets redacted and exit")
+	return cl
+}
+
+// Options returns the load options selected by the flags.
+func (cl *CommandLine) Options() LoadOptions {
+	return LoadOptions{Path: cl.ConfigFile, Flags: cl.Overrides}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a config file.
+type Format string
+
+const (
+	// FormatJSON is the default format.
+	FormatJSON Format = "json"
+	// FormatYAML is used for .yaml and .yml files.
+	FormatYAML Format = "yaml"
+	// FormatTOML is used for .toml files.
+	FormatTOML Format = "toml"
+)
+
+// FormatOf returns the format of a config file from its extension. Files
+// without a known extension are JSON.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// toJSON converts a YAML or TOML document to JSON, so that every format is
+// decoded and validated by the same code with the same field names.
+func toJSON(format Format, data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	return json.Marshal(doc)
+}
+
+// Marshal encodes v, a configuration struct, in the given format using its
+// JSON field names.
+func Marshal(format Format, v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatYAML:
+		// JSON is YAML; decoding it into a node keeps the field order
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+		clearStyle(&node)
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(tomlValue(doc)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return append(data, '\n'), nil
+	}
+}
+
+// clearStyle switches a node decoded from JSON to block style.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// tomlValue prepares a decoded JSON value for the TOML encoder: numbers
+// become integers where possible and nulls, which TOML cannot express, are
+// dropped.
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if value == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = tomlValue(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = tomlValue(value)
+		}
+		return v
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// formatError describes a syntax error in a config file.
+func formatError(format Format, path string, data []byte, err error) error {
+	if format == FormatJSON {
+		return fmt.Errorf("failed to parse config file %s: %s", path, describeJSONError(data, err))
+	}
+	return fmt.Errorf("failed to parse config file %s: %w", path, err)
+}
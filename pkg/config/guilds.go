@@ -0,0 +1,117 @@
+package config
+
+import "fmt"
+
+// GuildConfig overrides settings for one guild. Unset fields keep the bot's
+// own setting.
+type GuildConfig struct {
+	CommandCooldown    *Duration `json:"command_cooldown,omitempty"`
+	MaxQueueSize       *int      `json:"max_queue_size,omitempty"`
+	VolumeLevel        *float64  `json:"volume_level,omitempty"`
+	RandomResponses    *bool     `json:"random_responses,omitempty"`
+	RandomInterval     *Duration `json:"random_interval,omitempty"`
+	RandomMessageDelay *Duration `json:"random_message_delay,omitempty"`
+
+	// AllowedChannels limits commands to these channels. An empty list
+	// keeps the bot's allowed_channels.
+	AllowedChannels []string `json:"allowed_channels,omitempty"`
+}
+
+// ConfigFor returns the configuration that applies in a guild: the bot's
+// configuration with the guild's overrides from the guilds section. It
+// returns c itself for DMs and guilds without overrides, so the result must
+// not be modified.
+func (c *Config) ConfigFor(guildID string) *Config {
+	guild, ok := c.Guilds[guildID]
+	if guildID == "" || !ok {
+		return c
+	}
+
+	resolved := *c
+	if guild.CommandCooldown != nil {
+		resolved.CommandCooldown = *guild.CommandCooldown
+	}
+	if guild.MaxQueueSize != nil {
+		resolved.MaxQueueSize = *guild.MaxQueueSize
+	}
+	if guild.VolumeLevel != nil {
+		resolved.VolumeLevel = *guild.VolumeLevel
+	}
+	if guild.RandomResponses != nil {
+		resolved.RandomResponses = *guild.RandomResponses
+	}
+	if guild.RandomInterval != nil {
+		resolved.RandomInterval = *guild.RandomInterval
+	}
+	if guild.RandomMessageDelay != nil {
+		resolved.RandomMessageDelay = *guild.RandomMessageDelay
+	}
+	if len(guild.AllowedChannels) > 0 {
+		resolved.AllowedChannels = guild.AllowedChannels
+	}
+	return &resolved
+}
+
+// ChannelAllowed reports whether commands may be used in a channel. Every
+// channel is allowed when AllowedChannels is empty. The allowlist only
+// applies to guild channels; callers skip it for DMs.
+func (c *Config) ChannelAllowed(channelID string) bool {
+	if len(c.AllowedChannels) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedChannels {
+		if allowed == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// guildProblems validates the allowed channels and the guilds section.
+func (c *Config) guildProblems(add func(field, format string, args ...interface{})) {
+	for i, channelID := range c.AllowedChannels {
+		if !isSnowflake(channelID) {
+			add(fmt.Sprintf("allowed_channels[%d]", i), "'%s' is not a channel ID", channelID)
+		}
+	}
+
+	for guildID, guild := range c.Guilds {
+		path := "guilds." + guildID
+		if !isSnowflake(guildID) {
+			add(path, "'%s' is not a guild ID", guildID)
+		}
+		if guild.CommandCooldown != nil && *guild.CommandCooldown < 0 {
+			add(path+".command_cooldown", "cannot be negative")
+		}
+		if guild.MaxQueueSize != nil && *guild.MaxQueueSize <= 0 {
+			add(path+".max_queue_size", "must be positive")
+		}
+		if guild.VolumeLevel != nil && (*guild.VolumeLevel <= 0 || *guild.VolumeLevel > 1) {
+			add(path+".volume_level", "must be between 0 and 1")
+		}
+		if guild.RandomInterval != nil && *guild.RandomInterval <= 0 {
+			add(path+".random_interval", "must be positive")
+		}
+		if guild.RandomMessageDelay != nil && *guild.RandomMessageDelay < 0 {
+			add(path+".random_message_delay", "cannot be negative")
+		}
+		for i, channelID := range guild.AllowedChannels {
+			if !isSnowflake(channelID) {
+				add(fmt.Sprintf("%s.allowed_channels[%d]", path, i), "'%s' is not a channel ID", channelID)
+			}
+		}
+	}
+}
+
+// isSnowflake reports whether id looks like a Discord ID.
+func isSnowflake(id string) bool {
+	if len(id) < 17 || len(id) > 20 {
+		return false
+	}
+	for _, char := range id {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
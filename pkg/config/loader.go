@@ -0,0 +1,481 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadOptions selects the configuration sources of LoadWith. The layers are
+// applied in order, each overriding the previous one:
+//
+//	defaults < config file < environment < flags
+//
+// The config file holds settings shared by all bots at the top level and
+// per-bot settings in a section named after the bot type:
+//
+//	{
+//	  "log_level": "info",
+//	  "clippy": {"discord_token": "...", "random_responses": true},
+//	  "mtg": {"discord_token": "...", "cache_size": 2000}
+//	}
+//
+// YAML and TOML files use the same keys; see FormatOf.
+type LoadOptions struct {
+	// Path is the config file. An empty path or a missing file skips the
+	// file layer.
+	Path string
+	// Flags are command-line overrides keyed by field name, e.g. "log_level".
+	// A key prefixed with a bot type, e.g. "mtg.cache_size", only applies to
+	// that bot.
+	Flags Overrides
+}
+
+// FieldError is a problem with one configuration field.
+type FieldError struct {
+	// Path is the field, prefixed with the bot type, e.g. "mtg.cache_ttl".
+	Path string
+	// Source is where the value came from: a config file, an environment
+	// variable or a flag. It is empty for validation problems.
+	Source  string
+	Message string
+}
+
+// Error implements the error interface.
+func (e *FieldError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s: %s (from %s)", e.Path, e.Message, e.Source)
+	}
+	return e.Path + ": " + e.Message
+}
+
+// envBinding maps an environment variable to a config field.
+type envBinding struct {
+	env   string
+	field string
+}
+
+// commonEnv are the environment variables read by every bot.
+var commonEnv = []envBinding{
+	{"COMMAND_PREFIX", "command_prefix"},
+	{"GUILD_ID", "guild_id"},
+	{"LOG_LEVEL", "log_level"},
+	{"DEBUG", "debug_mode"},
+	{"JSON_LOGGING", "json_logging"},
+	{"SHUTDOWN_TIMEOUT", "shutdown_timeout"},
+	{"REQUEST_TIMEOUT", "request_timeout"},
+	{"MAX_RETRIES", "max_retries"},
+	{"OUTBOUND_QUEUE_SIZE", "outbound_queue_size"},
+	{"STORAGE", "storage"},
+	{"AUDIT_DATABASE_URL", "audit_database_url"},
+	{"AUDIT_RETENTION", "audit_retention"},
+	{"FEATURES_FILE", "features_file"},
+	{"PRESENCE_INTERVAL", "presence_interval"},
+}
+
+// botEnv are the environment variables read by one bot type. They are
+// applied after commonEnv, so e.g. CLIPPY_GUILD_ID wins over GUILD_ID.
+var botEnv = map[BotType][]envBinding{
+	BotTypeClipper: {
+		{"CLIPPY_DISCORD_TOKEN", "discord_token"},
+		{"CLIPPY_GUILD_ID", "guild_id"},
+		{"CLIPPY_COMMAND_PREFIX", "command_prefix"},
+		{"RANDOM_RESPONSES", "random_responses"},
+		{"RANDOM_INTERVAL", "random_interval"},
+		{"RANDOM_MESSAGE_DELAY", "random_message_delay"},
+	},
+	BotTypeMusic: {
+		{"MUSIC_DISCORD_TOKEN", "discord_token"},
+		{"MUSIC_GUILD_ID", "guild_id"},
+		{"MUSIC_COMMAND_PREFIX", "command_prefix"},
+		{"MUSIC_DATABASE_URL", "database_url"},
+		{"MAX_QUEUE_SIZE", "max_queue_size"},
+		{"INACTIVITY_TIMEOUT", "inactivity_timeout"},
+		{"VOLUME_LEVEL", "volume_level"},
+	},
+	BotTypeMTG: {
+		{"MTG_DISCORD_TOKEN", "discord_token"},
+		{"MTG_GUILD_ID", "guild_id"},
+		{"MTG_COMMAND_PREFIX", "command_prefix"},
+		{"CACHE_TTL", "cache_ttl"},
+		{"CACHE_SIZE", "cache_size"},
+	},
+	BotTypePlugins: {
+		{"PLUGINS_DISCORD_TOKEN", "discord_token"},
+		{"PLUGINS_GUILD_ID", "guild_id"},
+		{"PLUGINS_COMMAND_PREFIX", "command_prefix"},
+		{"PLUGIN_DIR", "plugin_dir"},
+	},
+}
+
+// BotTypes lists every bot type, in the order their sections are documented.
+var BotTypes = []BotType{BotTypeClipper, BotTypeMusic, BotTypeMTG, BotTypePlugins}
+
+// isBotType reports whether name is a known bot type.
+func isBotType(name string) bool {
+	for _, botType := range BotTypes {
+		if string(botType) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadWith loads the configuration of a bot from the layers selected by opts.
+// It does not validate the result; see Validate and Problems.
+func LoadWith(botType BotType, opts LoadOptions) (*Config, error) {
+	cfg := getDefaultConfig(botType)
+	cfg.ConfigFile = opts.Path
+	cfg.flags = opts.Flags
+
+	if opts.Path != "" {
+		if _, err := os.Stat(opts.Path); err == nil {
+			if err := cfg.loadFile(opts.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := cfg.loadFromEnvironment(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.applyFlags(opts.Flags); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	// The file must not turn one bot into another
+	cfg.BotType = botType
+	cfg.LogLevel = strings.ToLower(cfg.LogLevel)
+
+	return cfg, nil
+}
+
+// loadFile applies the shared settings and the bot's section of a config
+// file in any Format.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	format := FormatOf(path)
+	data, err = toJSON(format, data)
+	if err != nil {
+		return formatError(format, path, data, err)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return formatError(format, path, data, err)
+	}
+
+	// A top-level "plugins" array is the plugin list, not the plugins section
+	shared := make(map[string]json.RawMessage)
+	for key, raw := range sections {
+		if !isBotType(key) || !isObject(raw) {
+			shared[key] = raw
+			delete(sections, key)
+		}
+	}
+
+	sharedData, err := json.Marshal(shared)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := c.decodeSection(sharedData, "", path); err != nil {
+		return err
+	}
+
+	if section, ok := sections[string(c.BotType)]; ok {
+		if err := c.decodeSection(section, string(c.BotType)+".", path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeSection decodes one object of the config file into c. Unknown keys
+// are errors so that misspelled settings are not silently ignored.
+func (c *Config) decodeSection(data []byte, prefix, source string) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(c)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Type == durationType {
+			if fieldErr := checkDurations(data, prefix, source); fieldErr != nil {
+				return fieldErr
+			}
+		}
+		return &FieldError{
+			Path:    prefix + typeErr.Field,
+			Source:  source,
+			Message: fmt.Sprintf("cannot use %s as %s", typeErr.Value, typeErr.Type),
+		}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &FieldError{Path: prefix + strings.Trim(field, `"`), Source: source, Message: "unknown field"}
+	}
+	if prefix == "" {
+		return &FieldError{Path: "(top level)", Source: source, Message: err.Error()}
+	}
+	return &FieldError{Path: strings.TrimSuffix(prefix, "."), Source: source, Message: err.Error()}
+}
+
+// checkDurations finds the invalid duration in a section. The JSON decoder
+// does not report which field a Duration error came from.
+func checkDurations(data []byte, prefix, source string) error {
+	if err := checkDurationFields(data, reflect.TypeOf(Config{}), prefix, source); err != nil {
+		return err
+	}
+
+	var section struct {
+		Guilds map[string]json.RawMessage `json:"guilds"`
+	}
+	if err := json.Unmarshal(data, &section); err != nil {
+		return nil
+	}
+	for guildID, guild := range section.Guilds {
+		guildPrefix := prefix + "guilds." + guildID + "."
+		if err := checkDurationFields(guild, reflect.TypeOf(GuildConfig{}), guildPrefix, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDurationFields checks the Duration fields of struct type t in a JSON object.
+func checkDurationFields(data []byte, t reflect.Type, prefix, source string) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i).Type
+		if fieldType != durationType && fieldType != reflect.PointerTo(durationType) {
+			continue
+		}
+		name := jsonFieldName(t.Field(i))
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		var d Duration
+		if err := d.UnmarshalJSON(value); err != nil {
+			return &FieldError{
+				Path:    prefix + name,
+				Source:  source,
+				Message: fmt.Sprintf(`invalid duration %s, expected a string such as "30s" or "1h30m"`, value),
+			}
+		}
+	}
+	return nil
+}
+
+// isObject reports whether raw is a JSON object.
+func isObject(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// describeJSONError adds the line and column to JSON syntax errors.
+func describeJSONError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err.Error()
+	}
+
+	line, col := 1, 1
+	for _, b := range data[:syntaxErr.Offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Sprintf("line %d, column %d: %s", line, col, err)
+}
+
+// loadFromEnvironment applies the environment variables of c's bot type.
+func (c *Config) loadFromEnvironment() error {
+	bindings := append(append([]envBinding{}, commonEnv...), botEnv[c.BotType]...)
+	for _, binding := range bindings {
+		value, source := os.Getenv(binding.env), binding.env
+
+		// Secrets can be mounted as files, e.g. CLIPPY_DISCORD_TOKEN_FILE
+		if field := c.field(binding.field); field.IsValid() && field.Type() == secretType {
+			if path := os.Getenv(binding.env + "_FILE"); path != "" {
+				if value != "" {
+					return &FieldError{
+						Path:    string(c.BotType) + "." + binding.field,
+						Source:  binding.env + "_FILE",
+						Message: fmt.Sprintf("%s is also set; set only one of them", binding.env),
+					}
+				}
+				value, source = "file:"+path, binding.env+"_FILE"
+			}
+		}
+
+		if value == "" {
+			continue
+		}
+		if err := c.setField(binding.field, value, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyFlags applies command-line overrides meant for c's bot type.
+func (c *Config) applyFlags(flags Overrides) error {
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := key
+		if botType, rest, ok := strings.Cut(key, "."); ok && isBotType(botType) {
+			if BotType(botType) != c.BotType {
+				continue
+			}
+			field = rest
+		}
+		if err := c.setField(field, flags[key], "--set "+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field returns the field with the given JSON name, or the zero Value if
+// there is none.
+func (c *Config) field(name string) reflect.Value {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if jsonFieldName(v.Type().Field(i)) == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// setField parses value into the field with the given JSON name. Only scalar
+// fields can be set this way; lists and maps belong in the config file.
+func (c *Config) setField(name, value, source string) error {
+	path := string(c.BotType) + "." + name
+
+	field := c.field(name)
+	if !field.IsValid() {
+		return &FieldError{Path: path, Source: source, Message: "unknown field"}
+	}
+
+	invalid := func(kind string) error {
+		return &FieldError{Path: path, Source: source, Message: fmt.Sprintf("invalid %s %q", kind, value)}
+	}
+
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return invalid("duration")
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid("boolean")
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return invalid("integer")
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalid("number")
+		}
+		field.SetFloat(f)
+	default:
+		return &FieldError{Path: path, Source: source, Message: "can only be set in the config file"}
+	}
+
+	return nil
+}
+
+// Print writes the effective configuration to w as JSON. Secrets marshal as
+// "REDACTED".
+func (c *Config) Print(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// Overrides are configuration values given on the command line as
+// key=value. It implements flag.Value and pflag.Value, so it can back a
+// repeatable --set flag.
+type Overrides map[string]string
+
+// String returns the overrides as comma-separated key=value pairs.
+func (o *Overrides) String() string {
+	if o == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*o))
+	for key, value := range *o {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set adds one key=value override.
+func (o *Overrides) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+
+	if *o == nil {
+		*o = make(Overrides)
+	}
+	(*o)[key] = val
+	return nil
+}
+
+// Type describes the flag value for help output.
+func (o *Overrides) Type() string {
+	return "key=value"
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// redactedText replaces a secret in output.
+const redactedText = "REDACTED"
+
+// Secret is a sensitive setting such as a bot token. It prints, logs and
+// marshals as "REDACTED", so it can be dumped with %+v, logged with slog or
+// added to an error's context without leaking; Value returns the secret
+// itself.
+//
+// In a config file or environment variable a secret can also be given as a
+// reference, resolved when the configuration is loaded:
+//
+//	file:/run/secrets/clippy_token   the trimmed contents of a file
+//	env:CLIPPY_TOKEN                 the value of another environment variable
+type Secret string
+
+// secretType is the reflect type of Secret.
+var secretType = reflect.TypeOf(Secret(""))
+
+// Value returns the secret.
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String returns "REDACTED", or "" if the secret is not set.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redactedText
+}
+
+// GoString redacts the secret in %#v output.
+func (s Secret) GoString() string {
+	return strconv.Quote(s.String())
+}
+
+// LogValue redacts the secret in slog records.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalJSON writes the secret as "REDACTED".
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// resolveSecret returns the value a file: or env: reference points to, or
+// value itself if it is not a reference.
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "file:"):
+		path := strings.TrimPrefix(value, "file:")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolveSecrets replaces file: and env: references in every secret field.
+func (c *Config) resolveSecrets() error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Type() != secretType {
+			continue
+		}
+
+		resolved, err := resolveSecret(field.String())
+		if err != nil {
+			return &FieldError{
+				Path:    string(c.BotType) + "." + jsonFieldName(v.Type().Field(i)),
+				Message: err.Error(),
+			}
+		}
+		field.SetString(resolved)
+	}
+	return nil
+}
+
+// withoutSecrets returns a copy of the configuration with every secret
+// cleared, for writing to disk.
+func (c *Config) withoutSecrets() *Config {
+	saved := *c
+	v := reflect.ValueOf(&saved).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Type() == secretType {
+			v.Field(i).SetString("")
+		}
+	}
+	return &saved
+}
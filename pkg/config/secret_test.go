@@ -0,0 +1,162 @@
+package config_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+)
+
+// token is a stand-in bot token that must never appear in output.
+const token = "MTIzNDU2Nzg5.secret-token-value"
+
+func TestSecretRedaction(t *testing.T) {
+	secret := config.Secret(token)
+	cfg := &config.Config{BotType: config.BotTypeMTG, DiscordToken: secret}
+
+	tests := []struct {
+		name   string
+		output func() (string, error)
+	}{
+		{"String", func() (string, error) { return secret.String(), nil }},
+		{"GoString", func() (string, error) { return secret.GoString(), nil }},
+		{"%v", func() (string, error) { return fmt.Sprintf("%v", secret), nil }},
+		{"%s", func() (string, error) { return fmt.Sprintf("%s", secret), nil }},
+		{"%q", func() (string, error) { return fmt.Sprintf("%q", secret), nil }},
+		{"%#v", func() (string, error) { return fmt.Sprintf("%#v", secret), nil }},
+		{"struct %+v", func() (string, error) { return fmt.Sprintf("%+v", *cfg), nil }},
+		{"struct %#v", func() (string, error) { return fmt.Sprintf("%#v", *cfg), nil }},
+		{"LogValue", func() (string, error) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			logger.Info("starting", "token", secret)
+			return buf.String(), nil
+		}},
+		{"LogValue in JSON", func() (string, error) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			logger.Info("starting", "token", secret, "config", cfg)
+			return buf.String(), nil
+		}},
+		{"MarshalJSON", func() (string, error) {
+			data, err := json.Marshal(cfg)
+			return string(data), err
+		}},
+		{"Print", func() (string, error) {
+			var buf bytes.Buffer
+			err := cfg.Print(&buf)
+			return buf.String(), err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := tt.output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(output, token) {
+				t.Errorf("output contains the token: %s", output)
+			}
+			if !strings.Contains(output, "REDACTED") {
+				t.Errorf("output does not say REDACTED: %s", output)
+			}
+		})
+	}
+}
+
+func TestSecretEmpty(t *testing.T) {
+	var secret config.Secret
+	if got := secret.String(); got != "" {
+		t.Errorf("String() = %q, want \"\"", got)
+	}
+	if got := secret.Value(); got != "" {
+		t.Errorf("Value() = %q, want \"\"", got)
+	}
+}
+
+func TestSecretNotSaved(t *testing.T) {
+	for _, file := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(file, func(t *testing.T) {
+			cfg := &config.Config{BotType: config.BotTypeMTG, DiscordToken: config.Secret(token)}
+
+			path := filepath.Join(t.TempDir(), file)
+			if err := cfg.Save(path); err != nil {
+				t.Fatalf("Save(): %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), token) || strings.Contains(string(data), "REDACTED") {
+				t.Errorf("saved file contains the secret:\n%s", data)
+			}
+			if cfg.DiscordToken.Value() != token {
+				t.Errorf("Save() cleared the token of the config itself")
+			}
+		})
+	}
+}
+
+func TestSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"mtg": {"discord_token": "file:`+tokenFile+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		path    string
+		wantErr bool
+	}{
+		{"plain value", map[string]string{"MTG_DISCORD_TOKEN": token}, "", false},
+		{"file reference", map[string]string{"MTG_DISCORD_TOKEN": "file:" + tokenFile}, "", false},
+		{"env reference", map[string]string{"MTG_DISCORD_TOKEN": "env:TEST_BOT_TOKEN", "TEST_BOT_TOKEN": token}, "", false},
+		{"_FILE variable", map[string]string{"MTG_DISCORD_TOKEN_FILE": tokenFile}, "", false},
+		{"file reference in config file", nil, configFile, false},
+		{"missing file", map[string]string{"MTG_DISCORD_TOKEN": "file:" + filepath.Join(dir, "missing")}, "", true},
+		{"unset env reference", map[string]string{"MTG_DISCORD_TOKEN": "env:TEST_BOT_TOKEN_UNSET"}, "", true},
+		{"_FILE and value both set", map[string]string{"MTG_DISCORD_TOKEN": token, "MTG_DISCORD_TOKEN_FILE": tokenFile}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MTG_DISCORD_TOKEN", "")
+			t.Setenv("MTG_DISCORD_TOKEN_FILE", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := config.LoadWith(config.BotTypeMTG, config.LoadOptions{Path: tt.path})
+			if tt.wantErr {
+				var fieldErr *config.FieldError
+				if !errors.As(err, &fieldErr) {
+					t.Fatalf("LoadWith() error = %v, want a *FieldError", err)
+				}
+				if fieldErr.Path != "mtg.discord_token" {
+					t.Errorf("FieldError.Path = %q, want %q", fieldErr.Path, "mtg.discord_token")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadWith(): %v", err)
+			}
+			if got := cfg.DiscordToken.Value(); got != token {
+				t.Errorf("DiscordToken = %q, want %q", got, token)
+			}
+		})
+	}
+}
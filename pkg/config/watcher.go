@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+// hotReloadable lists the config fields (by JSON name) that running bots can
+// apply without a restart. Any other changed field is reported as unapplied.
+var hotReloadable = map[string]bool{
+	"log_level":            true,
+	"command_cooldown":     true,
+	"allowed_channels":     true,
+	"guilds":               true,
+	"max_queue_size":       true,
+	"random_responses":     true,
+	"random_interval":      true,
+	"random_message_delay": true,
+	"cache_ttl":            true,
+	"volume_level":         true,
+	"features":             true,
+	"presence":             true,
+	"presence_interval":    true,
+	"presence_now_playing": true,
+}
+
+// Change describes the difference between two successfully loaded configurations.
+type Change struct {
+	Old *Config
+	New *Config
+
+	// Changed holds the JSON names of every field whose value differs.
+	Changed []string
+
+	// Unapplied holds the changed fields that only take effect after a restart.
+	Unapplied []string
+}
+
+// Has reports whether the named field (by JSON name) changed.
+func (c *Change) Has(field string) bool {
+	for _, name := range c.Changed {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscriber is notified after a new configuration has been loaded and validated.
+type Subscriber func(change *Change)
+
+// Watcher reloads a bot's configuration when the config file changes or the
+// process receives SIGHUP, and notifies subscribers about the changed fields.
+// Environment variables are re-read on every reload and command-line flags are
+// applied again, with the same precedence as LoadWith.
+type Watcher struct {
+	path         string
+	botType      BotType
+	pollInterval time.Duration
+
+	current     *Config
+	modTime     time.Time
+	subscribers []Subscriber
+	mu          sync.RWMutex
+
+	signals chan os.Signal
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewWatcher creates a watcher for the given config file, starting from the
+// configuration the bot is currently running with. An empty path limits
+// reloads to SIGHUP.
+func NewWatcher(path string, botType BotType, current *Config) *Watcher {
+	w := &Watcher{
+		path:         path,
+		botType:      botType,
+		pollInterval: 5 * time.Second,
+		current:      current,
+		signals:      make(chan os.Signal, 1),
+		stopCh:       make(chan struct{}),
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+
+	return w
+}
+
+// Subscribe registers a function to be called on every applied reload.
+func (w *Watcher) Subscribe(fn Subscriber) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Current returns the most recently applied configuration.
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Start begins polling the config file and listening for SIGHUP.
+func (w *Watcher) Start() {
+	signal.Notify(w.signals, syscall.SIGHUP)
+
+	w.wg.Add(1)
+	go w.watchLoop()
+}
+
+// Stop stops watching for changes.
+func (w *Watcher) Stop() {
+	signal.Stop(w.signals)
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+// watchLoop triggers reloads on file modification and SIGHUP.
+func (w *Watcher) watchLoop() {
+	defer w.wg.Done()
+
+	logger := logging.BotComponent(string(w.botType), "config-watcher")
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-w.signals:
+			logger.Info("Received SIGHUP, reloading configuration")
+			if err := w.Reload(); err != nil {
+				logger.Error("Configuration reload failed, keeping previous configuration", "error", err)
+			}
+		case <-ticker.C:
+			if !w.fileChanged() {
+				continue
+			}
+			logger.Info("Configuration file changed, reloading", "path", w.path)
+			if err := w.Reload(); err != nil {
+				logger.Error("Configuration reload failed, keeping previous configuration", "error", err)
+			}
+		}
+	}
+}
+
+// fileChanged reports whether the config file's modification time has moved.
+func (w *Watcher) fileChanged() bool {
+	if w.path == "" {
+		return false
+	}
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if info.ModTime().Equal(w.modTime) {
+		return false
+	}
+	w.modTime = info.ModTime()
+	return true
+}
+
+// Reload loads and validates the configuration, then notifies subscribers if
+// anything changed. An invalid configuration is rejected and the previous one
+// stays active.
+func (w *Watcher) Reload() error {
+	next, err := LoadWith(w.botType, LoadOptions{Path: w.path, Flags: w.Current().flags})
+	if err != nil {
+		return err
+	}
+
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	w.mu.Lock()
+	change := diffConfigs(w.current, next)
+	if len(change.Changed) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	w.current = next
+	subscribers := make([]Subscriber, len(w.subscribers))
+	copy(subscribers, w.subscribers)
+	w.mu.Unlock()
+
+	logger := logging.BotComponent(string(w.botType), "config-watcher")
+	logger.Info("Configuration reloaded", "changed", strings.Join(change.Changed, ","))
+	if len(change.Unapplied) > 0 {
+		logger.Warn("Some configuration changes require a restart to take effect",
+			"fields", strings.Join(change.Unapplied, ","))
+	}
+
+	for _, subscriber := range subscribers {
+		subscriber(change)
+	}
+
+	return nil
+}
+
+// diffConfigs compares two configurations field by field.
+func diffConfigs(old, next *Config) *Change {
+	change := &Change{Old: old, New: next}
+
+	oldValue := reflect.ValueOf(old).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	configType := oldValue.Type()
+
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		name := jsonFieldName(field)
+		if name == "" {
+			continue
+		}
+
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			continue
+		}
+
+		change.Changed = append(change.Changed, name)
+		if !hotReloadable[name] {
+			change.Unapplied = append(change.Unapplied, name)
+		}
+	}
+
+	return change
+}
+
+// jsonFieldName returns the JSON name of a struct field, or "" if it is not serialized.
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" || !field.IsExported() {
+		return ""
+	}
+
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+)
+
+// backupTimeFormat is the timestamp in backup file names.
+const backupTimeFormat = "20060102-150405"
+
+// BackupPath returns the file a backup of the database called name taken at
+// t is written to in dir: <name>-<UTC timestamp>.db.
+func BackupPath(dir, name string, t time.Time) string {
+	return filepath.Join(dir, name+"-"+t.UTC().Format(backupTimeFormat)+".db")
+}
+
+// backupFile is a backup found in a backup directory.
+type backupFile struct {
+	path    string
+	takenAt time.Time
+}
+
+// listBackups returns the backups of the database called name in dir,
+// newest first. Files not named by BackupPath are ignored.
+func listBackups(dir, name string) ([]backupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), name+"-")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		takenAt, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ".db"))
+		if err != nil || !strings.HasSuffix(stamp, ".db") {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, entry.Name()), takenAt: takenAt})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].takenAt.After(backups[j].takenAt)
+	})
+	return backups, nil
+}
+
+// PruneBackups deletes old backups of the database called name from dir and
+// returns the files removed. The newest keep backups are kept, and of those
+// only the ones taken within maxAge; zero disables either limit. The newest
+// backup is never removed.
+func PruneBackups(dir, name string, keep int, maxAge time.Duration) ([]string, error) {
+	backups, err := listBackups(dir, name)
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to list backups in "+dir, err)
+	}
+
+	now := time.Now()
+	var removed []string
+	for i, backup := range backups {
+		expired := maxAge > 0 && now.Sub(backup.takenAt) > maxAge
+		if i == 0 || (!expired && (keep <= 0 || i < keep)) {
+			continue
+		}
+
+		if err := os.Remove(backup.path); err != nil {
+			return removed, errors.NewDatabaseError("failed to remove backup "+backup.path, err)
+		}
+		removed = append(removed, backup.path)
+	}
+	return removed, nil
+}
@@ -0,0 +1,295 @@
+// Package database shares SQLite connection pools between the bots of a process.
+//
+// When several bots run in one process they often point at the same database
+// file, such as the shared audit log. Opening a path that is already open
+// returns a handle to the existing pool instead of a second one, so SQLite
+// sees a single writer per file and connections are reused. The pool is closed
+// when its last handle is closed.
+//
+// Every pool runs SQLite in WAL mode with a busy timeout, foreign keys and a
+// bounded number of connections (see DSN), and registers a health check with
+// the monitoring package while it is open.
+//
+// Backup and Restore copy whole database files, for example from the launcher's
+// db commands, and PruneBackups applies a retention to the backups written.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/monitoring"
+)
+
+// Connection settings of every pool. WAL lets readers work while one
+// connection writes, and writers wait up to busyTimeout for the write lock.
+// Transactions take the write lock when they begin (_txlock=immediate): a
+// transaction that reads first and upgrades to a write lock later fails with
+// "database is locked" instead of waiting when another connection writes.
+const (
+	busyTimeout     = 5 * time.Second
+	maxOpenConns    = 8
+	maxIdleConns    = 4
+	connMaxIdleTime = 5 * time.Minute
+	healthTimeout   = 2 * time.Second
+)
+
+// pool is an open database shared by one or more handles.
+type pool struct {
+	conn *sql.DB
+	refs int
+	// unregister removes the pool's health check.
+	unregister func()
+}
+
+// DSN returns the data source name the SQLite driver is opened with for
+// path: WAL journaling, a busy timeout, foreign key enforcement and
+// immediate transactions. Parameters already in path are kept.
+func DSN(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + fmt.Sprintf("_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_foreign_keys=1&_txlock=immediate",
+		busyTimeout.Milliseconds())
+}
+
+// OpenPool opens a connection pool for the SQLite database at path with the
+// settings of DSN and a bounded number of connections. Unlike Open, the pool
+// is not shared.
+func OpenPool(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite3", DSN(path))
+	if err != nil {
+		return nil, errors.NewDatabaseError("failed to open database "+path, err)
+	}
+
+	conn.SetMaxOpenConns(maxOpenConns)
+	conn.SetMaxIdleConns(maxIdleConns)
+	conn.SetConnMaxIdleTime(connMaxIdleTime)
+	return conn, nil
+}
+
+// Ping checks that the database of a pool can be queried.
+func Ping(ctx context.Context, conn *sql.DB) error {
+	var one int
+	if err := conn.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return errors.NewDatabaseError("database is not responding", err)
+	}
+	return nil
+}
+
+var (
+	pools   = make(map[string]*pool)
+	poolsMu sync.Mutex
+)
+
+// Handle is a reference to a shared connection pool. It embeds the pool, so
+// it can be used like a *sql.DB; Close releases the reference.
+type Handle struct {
+	*sql.DB
+	path string
+	once sync.Once
+}
+
+// Open returns a handle to the connection pool for the SQLite database at
+// path, opening the database if no other handle has it open.
+func Open(path string) (*Handle, error) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	p, ok := pools[path]
+	if !ok {
+		conn, err := OpenPool(path)
+		if err != nil {
+			return nil, err
+		}
+		p = &pool{conn: conn}
+		p.unregister = monitoring.RegisterHealthCheck("database:"+path, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+			defer cancel()
+			return Ping(ctx, conn)
+		})
+		pools[path] = p
+	}
+	p.refs++
+
+	return &Handle{DB: p.conn, path: path}, nil
+}
+
+// Close releases the handle. The pool is closed once every handle to it has
+// been closed. Closing a handle more than once has no further effect.
+func (h *Handle) Close() error {
+	var err error
+	h.once.Do(func() {
+		poolsMu.Lock()
+		defer poolsMu.Unlock()
+
+		p, ok := pools[h.path]
+		if !ok {
+			return
+		}
+
+		p.refs--
+		if p.refs == 0 {
+			delete(pools, h.path)
+			p.unregister()
+			err = p.conn.Close()
+		}
+	})
+	return err
+}
+
+// Path returns the path the handle was opened with.
+func (h *Handle) Path() string {
+	return h.path
+}
+
+// Backup writes a consistent copy of the SQLite database at path to dest
+// using VACUUM INTO. It is safe to run while other connections write to the
+// database. dest must not exist.
+func Backup(ctx context.Context, path, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return errors.NewValidationError("backup destination already exists: " + dest)
+	}
+
+	h, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	if _, err := h.ExecContext(ctx, "VACUUM INTO ?", dest); err != nil {
+		return errors.NewDatabaseError("failed to back up database "+path, err)
+	}
+	return nil
+}
+
+// Restore replaces the SQLite database at path with the backup at src. The
+// backup is checked with PRAGMA integrity_check first. An existing database
+// is checkpointed, so the transactions still in its write-ahead log are kept,
+// and moved to a timestamped path+".<time>.bak" together with any journal
+// files. A database that another connection has open, in this process or
+// another, is refused unless force is set; force also restores over a
+// database that cannot be checkpointed, e.g. because it is corrupt.
+func Restore(ctx context.Context, src, path string, force bool) error {
+	poolsMu.Lock()
+	_, inUse := pools[path]
+	poolsMu.Unlock()
+	if inUse && !force {
+		return errors.NewValidationError("database is in use: " + path)
+	}
+
+	if err := checkIntegrity(ctx, src); err != nil {
+		return err
+	}
+
+	_, err := os.Stat(path)
+	exists := err == nil
+	if exists && !force {
+		if err := checkpointAlone(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	// Copy next to the target first so the final rename is atomic
+	tmp := path + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		return errors.NewDatabaseError("failed to copy backup "+src, err)
+	}
+
+	if exists {
+		bak := path + "." + time.Now().UTC().Format(backupTimeFormat) + ".bak"
+		if _, err := os.Stat(bak); err == nil {
+			_ = os.Remove(tmp)
+			return errors.NewValidationError("previous database backup already exists: " + bak)
+		}
+		if err := os.Rename(path, bak); err != nil {
+			_ = os.Remove(tmp)
+			return errors.NewDatabaseError("failed to keep the current database", err)
+		}
+		// The journals belong to the replaced database; they must not be
+		// applied to the backup, and are kept with the database they belong to
+		for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+			if err := os.Rename(path+suffix, bak+suffix); err != nil && !os.IsNotExist(err) {
+				_ = os.Remove(tmp)
+				return errors.NewDatabaseError("failed to keep the journal of the current database", err)
+			}
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.NewDatabaseError("failed to restore database "+path, err)
+	}
+	return nil
+}
+
+// checkpointAlone moves the write-ahead log of the SQLite database at path
+// into the database file and leaves it in rollback journal mode, so the file
+// holds every committed transaction on its own. Leaving WAL mode needs the
+// only connection to the database, so it fails while a bot has it open.
+func checkpointAlone(ctx context.Context, path string) error {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=0")
+	if err != nil {
+		return errors.NewDatabaseError("failed to open database "+path, err)
+	}
+	defer conn.Close()
+
+	var mode string
+	err = conn.QueryRowContext(ctx, "PRAGMA journal_mode=DELETE").Scan(&mode)
+	if err == nil && mode != "delete" {
+		err = fmt.Errorf("journal mode is still %s", mode)
+	}
+	if err != nil {
+		return errors.NewValidationError(fmt.Sprintf("database %s is in use or cannot be checkpointed (%v); stop the bots using it first", path, err))
+	}
+	return nil
+}
+
+// checkIntegrity verifies that path is a readable, uncorrupted SQLite database.
+func checkIntegrity(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return errors.NewValidationError("backup not found: " + path)
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.NewDatabaseError("failed to open backup "+path, err)
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return errors.NewDatabaseError("backup is not a valid SQLite database: "+path, err)
+	}
+	if result != "ok" {
+		return errors.NewDatabaseError("backup failed the integrity check: "+result, nil)
+	}
+	return nil
+}
+
+// copyFile copies src to dest, replacing dest.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -9,8 +9,11 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/sawyer/go-discord-bots/pkg/config"
 	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/guilds"
 	"github.com/sawyer/go-discord-bots/pkg/logging"
 	"github.com/sawyer/go-discord-bots/pkg/metrics"
+	"github.com/sawyer/go-discord-bots/pkg/outbound"
+	"github.com/sawyer/go-discord-bots/pkg/presence"
 )
 
 // BotInterface defines the common interface that all bots must implement.
@@ -65,6 +68,9 @@ type BaseBot struct {
 	session       *discordgo.Session
 	handlers      map[string]CommandHandler
 	eventHandlers []EventHandler
+	presence      *presence.Manager
+	outbound      *outbound.Dispatcher
+	guilds        *guilds.Settings
 	startTime     time.Time
 	isConnected   bool
 }
@@ -75,7 +81,7 @@ func NewBaseBot(cfg *config.Config) (*BaseBot, error) {
 		return nil, errors.NewConfigError("invalid configuration", err)
 	}
 
-	session, err := discordgo.New("Bot " + cfg.DiscordToken)
+	session, err := discordgo.New("Bot " + cfg.DiscordToken.Value())
 	if err != nil {
 		return nil, errors.NewDiscordError("failed to create Discord session", err)
 	}
@@ -89,10 +95,18 @@ func NewBaseBot(cfg *config.Config) (*BaseBot, error) {
 	session.State.TrackVoice = false
 	session.State.TrackPresences = false
 
+	activity, err := presence.NewManager(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	bot := &BaseBot{
 		config:   cfg,
 		session:  session,
 		handlers: make(map[string]CommandHandler),
+		presence: activity,
+		outbound: outbound.NewDispatcher(cfg),
+		guilds:   guilds.NewSettings(cfg),
 	}
 
 	// Register default event handlers
@@ -127,6 +141,9 @@ func (b *BaseBot) Start() error {
 	b.startTime = time.Now()
 	b.isConnected = true
 
+	b.outbound.Start(b.session)
+	b.presence.Start(b.session)
+
 	logging.LogStartup(b.config.BotName, string(b.config.BotType), b.config.CommandPrefix, b.config.LogLevel, b.config.DebugMode)
 
 	return nil
@@ -139,6 +156,9 @@ func (b *BaseBot) Stop() error {
 
 	b.isConnected = false
 
+	b.presence.Stop()
+	b.outbound.Stop()
+
 	if b.session != nil {
 		if err := b.session.Close(); err != nil {
 			return errors.NewDiscordError("failed to close Discord connection", err)
@@ -160,6 +180,21 @@ func (b *BaseBot) GetSession() *discordgo.Session {
 	return b.session
 }
 
+// Presence returns the manager that rotates the bot's Discord status.
+func (b *BaseBot) Presence() *presence.Manager {
+	return b.presence
+}
+
+// Outbound returns the dispatcher that queues messages and edits to Discord.
+func (b *BaseBot) Outbound() *outbound.Dispatcher {
+	return b.outbound
+}
+
+// Guilds returns the bot's per-guild settings.
+func (b *BaseBot) Guilds() *guilds.Settings {
+	return b.guilds
+}
+
 // GetBotInfo returns information about the bot.
 func (b *BaseBot) GetBotInfo() BotInfo {
 	return BotInfo{
@@ -179,13 +214,6 @@ func (b *BaseBot) onReady(s *discordgo.Session, event *discordgo.Ready) {
 		"discriminator", event.User.Discriminator,
 		"guilds", len(event.Guilds),
 	)
-
-	// Set bot status
-	status := fmt.Sprintf("Ready | %s", b.config.CommandPrefix+"help")
-	err := s.UpdateGameStatus(0, status)
-	if err != nil {
-		logger.Warn("Failed to set bot status", "error", err)
-	}
 }
 
 // onDisconnect handles disconnect events.
@@ -223,6 +251,22 @@ func (b *BaseBot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCrea
 	command := strings.ToLower(parts[0])
 	args := parts[1:]
 
+	// Text commands are ignored outside the guild's allowed channels, so the
+	// bot stays quiet there; the cooldown is enforced with a reply
+	if !b.guilds.ChannelAllowed(m.GuildID, m.ChannelID) {
+		return
+	}
+	handler, exists := b.handlers[command]
+	if exists {
+		if message, ok := b.guilds.Check(m.GuildID, m.ChannelID, m.Author.ID, command); !ok {
+			if _, err := b.outbound.SendText(m.ChannelID, message); err != nil {
+				logger := logging.WithBot(b.config.BotName, string(b.config.BotType))
+				logger.Error("Failed to send command rejection", "error", err)
+			}
+			return
+		}
+	}
+
 	// Create command context
 	ctx := &CommandContext{
 		Session:   s,
@@ -233,7 +277,7 @@ func (b *BaseBot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCrea
 		Username:  m.Author.Username,
 		ChannelID: m.ChannelID,
 		GuildID:   m.GuildID,
-		BotConfig: b.config,
+		BotConfig: b.guilds.ConfigFor(m.GuildID),
 	}
 
 	// Execute command
@@ -241,7 +285,7 @@ func (b *BaseBot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCrea
 	var success bool
 	var err error
 
-	if handler, exists := b.handlers[command]; exists {
+	if exists {
 		err = handler(ctx)
 		success = err == nil
 	} else {
@@ -254,7 +298,7 @@ func (b *BaseBot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCrea
 
 	// Record metrics
 	metrics.RecordCommand(command, m.Author.ID, success, duration)
-	logging.LogDiscordCommand(m.Author.ID, m.Author.Username, command, success)
+	logging.LogDiscordCommand(string(b.config.BotType), m.Author.ID, m.Author.Username, command, success)
 
 	// Handle errors
 	if err != nil {
@@ -290,7 +334,7 @@ func (b *BaseBot) handleCommandError(ctx *CommandContext, err error) {
 	}
 
 	// Send error message to channel
-	_, sendErr := ctx.Session.ChannelMessageSend(ctx.ChannelID, message)
+	_, sendErr := b.outbound.SendText(ctx.ChannelID, message)
 	if sendErr != nil {
 		logger := logging.WithBot(b.config.BotName, string(b.config.BotType))
 		logger.Error("Failed to send error message", "error", sendErr)
@@ -339,6 +383,23 @@ func containsSuspiciousContent(input string) bool {
 	return false
 }
 
+// IsValidGuildID checks if the guild ID is a valid Discord snowflake.
+func IsValidGuildID(guildID string) bool {
+	// Discord snowflakes are 17-19 digit numbers
+	if len(guildID) < 17 || len(guildID) > 19 {
+		return false
+	}
+
+	// Check if all characters are digits
+	for _, char := range guildID {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
 // CreateEmbed creates a standardized Discord embed.
 func CreateEmbed(title, description, color string) *discordgo.MessageEmbed {
 	embed := &discordgo.MessageEmbed{
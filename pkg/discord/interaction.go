@@ -0,0 +1,62 @@
+package discord
+
+import "github.com/bwmarrin/discordgo"
+
+// GuildOnly restricts a command to servers that have installed the bot. It is
+// for commands that need a guild, such as voice or server administration.
+func GuildOnly(command *discordgo.ApplicationCommand) *discordgo.ApplicationCommand {
+	contexts := []discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+	integrationTypes := []discordgo.ApplicationIntegrationType{discordgo.ApplicationIntegrationGuildInstall}
+
+	command.Contexts = &contexts
+	command.IntegrationTypes = &integrationTypes
+	return command
+}
+
+// Everywhere allows a command in servers, DMs with the bot and group DMs, for
+// both server and user installs. Handlers of such commands must only reply
+// through the interaction: in user-installed contexts the bot is usually not a
+// member of the channel.
+func Everywhere(command *discordgo.ApplicationCommand) *discordgo.ApplicationCommand {
+	contexts := []discordgo.InteractionContextType{
+		discordgo.InteractionContextGuild,
+		discordgo.InteractionContextBotDM,
+		discordgo.InteractionContextPrivateChannel,
+	}
+	integrationTypes := []discordgo.ApplicationIntegrationType{
+		discordgo.ApplicationIntegrationGuildInstall,
+		discordgo.ApplicationIntegrationUserInstall,
+	}
+
+	command.Contexts = &contexts
+	command.IntegrationTypes = &integrationTypes
+	return command
+}
+
+// InteractionUser returns the user who triggered an interaction. In guilds the
+// user is only available through the member, in DMs only directly. It returns
+// nil if neither is set.
+func InteractionUser(i *discordgo.Interaction) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// InteractionUserID returns the ID of the user who triggered an interaction,
+// or "" if it is unknown.
+func InteractionUserID(i *discordgo.Interaction) string {
+	if user := InteractionUser(i); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// InteractionUsername returns the username of the user who triggered an
+// interaction, or "" if it is unknown.
+func InteractionUsername(i *discordgo.Interaction) string {
+	if user := InteractionUser(i); user != nil {
+		return user.Username
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"context"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+// Lifecycle is implemented by bots that can be started and stopped.
+type Lifecycle interface {
+	Start() error
+	Stop() error
+}
+
+// Reloadable is implemented by bots that apply configuration changes while
+// they run.
+type Reloadable interface {
+	ApplyConfig(change *config.Change)
+}
+
+// RunUntilDone starts bot, blocks until ctx is cancelled and then stops it.
+// Stopping may take at most timeout; if it takes longer, RunUntilDone returns
+// an error without waiting for it to finish.
+func RunUntilDone(ctx context.Context, bot Lifecycle, timeout time.Duration) error {
+	if err := bot.Start(); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- bot.Stop()
+	}()
+
+	select {
+	case err := <-stopped:
+		return err
+	case <-time.After(timeout):
+		return errors.NewInternalError("shutdown timeout exceeded", nil)
+	}
+}
+
+// RunWithWatcher runs bot like RunUntilDone while watcher watches for
+// configuration changes. A Reloadable bot is given every change; anything
+// else that follows the configuration subscribes to watcher beforehand.
+func RunWithWatcher(ctx context.Context, bot Lifecycle, watcher *config.Watcher, timeout time.Duration) error {
+	if reloadable, ok := bot.(Reloadable); ok {
+		watcher.Subscribe(reloadable.ApplyConfig)
+	}
+	watcher.Start()
+	defer watcher.Stop()
+
+	return RunUntilDone(ctx, bot, timeout)
+}
+
+// WatchLogLevel applies log_level changes in cfg.ConfigFile to the process
+// logger until the returned function is called. The log level belongs to the
+// process, so only its launcher watches it, never the bots it runs.
+func WatchLogLevel(cfg *config.Config) (stop func()) {
+	watcher := config.NewWatcher(cfg.ConfigFile, cfg.BotType, cfg)
+	watcher.Subscribe(func(change *config.Change) {
+		if change.Has("log_level") {
+			logging.SetLevel(change.New.LogLevel)
+		}
+	})
+	watcher.Start()
+	return watcher.Stop
+}
@@ -0,0 +1,169 @@
+package features
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+)
+
+// AdminCommand returns a slash command that lets guild administrators list and
+// override feature flags. Only members with Manage Server can see it.
+func AdminCommand(name string) *discordgo.ApplicationCommand {
+	manageGuild := int64(discordgo.PermissionManageServer)
+
+	flagOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "flag",
+		Description: "Feature flag name",
+		Required:    true,
+	}
+
+	return discord.GuildOnly(&discordgo.ApplicationCommand{
+		Name:                     name,
+		Description:              "Manage feature flags for this server",
+		DefaultMemberPermissions: &manageGuild,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Show feature flags for this server",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "enable",
+				Description: "Enable a feature in this server",
+				Options:     []*discordgo.ApplicationCommandOption{flagOption},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "disable",
+				Description: "Disable a feature in this server",
+				Options:     []*discordgo.ApplicationCommandOption{flagOption},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "reset",
+				Description: "Return a feature to its default for this server",
+				Options:     []*discordgo.ApplicationCommandOption{flagOption},
+			},
+		},
+	})
+}
+
+// HandleAdminCommand serves the command created by AdminCommand.
+func (m *Manager) HandleAdminCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if i.GuildID == "" {
+		return respondEphemeral(s, i, "❌ Feature flags can only be managed in a server.")
+	}
+	if i.Member == nil || i.Member.Permissions&discordgo.PermissionManageServer == 0 {
+		return respondEphemeral(s, i, "🚫 You need the Manage Server permission to change features.")
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return errors.NewValidationError("missing subcommand")
+	}
+
+	subcommand := options[0]
+	args := []string{subcommand.Name}
+	for _, option := range subcommand.Options {
+		if option.Name == "flag" {
+			args = append(args, option.StringValue())
+		}
+	}
+
+	reply, err := m.Execute(i.GuildID, args)
+	if err != nil {
+		return err
+	}
+
+	return respondEphemeral(s, i, reply)
+}
+
+// Execute runs a feature admin command ("list", "enable <flag>", "disable
+// <flag>" or "reset <flag>") for a guild and returns the reply text. Callers
+// are responsible for checking that the user may manage the guild.
+func (m *Manager) Execute(guildID string, args []string) (string, error) {
+	if len(args) == 0 || args[0] == "list" {
+		return m.formatStatuses(guildID), nil
+	}
+
+	if len(args) < 2 {
+		return fmt.Sprintf("Usage: %s <flag>", args[0]), nil
+	}
+
+	action, name := strings.ToLower(args[0]), args[1]
+	if !m.Known(name) {
+		return fmt.Sprintf("❌ Unknown feature `%s`.", name), nil
+	}
+
+	if (action == "enable" || action == "disable") && m.DarkLaunched(name) {
+		return fmt.Sprintf("❌ `%s` is being dark launched; only the bot operator can choose the servers that get it.", name), nil
+	}
+
+	switch action {
+	case "enable":
+		if err := m.SetOverride(guildID, name, true); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ Enabled `%s` for this server.", name), nil
+	case "disable":
+		if err := m.SetOverride(guildID, name, false); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ Disabled `%s` for this server.", name), nil
+	case "reset":
+		if err := m.ClearOverride(guildID, name); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ Reset `%s` to its default for this server.", name), nil
+	}
+
+	return fmt.Sprintf("❌ Unknown action `%s`. Use list, enable, disable or reset.", action), nil
+}
+
+// formatStatuses renders the flag list for a guild.
+func (m *Manager) formatStatuses(guildID string) string {
+	statuses := m.Statuses(guildID)
+	if len(statuses) == 0 {
+		return "No feature flags are defined."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("**Feature flags**\n")
+	for _, status := range statuses {
+		icon := "🔴"
+		if status.Enabled {
+			icon = "🟢"
+		}
+		sb.WriteString(fmt.Sprintf("%s `%s`", icon, status.Name))
+		if status.Overridden {
+			sb.WriteString(" (server override)")
+		}
+		if status.Description != "" {
+			sb.WriteString(" - " + status.Description)
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// RespondDisabled tells the user that a command is not enabled in this server.
+func RespondDisabled(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return respondEphemeral(s, i, "🚫 This command is not enabled in this server.")
+}
+
+// respondEphemeral sends a message only the invoking user can see.
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
@@ -0,0 +1,352 @@
+// Package features provides per-guild feature flags with percentage rollout.
+//
+// Flags are defined in code with a default, configured through the features
+// section of the bot configuration, and can be overridden per guild by guild
+// administrators at runtime. For a given guild a flag is evaluated in order:
+// guild override, disabled_guilds, guilds (dark launch), enabled, rollout.
+// Undefined and unconfigured flags are enabled.
+//
+// Command flags use the name "command.<command name>". A command whose flag is
+// off by default and only on for listed guilds is dark launched: it is
+// registered with Discord in those guilds only. Which guilds get a dark
+// launched command is up to the operator, so guild overrides of its flag are
+// refused and ignored.
+package features
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+// definition is a flag declared in code.
+type definition struct {
+	enabled     bool
+	description string
+}
+
+// Status describes how a flag evaluates for a guild.
+type Status struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	Overridden  bool   `json:"overridden"`
+}
+
+// Manager evaluates feature flags.
+type Manager struct {
+	bot         string
+	definitions map[string]definition
+	flags       map[string]config.FeatureFlag
+	overrides   map[string]map[string]bool // guild ID -> flag -> enabled
+	path        string
+	mu          sync.RWMutex
+}
+
+// NewManager creates a manager from the flags configured for a bot. Guild
+// overrides are loaded from and saved to cfg.FeaturesFile; an empty path keeps
+// them in memory.
+func NewManager(cfg *config.Config) (*Manager, error) {
+	m := &Manager{
+		bot:         string(cfg.BotType),
+		definitions: make(map[string]definition),
+		flags:       copyFlags(cfg.Features),
+		overrides:   make(map[string]map[string]bool),
+		path:        cfg.FeaturesFile,
+	}
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// CommandFlag returns the name of the flag controlling a command.
+func CommandFlag(command string) string {
+	return "command." + command
+}
+
+// Define declares a flag with its default value and a short description.
+// The default applies when the flag is not configured.
+func (m *Manager) Define(name string, enabled bool, description string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.definitions[name] = definition{enabled: enabled, description: description}
+}
+
+// Update replaces the configured flags, keeping guild overrides.
+func (m *Manager) Update(flags map[string]config.FeatureFlag) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flags = copyFlags(flags)
+}
+
+// Enabled reports whether a flag is on for a guild. An empty guild ID (DMs)
+// evaluates the flag's default.
+func (m *Manager) Enabled(name, guildID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	enabled, _ := m.evaluate(name, guildID)
+	return enabled
+}
+
+// evaluate resolves a flag for a guild and reports whether a guild override applied.
+func (m *Manager) evaluate(name, guildID string) (enabled, overridden bool) {
+	if guildID != "" && !m.darkLaunched(name) {
+		if value, ok := m.overrides[guildID][name]; ok {
+			return value, true
+		}
+	}
+
+	flag, configured := m.flags[name]
+	if !configured {
+		if def, ok := m.definitions[name]; ok {
+			return def.enabled, false
+		}
+		return true, false
+	}
+
+	if guildID != "" {
+		if contains(flag.DisabledGuilds, guildID) {
+			return false, false
+		}
+		if contains(flag.Guilds, guildID) {
+			return true, false
+		}
+	}
+
+	if flag.Enabled {
+		return true, false
+	}
+
+	if guildID != "" && flag.Rollout > 0 {
+		return bucket(name, guildID) < flag.Rollout, false
+	}
+
+	return false, false
+}
+
+// DarkLaunched reports whether a flag is the flag of a dark launched command:
+// a command flag that is off unless the configuration lists the guild, either
+// by its configured value or, when unconfigured, by its defined default.
+func (m *Manager) DarkLaunched(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.darkLaunched(name)
+}
+
+// darkLaunched implements DarkLaunched. The caller must hold the lock.
+func (m *Manager) darkLaunched(name string) bool {
+	if !strings.HasPrefix(name, CommandFlag("")) {
+		return false
+	}
+
+	flag, configured := m.flags[name]
+	if !configured {
+		def, defined := m.definitions[name]
+		return defined && !def.enabled
+	}
+	return !flag.Enabled && flag.Rollout == 0
+}
+
+// CommandGuilds returns the guild IDs a command should be registered in. The
+// default guild ("" for global) is used unless the command is dark launched,
+// in which case only the configured guilds where its flag is on are returned.
+func (m *Manager) CommandGuilds(command, defaultGuild string) []string {
+	name := CommandFlag(command)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.darkLaunched(name) {
+		return []string{defaultGuild}
+	}
+
+	if defaultGuild != "" {
+		if enabled, _ := m.evaluate(name, defaultGuild); enabled {
+			return []string{defaultGuild}
+		}
+		return nil
+	}
+
+	var result []string
+	for _, guildID := range m.flags[name].Guilds {
+		if enabled, _ := m.evaluate(name, guildID); enabled && !contains(result, guildID) {
+			result = append(result, guildID)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SetOverride turns a flag on or off for a single guild and persists the change.
+// Flags of dark launched commands cannot be overridden.
+func (m *Manager) SetOverride(guildID, name string, enabled bool) error {
+	if guildID == "" {
+		return errors.NewValidationError("feature overrides require a guild")
+	}
+
+	m.mu.Lock()
+	if m.darkLaunched(name) {
+		m.mu.Unlock()
+		return errors.NewValidationError("dark launched commands cannot be overridden per guild")
+	}
+	if m.overrides[guildID] == nil {
+		m.overrides[guildID] = make(map[string]bool)
+	}
+	m.overrides[guildID][name] = enabled
+	m.mu.Unlock()
+
+	logger := logging.BotComponent(m.bot, "features")
+	logger.Info("Feature override set", "guild_id", guildID, "flag", name, "enabled", enabled)
+
+	return m.save()
+}
+
+// ClearOverride removes a guild override so the configured value applies again.
+func (m *Manager) ClearOverride(guildID, name string) error {
+	m.mu.Lock()
+	delete(m.overrides[guildID], name)
+	if len(m.overrides[guildID]) == 0 {
+		delete(m.overrides, guildID)
+	}
+	m.mu.Unlock()
+
+	logger := logging.BotComponent(m.bot, "features")
+	logger.Info("Feature override cleared", "guild_id", guildID, "flag", name)
+
+	return m.save()
+}
+
+// Known reports whether a flag is defined in code or configured.
+func (m *Manager) Known(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, defined := m.definitions[name]
+	_, configured := m.flags[name]
+	return defined || configured
+}
+
+// Statuses returns every known flag as evaluated for a guild, sorted by name.
+func (m *Manager) Statuses(guildID string) []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make(map[string]bool)
+	for name := range m.definitions {
+		names[name] = true
+	}
+	for name := range m.flags {
+		names[name] = true
+	}
+
+	statuses := make([]Status, 0, len(names))
+	for name := range names {
+		enabled, overridden := m.evaluate(name, guildID)
+		statuses = append(statuses, Status{
+			Name:        name,
+			Description: m.definitions[name].description,
+			Enabled:     enabled,
+			Overridden:  overridden,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// load reads persisted guild overrides.
+func (m *Manager) load() error {
+	if m.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.NewConfigError("failed to read feature overrides", err)
+	}
+
+	if err := json.Unmarshal(data, &m.overrides); err != nil {
+		return errors.NewConfigError("failed to parse feature overrides", err)
+	}
+	if m.overrides == nil {
+		m.overrides = make(map[string]map[string]bool)
+	}
+
+	return nil
+}
+
+// save writes guild overrides atomically.
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	m.mu.RLock()
+	data, err := json.MarshalIndent(m.overrides, "", "  ")
+	m.mu.RUnlock()
+	if err != nil {
+		return errors.NewInternalError("failed to encode feature overrides", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".features-*.json")
+	if err != nil {
+		return errors.NewInternalError("failed to write feature overrides", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.NewInternalError("failed to write feature overrides", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.NewInternalError("failed to write feature overrides", err)
+	}
+
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		return errors.NewInternalError("failed to write feature overrides", err)
+	}
+
+	return nil
+}
+
+// bucket maps a flag and guild to a stable value in [0, 100).
+func bucket(name, guildID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + ":" + guildID))
+	return int(h.Sum32() % 100)
+}
+
+// contains reports whether list contains value.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// copyFlags returns a copy of the configured flags.
+func copyFlags(flags map[string]config.FeatureFlag) map[string]config.FeatureFlag {
+	result := make(map[string]config.FeatureFlag, len(flags))
+	for name, flag := range flags {
+		result[name] = flag
+	}
+	return result
+}
@@ -0,0 +1,145 @@
+// Package guilds applies the per-guild overrides of the guilds config section
+// at runtime.
+//
+// Settings holds a bot's current configuration, resolves it for a guild with
+// ConfigFor and enforces the per-guild command rules: commands are only
+// accepted in the allowed channels, and each user must wait the guild's
+// command cooldown between two uses of the same command.
+package guilds
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+)
+
+// pruneThreshold is the number of tracked cooldowns above which expired
+// entries are removed.
+const pruneThreshold = 1000
+
+// Settings resolves per-guild configuration and tracks command cooldowns. It
+// is safe for concurrent use.
+type Settings struct {
+	config    *config.Config
+	cooldowns map[string]time.Time // guild/user/command -> last use
+	mu        sync.RWMutex
+}
+
+// NewSettings creates settings for a bot's configuration.
+func NewSettings(cfg *config.Config) *Settings {
+	return &Settings{
+		config:    cfg,
+		cooldowns: make(map[string]time.Time),
+	}
+}
+
+// Update replaces the configuration after a reload. Running cooldowns are
+// kept and measured against the new cooldown settings.
+func (s *Settings) Update(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = cfg
+}
+
+// ConfigFor returns the configuration that applies in a guild. The result
+// must not be modified.
+func (s *Settings) ConfigFor(guildID string) *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.ConfigFor(guildID)
+}
+
+// ChannelAllowed reports whether commands may be used in a channel of a
+// guild. Channel allowlists only restrict guild channels: DMs and user
+// installed commands outside a guild are always allowed.
+func (s *Settings) ChannelAllowed(guildID, channelID string) bool {
+	if guildID == "" {
+		return true
+	}
+	return s.ConfigFor(guildID).ChannelAllowed(channelID)
+}
+
+// Check reports whether a user may run a command in a channel of a guild. If
+// not, it returns the message to show the user. An allowed command starts
+// the user's cooldown for that command.
+func (s *Settings) Check(guildID, channelID, userID, command string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg := s.config.ConfigFor(guildID)
+	if guildID != "" && !cfg.ChannelAllowed(channelID) {
+		return "🚫 Commands can't be used in this channel.", false
+	}
+
+	cooldown := cfg.CommandCooldown.Std()
+	if cooldown <= 0 || userID == "" {
+		return "", true
+	}
+
+	key := guildID + "/" + userID + "/" + command
+	now := time.Now()
+	if lastUsed, ok := s.cooldowns[key]; ok {
+		if remaining := cooldown - now.Sub(lastUsed); remaining > 0 {
+			return fmt.Sprintf("⏳ Command is on cooldown. Try again in %.1f seconds.", remaining.Seconds()), false
+		}
+	}
+
+	s.cooldowns[key] = now
+	if len(s.cooldowns) > pruneThreshold {
+		s.prune(now)
+	}
+	return "", true
+}
+
+// CheckInteraction checks an application command with Check and, if it may
+// not run, responds to the interaction with the reason. It returns whether
+// the command may run. Other interactions are always allowed.
+func (s *Settings) CheckInteraction(session *discordgo.Session, i *discordgo.InteractionCreate) (bool, error) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return true, nil
+	}
+
+	message, ok := s.Check(i.GuildID, i.ChannelID, interactionUserID(i), i.ApplicationCommandData().Name)
+	if ok {
+		return true, nil
+	}
+
+	return false, session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// prune removes cooldowns that have expired under every guild's settings.
+// The caller must hold the write lock.
+func (s *Settings) prune(now time.Time) {
+	longest := s.config.CommandCooldown
+	for _, guild := range s.config.Guilds {
+		if guild.CommandCooldown != nil && *guild.CommandCooldown > longest {
+			longest = *guild.CommandCooldown
+		}
+	}
+
+	for key, lastUsed := range s.cooldowns {
+		if now.Sub(lastUsed) >= longest.Std() {
+			delete(s.cooldowns, key)
+		}
+	}
+}
+
+// interactionUserID returns the ID of the user who created an interaction.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
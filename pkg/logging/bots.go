@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// botLoggers holds the loggers of the bots run in this process, by bot type.
+var (
+	botLoggers   = make(map[string]*slog.Logger)
+	botLoggersMu sync.RWMutex
+)
+
+// AddBot gives the bot of type bot its own logger, which adds bot=<bot> to
+// every record and, if file is not nil, also writes them to file. The
+// launcher adds one for each bot it runs in this process, so their records
+// can be told apart; a bot running on its own has none. InitializeLogger
+// must be called first, and its level and format apply.
+func AddBot(bot string, file io.Writer) {
+	out := io.Writer(os.Stdout)
+	if file != nil {
+		out = io.MultiWriter(os.Stdout, file)
+	}
+	logger := slog.New(newHandler(out, jsonOutput)).With("bot", bot)
+
+	botLoggersMu.Lock()
+	defer botLoggersMu.Unlock()
+	botLoggers[bot] = logger
+}
+
+// ForBot returns the logger of the bot of type bot, or the default logger if
+// AddBot was not called for it.
+func ForBot(bot string) *slog.Logger {
+	botLoggersMu.RLock()
+	defer botLoggersMu.RUnlock()
+
+	if logger, ok := botLoggers[bot]; ok {
+		return logger
+	}
+	return DefaultLogger
+}
+
+// BotComponent returns the logger of a bot with a component field.
+func BotComponent(bot, component string) *slog.Logger {
+	return ForBot(bot).With("component", component)
+}
@@ -4,6 +4,7 @@ package logging
 import (
 	"context"
 	"errors"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -13,6 +14,12 @@ import (
 // DefaultLogger is the global logger instance.
 var DefaultLogger *slog.Logger
 
+// levelVar holds the active log level so it can be changed at runtime.
+var levelVar = new(slog.LevelVar)
+
+// jsonOutput records the format chosen by InitializeLogger for bot loggers.
+var jsonOutput bool
+
 // LogLevel represents the logging level.
 type LogLevel string
 
@@ -36,23 +43,17 @@ type BotError interface {
 
 // InitializeLogger initializes the global logger with the specified level and format.
 func InitializeLogger(level string, jsonFormat bool) {
-	var slogLevel slog.Level
+	levelVar.Set(parseLevel(level))
+	jsonOutput = jsonFormat
 
-	switch strings.ToLower(level) {
-	case "debug":
-		slogLevel = slog.LevelDebug
-	case "info":
-		slogLevel = slog.LevelInfo
-	case "warn", "warning":
-		slogLevel = slog.LevelWarn
-	case "error":
-		slogLevel = slog.LevelError
-	default:
-		slogLevel = slog.LevelInfo
-	}
+	DefaultLogger = slog.New(newHandler(os.Stdout, jsonFormat))
+	slog.SetDefault(DefaultLogger)
+}
 
+// newHandler creates a handler writing records to w at the global level.
+func newHandler(w io.Writer, jsonFormat bool) slog.Handler {
 	opts := &slog.HandlerOptions{
-		Level: slogLevel,
+		Level: levelVar,
 		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
 			// Customize timestamp format
 			if a.Key == slog.TimeKey {
@@ -62,15 +63,31 @@ func InitializeLogger(level string, jsonFormat bool) {
 		},
 	}
 
-	var handler slog.Handler
 	if jsonFormat {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		return slog.NewJSONHandler(w, opts)
 	}
+	return slog.NewTextHandler(w, opts)
+}
 
-	DefaultLogger = slog.New(handler)
-	slog.SetDefault(DefaultLogger)
+// SetLevel changes the level of the global logger without rebuilding it.
+func SetLevel(level string) {
+	levelVar.Set(parseLevel(level))
+}
+
+// parseLevel converts a textual log level into a slog level.
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "info":
+		return slog.LevelInfo
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
 }
 
 // WithContext returns a logger with context values.
@@ -83,9 +100,10 @@ func WithComponent(component string) *slog.Logger {
 	return DefaultLogger.With("component", component)
 }
 
-// WithBot returns a logger with bot-specific information.
+// WithBot returns the logger of a bot (see ForBot) with bot-specific
+// information.
 func WithBot(botName, botType string) *slog.Logger {
-	return DefaultLogger.With("bot_name", botName, "bot_type", botType)
+	return ForBot(botType).With("bot_name", botName, "bot_type", botType)
 }
 
 // WithUser returns a logger with user information.
@@ -189,9 +207,9 @@ func LogAPIRequest(service, endpoint string, duration time.Duration, success boo
 	)
 }
 
-// LogDiscordCommand logs Discord command execution.
-func LogDiscordCommand(userID, username, command string, success bool) {
-	logger := WithComponent("discord").With(
+// LogDiscordCommand logs Discord command execution by a bot.
+func LogDiscordCommand(bot, userID, username, command string, success bool) {
+	logger := BotComponent(bot, "discord").With(
 		"user_id", userID,
 		"username", username,
 		"command", command,
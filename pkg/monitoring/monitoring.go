@@ -3,6 +3,7 @@ package monitoring
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -19,6 +20,7 @@ type Monitor struct {
 	healthCheck     *HealthChecker
 	metricsExporter *MetricsExporter
 	httpServer      *http.Server
+	startTime       time.Time
 	mu              sync.RWMutex
 	isRunning       bool
 }
@@ -71,6 +73,7 @@ func (m *Monitor) Start() error {
 		}
 	}()
 
+	m.startTime = time.Now()
 	m.isRunning = true
 	logger.Info("Monitoring system started", "port", m.httpServer.Addr)
 
@@ -130,20 +133,33 @@ func (m *Monitor) metricsEndpoint(w http.ResponseWriter, r *http.Request) {
 	_, _ = fmt.Fprint(w, metrics)
 }
 
+// Status is the document served by the /status endpoint.
+type Status struct {
+	Uptime    string                 `json:"uptime"`
+	Version   string                 `json:"version"`
+	GoVersion string                 `json:"go_version"`
+	Metrics   map[string]interface{} `json:"metrics"`
+	Health    *HealthStatus          `json:"health"`
+	Alerts    []*Alert               `json:"alerts"`
+}
+
 // statusEndpoint provides detailed status information.
 func (m *Monitor) statusEndpoint(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	status := map[string]interface{}{
-		"uptime":     time.Since(time.Now()).String(),
-		"version":    "2.0.0",
-		"go_version": runtime.Version(),
-		"metrics":    metrics.GetMetricsSummary(),
-		"health":     m.healthCheck.GetStatus(),
-		"alerts":     m.alertManager.GetActiveAlerts(),
+	m.mu.RLock()
+	startTime := m.startTime
+	m.mu.RUnlock()
+
+	status := Status{
+		Uptime:    time.Since(startTime).Round(time.Second).String(),
+		Version:   "2.0.0",
+		GoVersion: runtime.Version(),
+		Metrics:   metrics.GetMetricsSummary(),
+		Health:    m.healthCheck.GetStatus(),
+		Alerts:    m.alertManager.GetActiveAlerts(),
 	}
 
-	_, _ = fmt.Fprintf(w, "%+v", status)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
 }
 
 // alertsEndpoint handles alert webhook requests.
@@ -155,7 +171,7 @@ func (m *Monitor) alertsEndpoint(w http.ResponseWriter, r *http.Request) {
 		// Return active alerts
 		alerts := m.alertManager.GetActiveAlerts()
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprintf(w, "%+v", alerts)
+		_ = json.NewEncoder(w).Encode(alerts)
 	}
 }
 
@@ -356,6 +372,28 @@ type HealthChecker struct {
 // HealthCheck represents a health check function.
 type HealthCheck func() error
 
+// registeredChecks are health checks added by other packages with
+// RegisterHealthCheck. Every HealthChecker runs them next to its own.
+var (
+	registeredChecks   = make(map[string]HealthCheck)
+	registeredChecksMu sync.RWMutex
+)
+
+// RegisterHealthCheck adds a health check that every HealthChecker runs,
+// such as a database ping, and returns a function that removes it again.
+// Registering a name twice replaces the earlier check.
+func RegisterHealthCheck(name string, check HealthCheck) (unregister func()) {
+	registeredChecksMu.Lock()
+	defer registeredChecksMu.Unlock()
+
+	registeredChecks[name] = check
+	return func() {
+		registeredChecksMu.Lock()
+		defer registeredChecksMu.Unlock()
+		delete(registeredChecks, name)
+	}
+}
+
 // HealthStatus represents the overall health status.
 type HealthStatus struct {
 	Overall   string                 `json:"overall"`
@@ -435,7 +473,15 @@ func (hc *HealthChecker) checkLoop() {
 	}
 }
 
-// runChecks runs all health checks.
+// AddCheck adds a health check to this checker, replacing any check with the
+// same name.
+func (hc *HealthChecker) AddCheck(name string, check HealthCheck) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.checks[name] = check
+}
+
+// runChecks runs all health checks, including the registered ones.
 func (hc *HealthChecker) runChecks() {
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
@@ -443,7 +489,17 @@ func (hc *HealthChecker) runChecks() {
 	results := make(map[string]CheckResult)
 	overall := "healthy"
 
+	checks := make(map[string]HealthCheck, len(hc.checks))
+	registeredChecksMu.RLock()
+	for name, check := range registeredChecks {
+		checks[name] = check
+	}
+	registeredChecksMu.RUnlock()
 	for name, check := range hc.checks {
+		checks[name] = check
+	}
+
+	for name, check := range checks {
 		if err := check(); err != nil {
 			results[name] = CheckResult{
 				Status: "unhealthy",
@@ -0,0 +1,384 @@
+// Package outbound queues messages and edits bots send to Discord.
+//
+// Each channel (and each interaction's webhook) has its own lane, so messages
+// to one channel are delivered in the order they were queued while different
+// channels proceed independently. discordgo's per-bucket rate limiter still
+// holds requests until their bucket has room; the dispatcher adds retries with
+// backoff for rate limits, server errors and network failures, bounded lanes
+// that drop new messages instead of piling up, and coalescing of edits: an edit
+// that has not been sent yet is replaced by a newer edit of the same message.
+package outbound
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+const (
+	// baseBackoff is the wait before the first retry; it doubles per attempt.
+	baseBackoff = 500 * time.Millisecond
+
+	// maxBackoff caps the wait between retries.
+	maxBackoff = 30 * time.Second
+)
+
+// sendFunc performs a single Discord request.
+type sendFunc func(options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+// result is the outcome of a request.
+type result struct {
+	message *discordgo.Message
+	err     error
+}
+
+// request is a queued message or edit.
+type request struct {
+	// key identifies the message an edit targets; it is empty for new messages,
+	// which are never coalesced.
+	key     string
+	send    sendFunc
+	waiters []chan result
+}
+
+// lane holds the queued requests of one channel or interaction.
+type lane struct {
+	pending []*request
+}
+
+// Dispatcher delivers queued requests over a Discord session.
+type Dispatcher struct {
+	bot        string
+	queueSize  int
+	maxRetries int
+	timeout    time.Duration
+
+	session *discordgo.Session
+	lanes   map[string]*lane
+	depth   int
+	stopped bool
+	mu      sync.Mutex
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher from the outbound settings of cfg.
+func NewDispatcher(cfg *config.Config) *Dispatcher {
+	return &Dispatcher{
+		bot:        string(cfg.BotType),
+		queueSize:  cfg.OutboundQueueSize,
+		maxRetries: cfg.MaxRetries,
+		timeout:    cfg.ShutdownTimeout.Std(),
+		lanes:      make(map[string]*lane),
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Start begins delivering over session. Requests queued before Start fail.
+func (d *Dispatcher) Start(session *discordgo.Session) {
+	d.mu.Lock()
+	d.session = session
+	d.mu.Unlock()
+}
+
+// Stop stops accepting requests and waits up to the shutdown timeout for the
+// queued ones to be delivered. Requests still queued after that fail.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d.timeout):
+		logger := logging.BotComponent(d.bot, "outbound")
+		logger.Warn("Outbound queue not drained before shutdown", "pending", d.Depth())
+	}
+
+	close(d.stopCh)
+	d.wg.Wait()
+}
+
+// Depth returns the number of queued requests across all lanes.
+func (d *Dispatcher) Depth() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.depth
+}
+
+// Send sends a message to a channel and waits until it is delivered or fails.
+func (d *Dispatcher) Send(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	return d.wait(d.enqueue(channelID, "", func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return d.session.ChannelMessageSendComplex(channelID, data, options...)
+	}))
+}
+
+// SendText sends a plain text message to a channel.
+func (d *Dispatcher) SendText(channelID, content string) (*discordgo.Message, error) {
+	return d.Send(channelID, &discordgo.MessageSend{Content: content})
+}
+
+// SendEmbed sends an embed to a channel.
+func (d *Dispatcher) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return d.Send(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+// SendAsync queues a message without waiting for it. Failures are logged.
+func (d *Dispatcher) SendAsync(channelID string, data *discordgo.MessageSend) {
+	results := d.enqueue(channelID, "", func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return d.session.ChannelMessageSendComplex(channelID, data, options...)
+	})
+
+	go func() {
+		if r := <-results; r.err != nil {
+			logger := logging.BotComponent(d.bot, "outbound").With("channel_id", channelID)
+			logging.LogError(logger, r.err, "Failed to deliver queued message")
+		}
+	}()
+}
+
+// EditMessage edits a message. If an earlier edit of the same message is still
+// queued, it is replaced and both callers receive the result of this one.
+func (d *Dispatcher) EditMessage(edit *discordgo.MessageEdit) (*discordgo.Message, error) {
+	return d.wait(d.enqueue(edit.Channel, "message:"+edit.ID, func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return d.session.ChannelMessageEditComplex(edit, options...)
+	}))
+}
+
+// EditInteraction edits the original response of an interaction, coalescing
+// queued edits like EditMessage.
+func (d *Dispatcher) EditInteraction(interaction *discordgo.Interaction, edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	// Interaction responses are sent through the interaction's webhook, not
+	// the channel, so they get a lane of their own.
+	laneKey := "interaction:" + interaction.Token
+	return d.wait(d.enqueue(laneKey, laneKey+":original", func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		return d.session.InteractionResponseEdit(interaction, edit, options...)
+	}))
+}
+
+// wait blocks until a request completes.
+func (d *Dispatcher) wait(results <-chan result) (*discordgo.Message, error) {
+	r := <-results
+	return r.message, r.err
+}
+
+// enqueue adds a request to a lane, starting the lane's worker if it is idle.
+// The returned channel receives the request's result.
+func (d *Dispatcher) enqueue(laneKey, key string, send sendFunc) <-chan result {
+	results := make(chan result, 1)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped || d.session == nil {
+		results <- result{err: errors.NewDiscordError("outbound dispatcher is not running", nil)}
+		return results
+	}
+
+	l, running := d.lanes[laneKey]
+	if !running {
+		l = &lane{}
+		d.lanes[laneKey] = l
+	}
+
+	if key != "" {
+		for _, queued := range l.pending {
+			if queued.key == key {
+				queued.send = send
+				queued.waiters = append(queued.waiters, results)
+				metrics.RecordPerformanceMetric("outbound", "coalesced", 1, "count")
+				return results
+			}
+		}
+	}
+
+	if len(l.pending) >= d.queueSize {
+		metrics.RecordPerformanceMetric("outbound", "dropped", 1, "count")
+		results <- result{err: errors.WithContext(
+			errors.NewRateLimitError("outbound queue full, message dropped", 0), "lane", laneKey,
+		)}
+		return results
+	}
+
+	l.pending = append(l.pending, &request{key: key, send: send, waiters: []chan result{results}})
+	d.depth++
+	metrics.RecordPerformanceMetric("outbound", "queue_depth", float64(d.depth), "count")
+
+	if !running {
+		d.wg.Add(1)
+		go d.run(laneKey, l)
+	}
+
+	return results
+}
+
+// next removes and returns the first request of a lane. When the lane is empty
+// it is removed and nil is returned, ending the lane's worker.
+func (d *Dispatcher) next(laneKey string, l *lane) *request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(l.pending) == 0 {
+		delete(d.lanes, laneKey)
+		return nil
+	}
+
+	req := l.pending[0]
+	l.pending = l.pending[1:]
+	d.depth--
+	metrics.RecordPerformanceMetric("outbound", "queue_depth", float64(d.depth), "count")
+
+	return req
+}
+
+// superseded hands the waiters of an in-flight edit over to a newer queued
+// edit of the same message. It reports whether there was one.
+func (d *Dispatcher) superseded(l *lane, req *request) bool {
+	if req.key == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, queued := range l.pending {
+		if queued.key == req.key {
+			queued.waiters = append(queued.waiters, req.waiters...)
+			metrics.RecordPerformanceMetric("outbound", "coalesced", 1, "count")
+			return true
+		}
+	}
+
+	return false
+}
+
+// run delivers the requests of one lane in order.
+func (d *Dispatcher) run(laneKey string, l *lane) {
+	defer d.wg.Done()
+
+	for req := d.next(laneKey, l); req != nil; req = d.next(laneKey, l) {
+		select {
+		case <-d.stopCh:
+			req.resolve(result{err: errors.NewDiscordError("outbound dispatcher stopped before delivery", nil)})
+		default:
+			d.deliver(laneKey, l, req)
+		}
+	}
+}
+
+// deliver sends a request, retrying retryable failures, and passes the result
+// to its waiters.
+func (d *Dispatcher) deliver(laneKey string, l *lane, req *request) {
+	logger := logging.BotComponent(d.bot, "outbound").With("lane", laneKey)
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		// Rate limits and server errors are retried here rather than inside
+		// discordgo, so they are counted and bounded like any other failure.
+		message, err := req.send(discordgo.WithRetryOnRatelimit(false), discordgo.WithRestRetries(0))
+		metrics.RecordAPIRequest("discord", "outbound", err == nil, time.Since(start))
+
+		if err == nil {
+			req.resolve(result{message: message})
+			return
+		}
+
+		err = classify(err)
+		if !errors.IsRetryable(err) || attempt >= d.maxRetries {
+			req.resolve(result{err: err})
+			return
+		}
+
+		wait := retryDelay(err, attempt)
+		logger.Debug("Retrying outbound request", "attempt", attempt+1, "wait", wait, "error", err)
+		metrics.RecordPerformanceMetric("outbound", "retries", 1, "count")
+
+		select {
+		case <-time.After(wait):
+		case <-d.stopCh:
+			req.resolve(result{err: errors.NewDiscordError("outbound dispatcher stopped before delivery", err)})
+			return
+		}
+
+		// A newer edit makes retrying this one pointless.
+		if d.superseded(l, req) {
+			return
+		}
+	}
+}
+
+// resolve passes a result to every waiter of a request.
+func (r *request) resolve(res result) {
+	for _, waiter := range r.waiters {
+		waiter <- res
+	}
+}
+
+// classify converts a discordgo error into a typed error, so that rate limits,
+// server errors and network failures are recognized as retryable.
+func classify(err error) error {
+	var rateLimit *discordgo.RateLimitError
+	if stderrors.As(err, &rateLimit) {
+		botErr := errors.NewRateLimitError("discord rate limit exceeded", int(rateLimit.RetryAfter.Seconds()))
+		botErr.Cause = err
+		return errors.WithContext(botErr, "retry_after_ms", rateLimit.RetryAfter.Milliseconds())
+	}
+
+	var restErr *discordgo.RESTError
+	if stderrors.As(err, &restErr) && restErr.Response != nil {
+		botErr := errors.FromHTTPStatus(restErr.Response.StatusCode, fmt.Sprintf("discord request failed with status %d", restErr.Response.StatusCode))
+		botErr.Cause = err
+		return botErr
+	}
+
+	// discordgo reports a 502 as a plain error once its own retries, which the
+	// dispatcher disables, are used up.
+	if strings.HasPrefix(err.Error(), "Exceeded Max retries") {
+		botErr := errors.FromHTTPStatus(http.StatusBadGateway, "discord request failed with status 502")
+		botErr.Cause = err
+		return botErr
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		return errors.NewNetworkError("discord request failed", err)
+	}
+
+	return errors.NewDiscordError("discord request failed", err)
+}
+
+// retryDelay returns how long to wait before retrying a failed request. Rate
+// limits are honoured exactly; other failures back off exponentially.
+func retryDelay(err error, attempt int) time.Duration {
+	var rateLimit *discordgo.RateLimitError
+	if stderrors.As(err, &rateLimit) && rateLimit.RetryAfter > 0 {
+		return rateLimit.RetryAfter
+	}
+
+	delay := baseBackoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,371 @@
+package outbound
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+func TestMain(m *testing.M) {
+	logging.InitializeLogger("error", false)
+	os.Exit(m.Run())
+}
+
+// newTestDispatcher returns a started dispatcher whose requests are sent by
+// the sendFuncs passed to enqueue rather than over a real session.
+func newTestDispatcher(t *testing.T, maxRetries, queueSize int) *Dispatcher {
+	t.Helper()
+
+	d := NewDispatcher(&config.Config{
+		BotType:           "test",
+		MaxRetries:        maxRetries,
+		OutboundQueueSize: queueSize,
+		ShutdownTimeout:   config.Duration(5 * time.Second),
+	})
+	d.Start(&discordgo.Session{})
+	t.Cleanup(d.Stop)
+	return d
+}
+
+// fakeSender records the requests sent through it. Each call to fn returns a
+// sendFunc that fails with the given errors in turn and then succeeds with a
+// message whose ID is the request's name.
+type fakeSender struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeSender) fn(name string, failures ...error) sendFunc {
+	return func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.calls = append(f.calls, name)
+		if len(failures) > 0 {
+			err := failures[0]
+			failures = failures[1:]
+			return nil, err
+		}
+		return &discordgo.Message{ID: name}, nil
+	}
+}
+
+func (f *fakeSender) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func rateLimited(retryAfter time.Duration) error {
+	return &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+		TooManyRequests: &discordgo.TooManyRequests{RetryAfter: retryAfter},
+		URL:             "https://discord.com/api/v9/channels/1/messages",
+	}}
+}
+
+func httpStatus(code int) error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: code, Status: http.StatusText(code)}}
+}
+
+func networkFailure() error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: stderrors.New("connection refused")}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		errorType errors.ErrorType
+		retryable bool
+	}{
+		{"rate limit", rateLimited(time.Second), errors.ErrorTypeRateLimit, true},
+		{"429", httpStatus(http.StatusTooManyRequests), errors.ErrorTypeRateLimit, true},
+		{"500", httpStatus(http.StatusInternalServerError), errors.ErrorTypeAPI, true},
+		{"503", httpStatus(http.StatusServiceUnavailable), errors.ErrorTypeAPI, true},
+		{"502 after discordgo retries", stderrors.New("Exceeded Max retries HTTP 502 Bad Gateway"), errors.ErrorTypeAPI, true},
+		{"network", networkFailure(), errors.ErrorTypeNetwork, true},
+		{"400", httpStatus(http.StatusBadRequest), errors.ErrorTypeValidation, false},
+		{"403", httpStatus(http.StatusForbidden), errors.ErrorTypePermission, false},
+		{"404", httpStatus(http.StatusNotFound), errors.ErrorTypeNotFound, false},
+		{"other", stderrors.New("unknown failure"), errors.ErrorTypeDiscord, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classify(tt.err)
+
+			var botErr *errors.BotError
+			if !stderrors.As(err, &botErr) {
+				t.Fatalf("classify() = %T, want *errors.BotError", err)
+			}
+			if botErr.ErrorType != tt.errorType {
+				t.Errorf("ErrorType = %v, want %v", botErr.ErrorType, tt.errorType)
+			}
+			if got := errors.IsRetryable(err); got != tt.retryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.retryable)
+			}
+			if !stderrors.Is(err, tt.err) {
+				t.Errorf("classify() does not wrap the original error")
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		attempt int
+		want    time.Duration
+	}{
+		{"rate limit honours retry_after", rateLimited(1500 * time.Millisecond), 3, 1500 * time.Millisecond},
+		{"server error, first retry", httpStatus(http.StatusInternalServerError), 0, baseBackoff},
+		{"server error, second retry", httpStatus(http.StatusInternalServerError), 1, 2 * baseBackoff},
+		{"server error, third retry", httpStatus(http.StatusBadGateway), 2, 4 * baseBackoff},
+		{"capped", networkFailure(), 10, maxBackoff},
+		{"overflow", networkFailure(), 100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryDelay(classify(tt.err), tt.attempt); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		failures   []error
+		wantCalls  int
+		wantErr    errors.ErrorType
+	}{
+		{"success", 3, nil, 1, ""},
+		{"rate limited then sent", 3, []error{rateLimited(10 * time.Millisecond), rateLimited(10 * time.Millisecond)}, 3, ""},
+		{"server error then sent", 3, []error{httpStatus(http.StatusInternalServerError)}, 2, ""},
+		{"retries exhausted", 2, []error{rateLimited(time.Millisecond), rateLimited(time.Millisecond), rateLimited(time.Millisecond)}, 3, errors.ErrorTypeRateLimit},
+		{"not retryable", 3, []error{httpStatus(http.StatusNotFound)}, 1, errors.ErrorTypeNotFound},
+		{"retries disabled", 0, []error{httpStatus(http.StatusServiceUnavailable)}, 1, errors.ErrorTypeAPI},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher(t, tt.maxRetries, 10)
+			sender := &fakeSender{}
+
+			message, err := d.wait(d.enqueue("channel", "", sender.fn("m1", tt.failures...)))
+
+			if got := len(sender.sent()); got != tt.wantCalls {
+				t.Errorf("sent %d times, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if message == nil || message.ID != "m1" {
+					t.Errorf("message = %v, want m1", message)
+				}
+				return
+			}
+
+			var botErr *errors.BotError
+			if !stderrors.As(err, &botErr) || botErr.ErrorType != tt.wantErr {
+				t.Errorf("error = %v, want a %v error", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLaneOrder(t *testing.T) {
+	d := newTestDispatcher(t, 0, 10)
+	sender := &fakeSender{}
+
+	// The first message of channel a is held in flight until the rest of
+	// the lane is queued.
+	started, release := make(chan struct{}), make(chan struct{})
+	unblock := sync.OnceFunc(func() { close(release) })
+	t.Cleanup(unblock) // before Stop, which waits for the blocked request
+	first := sender.fn("a0")
+	blocking := func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		close(started)
+		<-release
+		return first(options...)
+	}
+
+	results := []<-chan result{d.enqueue("a", "", blocking)}
+	<-started
+	for i := 1; i < 5; i++ {
+		results = append(results, d.enqueue("a", "", sender.fn(fmt.Sprintf("a%d", i))))
+	}
+
+	// Another channel is not held up by the blocked lane.
+	if _, err := d.wait(d.enqueue("b", "", sender.fn("b0"))); err != nil {
+		t.Fatalf("channel b: %v", err)
+	}
+	if got := sender.sent(); len(got) != 1 || got[0] != "b0" {
+		t.Fatalf("sent %v while channel a was blocked, want [b0]", got)
+	}
+	if got := d.Depth(); got != 4 {
+		t.Errorf("Depth() = %d, want 4", got)
+	}
+
+	unblock()
+	for i, results := range results {
+		message, err := d.wait(results)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if want := fmt.Sprintf("a%d", i); message.ID != want {
+			t.Errorf("message %d = %s, want %s", i, message.ID, want)
+		}
+	}
+
+	want := []string{"b0", "a0", "a1", "a2", "a3", "a4"}
+	if got := sender.sent(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	d := newTestDispatcher(t, 0, 1)
+	sender := &fakeSender{}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	unblock := sync.OnceFunc(func() { close(release) })
+	t.Cleanup(unblock) // before Stop, which waits for the blocked request
+	blocking := func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		close(started)
+		<-release
+		return &discordgo.Message{ID: "m0"}, nil
+	}
+
+	inFlight := d.enqueue("channel", "", blocking)
+	<-started
+	queued := d.enqueue("channel", "", sender.fn("m1"))
+
+	_, err := d.wait(d.enqueue("channel", "", sender.fn("m2")))
+	var botErr *errors.BotError
+	if !stderrors.As(err, &botErr) || botErr.ErrorType != errors.ErrorTypeRateLimit {
+		t.Errorf("third message error = %v, want a dropped-message error", err)
+	}
+
+	unblock()
+	if _, err := d.wait(inFlight); err != nil {
+		t.Errorf("first message: %v", err)
+	}
+	if _, err := d.wait(queued); err != nil {
+		t.Errorf("second message: %v", err)
+	}
+	if got := sender.sent(); fmt.Sprint(got) != "[m1]" {
+		t.Errorf("sent %v, want [m1]", got)
+	}
+}
+
+func TestEditCoalescing(t *testing.T) {
+	d := newTestDispatcher(t, 0, 10)
+	sender := &fakeSender{}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	unblock := sync.OnceFunc(func() { close(release) })
+	t.Cleanup(unblock) // before Stop, which waits for the blocked request
+	first := sender.fn("edit1")
+	blocking := func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		close(started)
+		<-release
+		return first(options...)
+	}
+
+	edit1 := d.enqueue("channel", "message:1", blocking)
+	<-started
+	edit2 := d.enqueue("channel", "message:1", sender.fn("edit2"))
+	other := d.enqueue("channel", "message:2", sender.fn("other"))
+	edit3 := d.enqueue("channel", "message:1", sender.fn("edit3"))
+	unblock()
+
+	tests := []struct {
+		name    string
+		results <-chan result
+		want    string
+	}{
+		{"in-flight edit", edit1, "edit1"},
+		{"replaced edit", edit2, "edit3"},
+		{"other message", other, "other"},
+		{"newest edit", edit3, "edit3"},
+	}
+	for _, tt := range tests {
+		message, err := d.wait(tt.results)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if message.ID != tt.want {
+			t.Errorf("%s got the result of %s, want %s", tt.name, message.ID, tt.want)
+		}
+	}
+
+	// edit2 is never sent, and edit3 keeps edit2's place before "other".
+	want := []string{"edit1", "edit3", "other"}
+	if got := sender.sent(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestRetriedEditSuperseded(t *testing.T) {
+	d := newTestDispatcher(t, 3, 10)
+	sender := &fakeSender{}
+
+	// While the first edit is rate limited, a newer edit of the same message
+	// is queued; the first is then dropped instead of retried.
+	var newer <-chan result
+	first := func(options ...discordgo.RequestOption) (*discordgo.Message, error) {
+		sender.fn("edit1")()
+		newer = d.enqueue("channel", "message:1", sender.fn("edit2"))
+		return nil, rateLimited(10 * time.Millisecond)
+	}
+
+	message, err := d.wait(d.enqueue("channel", "message:1", first))
+	if err != nil {
+		t.Fatalf("first edit: %v", err)
+	}
+	if message.ID != "edit2" {
+		t.Errorf("first edit got the result of %s, want edit2", message.ID)
+	}
+	if message, err := d.wait(newer); err != nil || message.ID != "edit2" {
+		t.Errorf("newer edit = %v, %v, want edit2", message, err)
+	}
+
+	if got := sender.sent(); fmt.Sprint(got) != "[edit1 edit2]" {
+		t.Errorf("sent %v, want [edit1 edit2]", got)
+	}
+}
+
+func TestNotRunning(t *testing.T) {
+	d := NewDispatcher(&config.Config{BotType: "test", OutboundQueueSize: 10})
+	sender := &fakeSender{}
+
+	if _, err := d.wait(d.enqueue("channel", "", sender.fn("m1"))); err == nil {
+		t.Error("enqueue before Start succeeded")
+	}
+
+	d.Start(&discordgo.Session{})
+	d.Stop()
+	if _, err := d.wait(d.enqueue("channel", "", sender.fn("m2"))); err == nil {
+		t.Error("enqueue after Stop succeeded")
+	}
+
+	if got := sender.sent(); len(got) != 0 {
+		t.Errorf("sent %v, want nothing", got)
+	}
+}
@@ -0,0 +1,247 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// maxMessageSize bounds a single JSON-RPC line.
+const maxMessageSize = 8 * 1024 * 1024
+
+// Handler serves requests and notifications received on a connection. The
+// returned value is sent back as the result of a request; it is ignored for
+// notifications. Returning an *RPCError controls the error code.
+type Handler func(method string, params json.RawMessage) (any, error)
+
+// Conn is a bidirectional JSON-RPC connection over a line-delimited stream.
+// Both sides may send requests at any time.
+type Conn struct {
+	reader  io.Reader
+	writer  io.Writer
+	writeMu sync.Mutex
+	handler Handler
+
+	nextID  int64
+	pending map[string]chan *Message
+	mu      sync.Mutex
+
+	done    chan struct{}
+	doneErr error
+}
+
+// NewConn creates a connection reading from r and writing to w. Incoming
+// requests are passed to handler.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	return &Conn{
+		reader:  r,
+		writer:  w,
+		handler: handler,
+		pending: make(map[string]chan *Message),
+		done:    make(chan struct{}),
+	}
+}
+
+// Run reads messages until the stream is closed. It returns nil on a clean EOF.
+func (c *Conn) Run() error {
+	scanner := bufio.NewScanner(c.reader)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg Message
+		if decodeErr := json.Unmarshal(line, &msg); decodeErr != nil {
+			_ = c.send(&Message{JSONRPC: jsonrpcVersion, ID: json.RawMessage("null"), Error: NewRPCError(CodeParseError, decodeErr.Error())})
+			continue
+		}
+
+		c.dispatch(&msg)
+	}
+	err := scanner.Err()
+
+	c.close(err)
+	return err
+}
+
+// Done is closed once the connection stops reading.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the read error that closed the connection, if any.
+func (c *Conn) Err() error {
+	<-c.done
+	return c.doneErr
+}
+
+// Call sends a request and waits for its response, decoding the result into
+// result when it is non-nil.
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	id := strconv.FormatInt(c.nextID, 10)
+	reply := make(chan *Message, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(&Message{JSONRPC: jsonrpcVersion, ID: json.RawMessage(id), Method: method, Params: rawParams}); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("failed to decode %s result: %w", method, err)
+			}
+		}
+		return nil
+	case <-c.done:
+		return fmt.Errorf("connection closed while waiting for %s", method)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification, which has no response.
+func (c *Conn) Notify(method string, params any) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+
+	return c.send(&Message{JSONRPC: jsonrpcVersion, Method: method, Params: rawParams})
+}
+
+// dispatch routes an incoming message to a waiting caller or the handler.
+func (c *Conn) dispatch(msg *Message) {
+	if msg.isResponse() {
+		c.mu.Lock()
+		reply, ok := c.pending[idKey(msg.ID)]
+		c.mu.Unlock()
+
+		if ok {
+			reply <- msg
+		}
+		return
+	}
+
+	if msg.Method == "" {
+		return
+	}
+
+	// Serve requests concurrently so a slow handler doesn't block responses.
+	go func() {
+		result, err := c.handler(msg.Method, msg.Params)
+		if len(msg.ID) == 0 {
+			return
+		}
+
+		response := &Message{JSONRPC: jsonrpcVersion, ID: msg.ID}
+		if err != nil {
+			rpcErr, ok := err.(*RPCError)
+			if !ok {
+				rpcErr = NewRPCError(CodeInternalError, err.Error())
+			}
+			response.Error = rpcErr
+		} else {
+			raw, marshalErr := json.Marshal(result)
+			if marshalErr != nil {
+				response.Error = NewRPCError(CodeInternalError, marshalErr.Error())
+			} else {
+				response.Result = raw
+			}
+		}
+
+		_ = c.send(response)
+	}()
+}
+
+// send writes a single message followed by a newline.
+func (c *Conn) send(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	data = append(data, '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// close marks the connection as finished.
+func (c *Conn) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+	default:
+		c.doneErr = err
+		close(c.done)
+	}
+}
+
+// idKey returns the key of a request ID in the pending map. Equal IDs get the
+// same key however the peer encoded them: 1, 1.0 and 1e0 all become "1", the
+// form Call uses, and strings are quoted so "1" stays distinct from 1.
+func idKey(raw json.RawMessage) string {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var id any
+	if err := decoder.Decode(&id); err != nil {
+		return string(raw)
+	}
+
+	switch id := id.(type) {
+	case json.Number:
+		if n, err := id.Int64(); err == nil {
+			return strconv.FormatInt(n, 10)
+		}
+		if f, err := id.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return id.String()
+	case string:
+		return strconv.Quote(id)
+	default:
+		return string(bytes.TrimSpace(raw))
+	}
+}
+
+// decodeParams unmarshals request params, mapping failures to an invalid params error.
+func decodeParams(raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return NewRPCError(CodeInvalidParams, err.Error())
+	}
+	return nil
+}
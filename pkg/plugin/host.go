@@ -0,0 +1,521 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/audit"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/guilds"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+	"github.com/sawyer/go-discord-bots/pkg/outbound"
+)
+
+// interactionLifetime is how long Discord accepts followups for an interaction.
+const interactionLifetime = 15 * time.Minute
+
+// pendingInteraction is an interaction a plugin may still reply to.
+type pendingInteraction struct {
+	interaction *discordgo.Interaction
+	owner       *Process
+	received    time.Time
+}
+
+// Host runs plugins on behalf of a bot that owns the Discord session.
+type Host struct {
+	session  *discordgo.Session
+	outbound *outbound.Dispatcher
+	config   *config.Config
+	guilds   *guilds.Settings
+	audit    *audit.Recorder
+
+	plugins      []*Process
+	commands     map[string]*Process
+	events       map[string][]*Process
+	interactions map[string]*pendingInteraction
+	registered   []*discordgo.ApplicationCommand
+	mu           sync.RWMutex
+
+	removeHandlers []func()
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+}
+
+// NewHost creates a plugin host for an existing Discord session. Messages and
+// response edits requested by plugins are delivered through outbox. Plugin
+// commands follow the allowed channels and cooldowns of guildSettings when it
+// is not nil. Forwarded interactions are recorded in the audit log when
+// recorder is not nil.
+func NewHost(session *discordgo.Session, outbox *outbound.Dispatcher, cfg *config.Config, guildSettings *guilds.Settings, recorder *audit.Recorder) *Host {
+	return &Host{
+		session:      session,
+		outbound:     outbox,
+		config:       cfg,
+		guilds:       guildSettings,
+		audit:        recorder,
+		commands:     make(map[string]*Process),
+		events:       make(map[string][]*Process),
+		interactions: make(map[string]*pendingInteraction),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// Start launches all configured plugins and registers their commands. The
+// Discord session must already be open. A plugin that fails to start is logged
+// and skipped; Start only fails if no plugin could be started.
+func (h *Host) Start() error {
+	logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+
+	specs := append([]config.PluginConfig(nil), h.config.Plugins...)
+	if h.config.PluginDir != "" {
+		discovered, err := discoverPlugins(h.config.PluginDir)
+		if err != nil {
+			return err
+		}
+		specs = append(specs, discovered...)
+	}
+
+	for _, spec := range specs {
+		ctx, cancel := context.WithTimeout(context.Background(), h.config.RequestTimeout.Std())
+		p, err := startProcess(ctx, spec, h.config, h.handlerFor)
+		cancel()
+		if err != nil {
+			logging.LogError(logger, err, "Failed to start plugin")
+			continue
+		}
+
+		h.attach(p)
+		logger.Info("Plugin started",
+			"plugin", p.Name(),
+			"version", p.info.Version,
+			"commands", len(p.info.Commands),
+			"events", strings.Join(p.info.Events, ","),
+		)
+	}
+
+	if len(h.plugins) == 0 {
+		return errors.NewConfigError("no plugins could be started", nil)
+	}
+
+	if err := h.registerCommands(); err != nil {
+		return err
+	}
+
+	h.removeHandlers = append(h.removeHandlers,
+		h.session.AddHandler(h.onInteractionCreate),
+		h.session.AddHandler(h.onMessageCreate),
+		h.session.AddHandler(h.onMessageReactionAdd),
+		h.session.AddHandler(h.onGuildMemberAdd),
+	)
+
+	h.wg.Add(1)
+	go h.expireInteractions()
+
+	return nil
+}
+
+// Stop unregisters plugin commands and shuts every plugin down.
+func (h *Host) Stop() {
+	logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+
+	close(h.stopCh)
+	for _, remove := range h.removeHandlers {
+		remove()
+	}
+	h.wg.Wait()
+
+	h.removeCommands()
+
+	var wg sync.WaitGroup
+	for _, p := range h.plugins {
+		wg.Add(1)
+		go func(p *Process) {
+			defer wg.Done()
+			p.Stop(h.config.ShutdownTimeout.Std())
+			logger.Info("Plugin stopped", "plugin", p.Name())
+		}(p)
+	}
+	wg.Wait()
+}
+
+// Plugins returns the handshake information of every started plugin.
+func (h *Host) Plugins() []InitializeResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	infos := make([]InitializeResult, 0, len(h.plugins))
+	for _, p := range h.plugins {
+		infos = append(infos, p.info)
+	}
+	return infos
+}
+
+// attach records a started plugin's commands and event subscriptions.
+// Commands already claimed by an earlier plugin are dropped.
+func (h *Host) attach(p *Process) {
+	logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.plugins = append(h.plugins, p)
+
+	var kept []*discordgo.ApplicationCommand
+	for _, command := range p.info.Commands {
+		if command == nil || command.Name == "" {
+			continue
+		}
+		if owner, exists := h.commands[command.Name]; exists {
+			logger.Warn("Command already provided by another plugin, ignoring",
+				"plugin", p.Name(), "command", command.Name, "owner", owner.Name())
+			continue
+		}
+		h.commands[command.Name] = p
+		kept = append(kept, command)
+	}
+	p.info.Commands = kept
+
+	for _, event := range p.info.Events {
+		switch event {
+		case EventMessageCreate, EventMessageReactionAdd, EventGuildMemberAdd:
+			h.events[event] = append(h.events[event], p)
+		default:
+			logger.Warn("Plugin subscribed to unknown event", "plugin", p.Name(), "event", event)
+		}
+	}
+
+	go h.watchExit(p)
+}
+
+// watchExit logs when a plugin process exits unexpectedly.
+func (h *Host) watchExit(p *Process) {
+	select {
+	case <-p.Exited():
+	case <-h.stopCh:
+		return
+	}
+
+	select {
+	case <-h.stopCh:
+	default:
+		logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+		logger.Error("Plugin exited unexpectedly", "plugin", p.Name(), "error", p.exitErr)
+		metrics.RecordPerformanceMetric("plugin", "crashes", 1, "count")
+	}
+}
+
+// registerCommands registers every plugin command with Discord.
+func (h *Host) registerCommands() error {
+	logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, p := range h.plugins {
+		for _, command := range p.info.Commands {
+			cmd, err := h.session.ApplicationCommandCreate(h.session.State.User.ID, h.config.GuildID, command)
+			if err != nil {
+				return errors.WithContext(
+					errors.NewDiscordError(fmt.Sprintf("failed to register command %s", command.Name), err),
+					"plugin", p.Name())
+			}
+			h.registered = append(h.registered, cmd)
+			logger.Info("Registered plugin command", "plugin", p.Name(), "command", command.Name, "guild_specific", h.config.GuildID != "")
+		}
+	}
+
+	return nil
+}
+
+// removeCommands deletes every command registered by registerCommands.
+func (h *Host) removeCommands() {
+	logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, cmd := range h.registered {
+		if err := h.session.ApplicationCommandDelete(h.session.State.User.ID, h.config.GuildID, cmd.ID); err != nil {
+			logger.Error("Failed to delete plugin command", "command", cmd.Name, "error", err)
+		}
+	}
+	h.registered = nil
+}
+
+// onInteractionCreate forwards interactions to the plugin that owns them.
+func (h *Host) onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	owner := h.ownerOf(i.Interaction)
+	if owner == nil {
+		return
+	}
+
+	start := time.Now()
+	logger := logging.BotComponent(string(h.config.BotType), "plugin-host").With("plugin", owner.Name())
+
+	if !owner.Running() {
+		logger.Warn("Interaction for a plugin that is not running")
+		h.respondUnavailable(s, i.Interaction)
+		h.audit.RecordResult(audit.FromInteraction(i), start, errors.NewInternalError("plugin not running", nil))
+		return
+	}
+
+	if h.guilds != nil {
+		if ok, err := h.guilds.CheckInteraction(s, i); !ok {
+			if err != nil {
+				logger.Error("Failed to send command rejection", "error", err)
+			}
+			h.audit.RecordResult(audit.FromInteraction(i), start, errors.NewPermissionError("command not allowed by guild settings", nil))
+			return
+		}
+	}
+
+	h.mu.Lock()
+	h.interactions[i.ID] = &pendingInteraction{interaction: i.Interaction, owner: owner, received: time.Now()}
+	h.mu.Unlock()
+
+	err := owner.conn.Notify(MethodInteraction, InteractionParams{Interaction: i.Interaction})
+	h.audit.RecordResult(audit.FromInteraction(i), start, err)
+	if err != nil {
+		logger.Error("Failed to forward interaction", "error", err)
+		h.respondUnavailable(s, i.Interaction)
+	}
+}
+
+// ownerOf finds the plugin responsible for an interaction.
+func (h *Host) ownerOf(i *discordgo.Interaction) *Process {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		return h.commands[i.ApplicationCommandData().Name]
+	case discordgo.InteractionMessageComponent:
+		return h.pluginByPrefix(i.MessageComponentData().CustomID)
+	case discordgo.InteractionModalSubmit:
+		return h.pluginByPrefix(i.ModalSubmitData().CustomID)
+	}
+	return nil
+}
+
+// pluginByPrefix finds the plugin named by a "<plugin>:" custom ID prefix.
+func (h *Host) pluginByPrefix(customID string) *Process {
+	name, _, found := strings.Cut(customID, ":")
+	if !found {
+		return nil
+	}
+	for _, p := range h.plugins {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// respondUnavailable tells the user that the plugin can't handle the interaction.
+func (h *Host) respondUnavailable(s *discordgo.Session, i *discordgo.Interaction) {
+	err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "❌ This command is temporarily unavailable.",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+		logger.Debug("Failed to send unavailable response", "error", err)
+	}
+}
+
+// onMessageCreate forwards non-bot messages to subscribed plugins.
+func (h *Host) onMessageCreate(_ *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+	h.broadcast(EventMessageCreate, m.Message)
+}
+
+// onMessageReactionAdd forwards reactions to subscribed plugins.
+func (h *Host) onMessageReactionAdd(_ *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	h.broadcast(EventMessageReactionAdd, r.MessageReaction)
+}
+
+// onGuildMemberAdd forwards member joins to subscribed plugins.
+func (h *Host) onGuildMemberAdd(_ *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	h.broadcast(EventGuildMemberAdd, m.Member)
+}
+
+// broadcast sends an event notification to every running subscriber.
+func (h *Host) broadcast(event string, payload any) {
+	h.mu.RLock()
+	subscribers := h.events[event]
+	h.mu.RUnlock()
+
+	if len(subscribers) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+		logger.Error("Failed to encode event", "event", event, "error", err)
+		return
+	}
+
+	params := EventParams{Name: event, Data: data}
+	for _, p := range subscribers {
+		if !p.Running() {
+			continue
+		}
+		if err := p.conn.Notify(MethodEvent, params); err != nil {
+			logger := logging.BotComponent(string(h.config.BotType), "plugin-host")
+			logger.Debug("Failed to forward event", "plugin", p.Name(), "event", event, "error", err)
+		}
+	}
+}
+
+// handlerFor builds the JSON-RPC handler serving callbacks from a plugin.
+func (h *Host) handlerFor(p *Process) Handler {
+	return func(method string, params json.RawMessage) (any, error) {
+		start := time.Now()
+		result, err := h.handleCall(p, method, params)
+		metrics.RecordAPIRequest("plugin:"+p.Name(), method, err == nil, time.Since(start))
+		return result, err
+	}
+}
+
+// handleCall executes a single plugin callback.
+func (h *Host) handleCall(p *Process, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case MethodRespond:
+		var req RespondParams
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		i, err := h.interactionFor(p, req.InteractionID)
+		if err != nil {
+			return nil, err
+		}
+		if req.Response == nil {
+			return nil, NewRPCError(CodeInvalidParams, "response is required")
+		}
+		if err := h.session.InteractionRespond(i, req.Response); err != nil {
+			return nil, errors.NewDiscordError("failed to respond to interaction", err)
+		}
+		return struct{}{}, nil
+
+	case MethodFollowup:
+		var req FollowupParams
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		i, err := h.interactionFor(p, req.InteractionID)
+		if err != nil {
+			return nil, err
+		}
+		if req.Message == nil {
+			return nil, NewRPCError(CodeInvalidParams, "message is required")
+		}
+		msg, err := h.session.FollowupMessageCreate(i, true, req.Message)
+		if err != nil {
+			return nil, errors.NewDiscordError("failed to send followup", err)
+		}
+		return MessageResult{MessageID: msg.ID, ChannelID: msg.ChannelID}, nil
+
+	case MethodEditResponse:
+		var req EditResponseParams
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		i, err := h.interactionFor(p, req.InteractionID)
+		if err != nil {
+			return nil, err
+		}
+		if req.Edit == nil {
+			return nil, NewRPCError(CodeInvalidParams, "edit is required")
+		}
+		msg, err := h.outbound.EditInteraction(i, req.Edit)
+		if err != nil {
+			return nil, errors.NewDiscordError("failed to edit interaction response", err)
+		}
+		return MessageResult{MessageID: msg.ID, ChannelID: msg.ChannelID}, nil
+
+	case MethodSendMessage:
+		var req SendMessageParams
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		if req.ChannelID == "" || req.Message == nil {
+			return nil, NewRPCError(CodeInvalidParams, "channel_id and message are required")
+		}
+		msg, err := h.outbound.Send(req.ChannelID, req.Message)
+		if err != nil {
+			return nil, errors.NewDiscordError("failed to send message", err)
+		}
+		return MessageResult{MessageID: msg.ID, ChannelID: msg.ChannelID}, nil
+
+	case MethodLog:
+		var req LogParams
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		args := make([]any, 0, len(req.Fields)*2)
+		for key, value := range req.Fields {
+			args = append(args, key, value)
+		}
+		switch strings.ToLower(req.Level) {
+		case "debug":
+			p.logger.Debug(req.Message, args...)
+		case "warn", "warning":
+			p.logger.Warn(req.Message, args...)
+		case "error":
+			p.logger.Error(req.Message, args...)
+		default:
+			p.logger.Info(req.Message, args...)
+		}
+		return struct{}{}, nil
+	}
+
+	return nil, NewRPCError(CodeMethodNotFound, fmt.Sprintf("unknown method %s", method))
+}
+
+// interactionFor returns a pending interaction owned by the calling plugin.
+func (h *Host) interactionFor(p *Process, id string) (*discordgo.Interaction, error) {
+	h.mu.RLock()
+	pending, ok := h.interactions[id]
+	h.mu.RUnlock()
+
+	if !ok || pending.owner != p {
+		return nil, NewRPCError(CodeInvalidParams, fmt.Sprintf("unknown or expired interaction %s", id))
+	}
+	return pending.interaction, nil
+}
+
+// expireInteractions drops interactions whose tokens Discord no longer accepts.
+func (h *Host) expireInteractions() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case now := <-ticker.C:
+			h.mu.Lock()
+			for id, pending := range h.interactions {
+				if now.Sub(pending.received) > interactionLifetime {
+					delete(h.interactions, id)
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
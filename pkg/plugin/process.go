@@ -0,0 +1,214 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+)
+
+// Process is a running plugin executable and its JSON-RPC connection.
+type Process struct {
+	spec   config.PluginConfig
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	conn   *Conn
+	info   InitializeResult
+	logger *slog.Logger
+
+	exited  chan struct{}
+	exitErr error
+	once    sync.Once
+}
+
+// pluginEnvAllowlist are the variables of the launcher's environment that
+// plugins inherit. Everything else, such as bot tokens, secret files and
+// database URLs, stays with the launcher.
+var pluginEnvAllowlist = []string{"PATH", "HOME", "LANG", "TZ"}
+
+// pluginEnv returns the environment of a plugin: the allowlisted variables of
+// the launcher's environment and the plugin's configured env.
+func pluginEnv(spec config.PluginConfig) []string {
+	env := make([]string, 0, len(pluginEnvAllowlist)+len(spec.Env))
+	for _, name := range pluginEnvAllowlist {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, spec.Env...)
+}
+
+// startProcess launches a plugin for the bot configured by cfg and performs
+// the initialize handshake.
+func startProcess(ctx context.Context, spec config.PluginConfig, cfg *config.Config, handler func(p *Process) Handler) (*Process, error) {
+	cmd := exec.Command(spec.Command, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = pluginEnv(spec)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.NewInternalError("failed to open plugin stdin", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.NewInternalError("failed to open plugin stdout", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.NewInternalError("failed to open plugin stderr", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.WithContext(errors.NewInternalError("failed to start plugin", err), "plugin", spec.Name)
+	}
+
+	p := &Process{
+		spec:   spec,
+		cmd:    cmd,
+		stdin:  stdin,
+		logger: logging.BotComponent(string(cfg.BotType), "plugin").With("plugin", spec.Name),
+		exited: make(chan struct{}),
+	}
+	p.conn = NewConn(stdout, stdin, handler(p))
+
+	stderrDone := make(chan struct{})
+	go func() {
+		p.forwardStderr(stderr)
+		close(stderrDone)
+	}()
+	go func() {
+		_ = p.conn.Run()
+		// Wait closes the stderr pipe, so the last lines of a crashing
+		// plugin, usually its panic, must be read first.
+		<-stderrDone
+		p.exitErr = cmd.Wait()
+		close(p.exited)
+	}()
+
+	params := InitializeParams{ProtocolVersion: ProtocolVersion, BotName: cfg.BotName}
+	if err := p.conn.Call(ctx, MethodInitialize, params, &p.info); err != nil {
+		p.kill()
+		return nil, errors.WithContext(errors.NewInternalError("plugin handshake failed", err), "plugin", spec.Name)
+	}
+
+	if p.info.ProtocolVersion != ProtocolVersion {
+		p.kill()
+		return nil, errors.WithContext(
+			errors.NewInternalError(fmt.Sprintf("unsupported protocol version %d (host speaks %d)", p.info.ProtocolVersion, ProtocolVersion), nil),
+			"plugin", spec.Name)
+	}
+
+	if p.info.Name == "" {
+		p.info.Name = spec.Name
+	}
+
+	return p, nil
+}
+
+// Name returns the configured plugin name.
+func (p *Process) Name() string {
+	return p.spec.Name
+}
+
+// Info returns what the plugin declared during the handshake.
+func (p *Process) Info() InitializeResult {
+	return p.info
+}
+
+// Running reports whether the plugin process is still alive.
+func (p *Process) Running() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Exited is closed once the plugin process has exited.
+func (p *Process) Exited() <-chan struct{} {
+	return p.exited
+}
+
+// Stop asks the plugin to shut down and kills it if it doesn't exit in time.
+func (p *Process) Stop(timeout time.Duration) {
+	p.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		if err := p.conn.Call(ctx, MethodShutdown, struct{}{}, nil); err != nil {
+			p.logger.Debug("Plugin did not acknowledge shutdown", "error", err)
+		}
+		_ = p.stdin.Close()
+
+		select {
+		case <-p.exited:
+		case <-ctx.Done():
+			p.logger.Warn("Plugin did not exit in time, killing it")
+			p.kill()
+		}
+	})
+}
+
+// kill terminates the plugin process immediately.
+func (p *Process) kill() {
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	_ = p.stdin.Close()
+}
+
+// forwardStderr logs everything the plugin writes to stderr.
+func (p *Process) forwardStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.logger.Info(scanner.Text(), "stream", "stderr")
+	}
+}
+
+// discoverPlugins returns a plugin spec for every executable file in dir.
+// The file name (without extension) becomes the plugin name.
+func discoverPlugins(dir string) ([]config.PluginConfig, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.NewConfigError("failed to read plugin directory", err)
+	}
+
+	var specs []config.PluginConfig
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+
+		path, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		specs = append(specs, config.PluginConfig{
+			Name:    strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Command: path,
+			Dir:     dir,
+		})
+	}
+
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
@@ -0,0 +1,165 @@
+// Package plugin implements out-of-process plugin modules for the Discord bots.
+//
+// Plugins are external executables that speak JSON-RPC 2.0 over stdio, one JSON
+// message per line. The host owns the gateway connection: it starts each plugin,
+// asks it for the commands and events it wants during the initialize handshake,
+// registers those commands with Discord, and forwards matching interactions and
+// events. Plugins reply by calling back into the host (interaction.respond,
+// message.send, ...). Anything written to a plugin's stderr is logged by the host.
+//
+// Component and modal interactions are routed by custom ID: a plugin must prefix
+// the custom IDs it creates with "<plugin name>:".
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ProtocolVersion is the plugin protocol version implemented by this package.
+// The host refuses plugins that report a different version.
+const ProtocolVersion = 1
+
+const jsonrpcVersion = "2.0"
+
+// Methods the host calls on a plugin.
+const (
+	// MethodInitialize is the handshake request sent once after the plugin starts.
+	MethodInitialize = "initialize"
+	// MethodShutdown asks the plugin to exit cleanly.
+	MethodShutdown = "shutdown"
+	// MethodInteraction is a notification carrying an interaction for the plugin.
+	MethodInteraction = "interaction"
+	// MethodEvent is a notification carrying a gateway event the plugin subscribed to.
+	MethodEvent = "event"
+)
+
+// Methods a plugin calls on the host.
+const (
+	// MethodRespond sends the initial response to an interaction.
+	MethodRespond = "interaction.respond"
+	// MethodFollowup sends a followup message for an interaction.
+	MethodFollowup = "interaction.followup"
+	// MethodEditResponse edits the original interaction response.
+	MethodEditResponse = "interaction.edit"
+	// MethodSendMessage sends a message to a channel.
+	MethodSendMessage = "message.send"
+	// MethodLog writes a line to the host log.
+	MethodLog = "log"
+)
+
+// Gateway events a plugin can subscribe to.
+const (
+	// EventMessageCreate is sent for every non-bot message the host can see.
+	EventMessageCreate = "message_create"
+	// EventMessageReactionAdd is sent when a reaction is added to a message.
+	EventMessageReactionAdd = "message_reaction_add"
+	// EventGuildMemberAdd is sent when a member joins a guild.
+	EventGuildMemberAdd = "guild_member_add"
+)
+
+// Standard JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Message is a single JSON-RPC request, notification or response.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// isResponse reports whether the message answers an earlier request.
+func (m *Message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// RPCError is a JSON-RPC error object.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// NewRPCError creates a JSON-RPC error with the given code.
+func NewRPCError(code int, message string) *RPCError {
+	return &RPCError{Code: code, Message: message}
+}
+
+// InitializeParams is sent by the host in the initialize request.
+type InitializeParams struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	BotName         string `json:"bot_name"`
+}
+
+// InitializeResult is the plugin's answer to the initialize request.
+type InitializeResult struct {
+	Name            string                          `json:"name"`
+	Version         string                          `json:"version"`
+	ProtocolVersion int                             `json:"protocol_version"`
+	Commands        []*discordgo.ApplicationCommand `json:"commands,omitempty"`
+	Events          []string                        `json:"events,omitempty"`
+}
+
+// InteractionParams carries an interaction to the plugin that owns it.
+type InteractionParams struct {
+	Interaction *discordgo.Interaction `json:"interaction"`
+}
+
+// EventParams carries a gateway event to a subscribed plugin.
+type EventParams struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// RespondParams is the payload of interaction.respond.
+type RespondParams struct {
+	InteractionID string                         `json:"interaction_id"`
+	Response      *discordgo.InteractionResponse `json:"response"`
+}
+
+// FollowupParams is the payload of interaction.followup.
+type FollowupParams struct {
+	InteractionID string                   `json:"interaction_id"`
+	Message       *discordgo.WebhookParams `json:"message"`
+}
+
+// EditResponseParams is the payload of interaction.edit.
+type EditResponseParams struct {
+	InteractionID string                 `json:"interaction_id"`
+	Edit          *discordgo.WebhookEdit `json:"edit"`
+}
+
+// SendMessageParams is the payload of message.send.
+type SendMessageParams struct {
+	ChannelID string                 `json:"channel_id"`
+	Message   *discordgo.MessageSend `json:"message"`
+}
+
+// MessageResult is returned by methods that create or edit a message.
+type MessageResult struct {
+	MessageID string `json:"message_id"`
+	ChannelID string `json:"channel_id"`
+}
+
+// LogParams is the payload of the log method.
+type LogParams struct {
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
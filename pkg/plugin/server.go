@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Server is the plugin side of the protocol for plugins written in Go. Fill in
+// the declaration fields and handlers, then call Serve from the plugin's main.
+type Server struct {
+	Name     string
+	Version  string
+	Commands []*discordgo.ApplicationCommand
+	Events   []string
+
+	// OnInteraction is called for every interaction routed to the plugin.
+	OnInteraction func(c *Client, i *discordgo.Interaction)
+
+	// OnEvent is called for every subscribed gateway event.
+	OnEvent func(c *Client, name string, data json.RawMessage)
+
+	// OnShutdown is called when the host asks the plugin to exit.
+	OnShutdown func()
+}
+
+// Client lets a plugin call back into the host.
+type Client struct {
+	conn *Conn
+}
+
+// Serve runs the plugin over stdin and stdout until the host closes the pipe.
+func (s *Server) Serve() error {
+	return s.ServeConn(os.Stdin, os.Stdout)
+}
+
+// ServeConn runs the plugin over the given streams.
+func (s *Server) ServeConn(r io.Reader, w io.Writer) error {
+	client := &Client{}
+	client.conn = NewConn(r, w, func(method string, params json.RawMessage) (any, error) {
+		switch method {
+		case MethodInitialize:
+			return InitializeResult{
+				Name:            s.Name,
+				Version:         s.Version,
+				ProtocolVersion: ProtocolVersion,
+				Commands:        s.Commands,
+				Events:          s.Events,
+			}, nil
+
+		case MethodShutdown:
+			if s.OnShutdown != nil {
+				s.OnShutdown()
+			}
+			return struct{}{}, nil
+
+		case MethodInteraction:
+			var req InteractionParams
+			if err := decodeParams(params, &req); err != nil {
+				return nil, err
+			}
+			if s.OnInteraction != nil && req.Interaction != nil {
+				s.OnInteraction(client, req.Interaction)
+			}
+			return nil, nil
+
+		case MethodEvent:
+			var req EventParams
+			if err := decodeParams(params, &req); err != nil {
+				return nil, err
+			}
+			if s.OnEvent != nil {
+				s.OnEvent(client, req.Name, req.Data)
+			}
+			return nil, nil
+		}
+
+		return nil, NewRPCError(CodeMethodNotFound, "unknown method "+method)
+	})
+
+	return client.conn.Run()
+}
+
+// Respond sends the initial response to an interaction.
+func (c *Client) Respond(ctx context.Context, interactionID string, response *discordgo.InteractionResponse) error {
+	return c.conn.Call(ctx, MethodRespond, RespondParams{InteractionID: interactionID, Response: response}, nil)
+}
+
+// Reply responds to an interaction with a plain text message.
+func (c *Client) Reply(ctx context.Context, interactionID, content string) error {
+	return c.Respond(ctx, interactionID, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content},
+	})
+}
+
+// Followup sends a followup message for an interaction.
+func (c *Client) Followup(ctx context.Context, interactionID string, message *discordgo.WebhookParams) (*MessageResult, error) {
+	var result MessageResult
+	if err := c.conn.Call(ctx, MethodFollowup, FollowupParams{InteractionID: interactionID, Message: message}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EditResponse edits the original response to an interaction.
+func (c *Client) EditResponse(ctx context.Context, interactionID string, edit *discordgo.WebhookEdit) (*MessageResult, error) {
+	var result MessageResult
+	if err := c.conn.Call(ctx, MethodEditResponse, EditResponseParams{InteractionID: interactionID, Edit: edit}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendMessage sends a message to a channel.
+func (c *Client) SendMessage(ctx context.Context, channelID string, message *discordgo.MessageSend) (*MessageResult, error) {
+	var result MessageResult
+	if err := c.conn.Call(ctx, MethodSendMessage, SendMessageParams{ChannelID: channelID, Message: message}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Log writes a structured line to the host log.
+func (c *Client) Log(level, message string, fields map[string]any) error {
+	return c.conn.Notify(MethodLog, LogParams{Level: level, Message: message, Fields: fields})
+}
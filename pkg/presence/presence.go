@@ -0,0 +1,315 @@
+// Package presence rotates a bot's Discord status through configured activities.
+//
+// Activity texts are text/templates rendered with live values each time the
+// rotation advances. Every bot gets {{.Guilds}}, {{.Uptime}}, {{.Commands}},
+// {{.BotName}} and {{.Prefix}}; bots add their own values with AddValues. A bot
+// can temporarily replace the rotation with SetOverride, which is polled so the
+// status follows changing state such as the current music track.
+package presence
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/errors"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+)
+
+// pollInterval is how often the override and rotation are checked.
+const pollInterval = 15 * time.Second
+
+// maxTextLength is the longest activity text Discord accepts.
+const maxTextLength = 128
+
+// activityTypes maps configured type names to Discord activity types.
+var activityTypes = map[string]discordgo.ActivityType{
+	"playing":   discordgo.ActivityTypeGame,
+	"listening": discordgo.ActivityTypeListening,
+	"watching":  discordgo.ActivityTypeWatching,
+	"competing": discordgo.ActivityTypeCompeting,
+	"custom":    discordgo.ActivityTypeCustom,
+}
+
+// Values holds the template values available to activity texts.
+type Values map[string]interface{}
+
+// Activity is a rendered status.
+type Activity struct {
+	Type discordgo.ActivityType
+	Text string
+}
+
+// activity is a configured activity with its compiled template.
+type activity struct {
+	activityType discordgo.ActivityType
+	text         *template.Template
+}
+
+// Manager rotates the status of a single Discord session.
+type Manager struct {
+	botName    string
+	botType    string
+	prefix     string
+	interval   time.Duration
+	activities []activity
+	providers  []func(Values)
+	override   func() *Activity
+	started    time.Time
+
+	session  *discordgo.Session
+	index    int
+	rotated  time.Time
+	rotation *Activity
+	current  *Activity
+	mu       sync.Mutex
+
+	removeHandler func()
+	stopCh        chan struct{}
+	wg            sync.WaitGroup
+}
+
+// NewManager creates a presence manager from the presence settings of cfg.
+func NewManager(cfg *config.Config) (*Manager, error) {
+	m := &Manager{
+		botName: cfg.BotName,
+		botType: string(cfg.BotType),
+		prefix:  cfg.CommandPrefix,
+		stopCh:  make(chan struct{}),
+	}
+
+	if err := m.Update(cfg); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Update replaces the activities and rotation interval. The next activity is
+// shown on the following poll.
+func (m *Manager) Update(cfg *config.Config) error {
+	activities := make([]activity, 0, len(cfg.Presence))
+	for i, configured := range cfg.Presence {
+		activityType, ok := activityTypes[configured.Type]
+		if !ok {
+			return errors.NewConfigError(fmt.Sprintf("presence[%d]: unknown activity type '%s'", i, configured.Type), nil)
+		}
+
+		text, err := template.New(fmt.Sprintf("presence[%d]", i)).Parse(configured.Text)
+		if err != nil {
+			return errors.NewConfigError(fmt.Sprintf("presence[%d]: invalid text template", i), err)
+		}
+
+		activities = append(activities, activity{activityType: activityType, text: text})
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.activities = activities
+	m.interval = cfg.PresenceInterval.Std()
+	m.index = -1
+	m.rotated = time.Time{}
+	m.rotation = nil
+
+	return nil
+}
+
+// AddValues registers a function that adds bot-specific template values. It
+// must be called before Start.
+func (m *Manager) AddValues(provider func(Values)) {
+	m.providers = append(m.providers, provider)
+}
+
+// SetOverride registers a function whose activity, when not nil, is shown
+// instead of the rotation. It must be called before Start.
+func (m *Manager) SetOverride(override func() *Activity) {
+	m.override = override
+}
+
+// Start shows the first activity and begins rotating. The session must be open.
+func (m *Manager) Start(session *discordgo.Session) {
+	m.session = session
+	m.started = time.Now()
+
+	// A new gateway session starts without a status, so show it again.
+	m.removeHandler = session.AddHandler(func(_ *discordgo.Session, _ *discordgo.Ready) {
+		m.mu.Lock()
+		m.current = nil
+		m.mu.Unlock()
+		m.refresh()
+	})
+
+	m.wg.Add(1)
+	go m.run()
+}
+
+// Stop stops rotating. The current status is left in place.
+func (m *Manager) Stop() {
+	if m.session == nil {
+		return
+	}
+
+	close(m.stopCh)
+	m.wg.Wait()
+	m.removeHandler()
+}
+
+// run refreshes the status until stopped.
+func (m *Manager) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.refresh()
+
+		select {
+		case <-ticker.C:
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// refresh shows the override or, once the interval has passed, the next
+// activity of the rotation. Discord is only contacted when the status changes.
+func (m *Manager) refresh() {
+	var next *Activity
+	if m.override != nil {
+		next = m.override()
+	}
+
+	m.mu.Lock()
+	if next == nil {
+		next = m.rotate()
+	}
+	if sameActivity(next, m.current) {
+		m.mu.Unlock()
+		return
+	}
+	m.current = next
+	m.mu.Unlock()
+
+	m.send(next)
+}
+
+// rotate returns the rotation entry to show, advancing to the next one once
+// the interval has passed. It must be called with m.mu held.
+func (m *Manager) rotate() *Activity {
+	if len(m.activities) == 0 {
+		return nil
+	}
+
+	if m.index < 0 || time.Since(m.rotated) >= m.interval {
+		m.index = (m.index + 1) % len(m.activities)
+		m.rotated = time.Now()
+
+		// Keep showing the previous entry if this one fails to render.
+		if rendered := m.render(m.activities[m.index]); rendered != nil {
+			m.rotation = rendered
+		}
+	}
+
+	return m.rotation
+}
+
+// render executes an activity template with the current values. Rendering
+// errors are logged and yield nil.
+func (m *Manager) render(a activity) *Activity {
+	var buf bytes.Buffer
+	if err := a.text.Execute(&buf, m.values()); err != nil {
+		logger := logging.BotComponent(m.botType, "presence")
+		logger.Warn("Failed to render presence text", "template", a.text.Name(), "error", err)
+		return nil
+	}
+
+	text := buf.String()
+	if runes := []rune(text); len(runes) > maxTextLength {
+		text = string(runes[:maxTextLength-1]) + "…"
+	}
+
+	return &Activity{Type: a.activityType, Text: text}
+}
+
+// values collects the built-in and bot-specific template values.
+func (m *Manager) values() Values {
+	values := Values{
+		"BotName":  m.botName,
+		"Prefix":   m.prefix,
+		"Uptime":   formatUptime(time.Since(m.started)),
+		"Guilds":   0,
+		"Commands": int64(0),
+	}
+
+	if m.session != nil && m.session.State != nil {
+		m.session.State.RLock()
+		values["Guilds"] = len(m.session.State.Guilds)
+		m.session.State.RUnlock()
+	}
+
+	if total, ok := metrics.GetMetricsSummary()["commands_total"].(int64); ok {
+		values["Commands"] = total
+	}
+
+	for _, provider := range m.providers {
+		provider(values)
+	}
+
+	return values
+}
+
+// send updates the Discord status. A nil activity clears it.
+func (m *Manager) send(a *Activity) {
+	status := discordgo.UpdateStatusData{Status: string(discordgo.StatusOnline)}
+
+	if a != nil {
+		activity := &discordgo.Activity{Name: a.Text, Type: a.Type}
+		if a.Type == discordgo.ActivityTypeCustom {
+			// Custom statuses show State; Name is required but not displayed.
+			activity.Name = "Custom Status"
+			activity.State = a.Text
+		}
+		status.Activities = []*discordgo.Activity{activity}
+	}
+
+	if err := m.session.UpdateStatusComplex(status); err != nil {
+		logger := logging.BotComponent(m.botType, "presence")
+		logger.Debug("Failed to update presence", "error", err)
+
+		// Try again on the next poll.
+		m.mu.Lock()
+		m.current = nil
+		m.mu.Unlock()
+	}
+}
+
+// sameActivity reports whether two activities display the same status.
+func sameActivity(a, b *Activity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// formatUptime renders a duration as days, hours and minutes.
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
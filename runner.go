@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	clippy "github.com/sawyer/go-discord-bots/apps/clippy/discord"
+	mtg "github.com/sawyer/go-discord-bots/apps/mtg-card-bot/discord"
+	music "github.com/sawyer/go-discord-bots/apps/music/discord"
+	plugins "github.com/sawyer/go-discord-bots/apps/plugin-host/discord"
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	shareddiscord "github.com/sawyer/go-discord-bots/pkg/discord"
+	"github.com/sawyer/go-discord-bots/pkg/logging"
+	"github.com/sawyer/go-discord-bots/pkg/metrics"
+	"github.com/sawyer/go-discord-bots/pkg/monitoring"
+)
+
+// botApp describes a bot the launcher can run.
+type botApp struct {
+	// name is the value used with --bot.
+	name string
+	// title is the human-readable bot name.
+	title string
+	// binary is the name of the standalone build in bin/.
+	binary  string
+	botType config.BotType
+	run     func(ctx context.Context, cfg *config.Config) error
+	// commands returns the application commands the bot registers; nil if
+	// they are only known once the bot runs.
+	commands func(cfg *config.Config) []*discordgo.ApplicationCommand
+	// globalCommands is set for bots that never register guild commands.
+	globalCommands bool
+}
+
+// botApps lists every bot in the order they are started.
+var botApps = []botApp{
+	{
+		name: "clippy", title: "Clippy Bot", binary: "clippy", botType: config.BotTypeClipper, run: clippy.Run,
+		commands: func(*config.Config) []*discordgo.ApplicationCommand { return clippy.Commands() },
+	},
+	{
+		name: "music", title: "Music Bot", binary: "music", botType: config.BotTypeMusic, run: music.Run,
+		commands: music.Commands,
+	},
+	{
+		name: "mtg", title: "MTG Card Bot", binary: "mtg-card-bot", botType: config.BotTypeMTG, run: mtg.Run,
+		commands:       func(*config.Config) []*discordgo.ApplicationCommand { return mtg.Commands() },
+		globalCommands: true,
+	},
+	{
+		// Plugin commands are declared by the plugins when the host starts them
+		name: "plugins", title: "Plugin Host", binary: "plugin-host", botType: config.BotTypePlugins, run: plugins.Run,
+	},
+}
+
+// allBots are the bots started by --bot all.
+var allBots = []string{"clippy", "music", "mtg"}
+
+// selectBots resolves a --bot value such as "all" or "clippy,mtg".
+func selectBots(value string) ([]botApp, error) {
+	var names []string
+	for _, name := range strings.Split(strings.ToLower(value), ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			names = append(names, allBots...)
+		} else if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	var selected []botApp
+	seen := make(map[string]bool)
+	for _, name := range names {
+		app, ok := findBot(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid bot specified: %s (valid options: clippy, music, mtg, plugins, all)", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, app)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no bot specified (valid options: clippy, music, mtg, plugins, all)")
+	}
+	return selected, nil
+}
+
+// runOnce runs a bot in this process until it stops. A panic in the bot's
+// Run is returned as an error, so the restart policy applies to it like to
+// any other failure. Each run gets its own copy of the configuration.
+func runOnce(ctx context.Context, app botApp, cfg *config.Config) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	runCfg := *cfg
+	return app.run(ctx, &runCfg)
+}
+
+// findBot returns the bot with the given --bot name.
+func findBot(name string) (botApp, bool) {
+	for _, app := range botApps {
+		if app.name == name {
+			return app, true
+		}
+	}
+	return botApp{}, false
+}
+
+// loadOptions returns the configuration sources selected by --config, --set
+// and --debug.
+func loadOptions() config.LoadOptions {
+	flags := make(config.Overrides)
+	for key, value := range setFlag {
+		flags[key] = value
+	}
+	if debugFlag {
+		flags["debug_mode"] = "true"
+		flags["log_level"] = "debug"
+	}
+	return config.LoadOptions{Path: configFlag, Flags: flags}
+}
+
+// loadBotConfig loads the configuration of a bot: defaults, then --config,
+// then the environment, then --set and --debug.
+func loadBotConfig(app botApp) (*config.Config, error) {
+	return config.LoadWith(app.botType, loadOptions())
+}
+
+// printConfigs prints the effective configuration of each bot for
+// --print-config.
+func printConfigs(apps []botApp) error {
+	for _, app := range apps {
+		cfg, err := loadBotConfig(app)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n", app.title)
+		if err := cfg.Print(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runInProcess runs the given bots in this process until SIGINT or SIGTERM.
+// The bots share the log output, the metrics collector, the monitoring server
+// and database connection pools; each bot's records are tagged with its name. A bot that stops is restarted according to its
+// restart policy; one that exceeds its crash budget stops the others too.
+func runInProcess(apps []botApp, opts supervisorOptions) error {
+	// Validate every configuration before starting anything
+	configs := make([]*config.Config, len(apps))
+	var problems []string
+	for i, app := range apps {
+		cfg, err := loadBotConfig(app)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		for _, problem := range cfg.Problems() {
+			problems = append(problems, problem.Error())
+		}
+		configs[i] = cfg
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	// The first bot's settings decide the shared log level and format
+	logging.InitializeLogger(configs[0].LogLevel, configs[0].JSONLogging)
+	defer shareddiscord.WatchLogLevel(configs[0])()
+	// Tag each bot's records with its name and, with --log-dir, also write
+	// them to <dir>/<bot>.log
+	for _, app := range apps {
+		var out io.Writer
+		if logDirFlag != "" {
+			file, err := logging.NewRotatingFile(filepath.Join(logDirFlag, app.name+".log"), int64(logMaxSizeFlag)<<20, logMaxFilesFlag)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+			out = file
+		}
+		logging.AddBot(string(app.botType), out)
+	}
+
+	if len(apps) == 1 {
+		metrics.Initialize(configs[0].BotName, string(configs[0].BotType))
+	} else {
+		metrics.Initialize("Discord Bot Framework", "launcher")
+	}
+	defer metrics.Shutdown()
+
+	if monitorPortFlag > 0 {
+		monitor := monitoring.NewMonitor(monitorPortFlag)
+		if err := monitor.Start(); err != nil {
+			return err
+		}
+		defer func() { _ = monitor.Stop() }()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A bot that exceeds its crash budget cancels the rest
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		failed []string
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for i, app := range apps {
+		wg.Add(1)
+		go func(cfg *config.Config) {
+			defer wg.Done()
+
+			budget := newRestartBudget(opts, app.name)
+			botLogger := logging.BotComponent(string(app.botType), "launcher")
+			for {
+				botLogger.Info("Starting bot")
+				started := time.Now()
+				err := runOnce(ctx, app, cfg)
+				if ctx.Err() != nil {
+					botLogger.Info("Bot stopped")
+					return
+				}
+				if err != nil {
+					logging.LogError(botLogger, err, app.title+" stopped with an error")
+				} else {
+					botLogger.Info("Bot stopped")
+				}
+
+				delay, restart, exceeded := budget.next(err, time.Since(started))
+				if exceeded || (!restart && err != nil) {
+					mu.Lock()
+					failed = append(failed, app.name)
+					mu.Unlock()
+				}
+				if exceeded {
+					botLogger.Error("Bot exceeded its crash budget, stopping all bots",
+						"max_restarts", opts.maxRestarts, "restart_window", opts.restartWindow)
+					cancel()
+					return
+				}
+				if !restart {
+					return
+				}
+
+				botLogger.Info("Restarting bot", "delay", delay, "policy", budget.policy)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return
+				}
+				budget.restarted()
+			}
+		}(configs[i])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		case <-time.After(opts.stopTimeout):
+			return fmt.Errorf("bots did not stop within %s", opts.stopTimeout)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("bots failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
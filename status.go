@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sawyer/go-discord-bots/pkg/config"
+	"github.com/sawyer/go-discord-bots/pkg/monitoring"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusURLFlag  string
+	statusJSONFlag bool
+)
+
+// newStatusCmd returns the command for querying a running launcher.
+func newStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of a running instance",
+		Long:  "Query the monitoring endpoint of a running instance and print its health, metrics and active alerts",
+		Args:  cobra.NoArgs,
+		RunE:  runStatus,
+	}
+
+	defaultURL := fmt.Sprintf("http://localhost:%d", config.GetInt("MONITOR_PORT", 9090))
+	cmd.Flags().StringVar(&statusURLFlag, "url", defaultURL, "Base URL of the monitoring server")
+	cmd.Flags().BoolVar(&statusJSONFlag, "json", false, "Print the raw status document")
+
+	return cmd
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := strings.TrimRight(statusURLFlag, "/") + "/status"
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to reach monitoring server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("monitoring server returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read status: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	if statusJSONFlag {
+		_, err := out.Write(body)
+		return err
+	}
+
+	var status monitoring.Status
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("invalid status response: %w", err)
+	}
+
+	fmt.Fprintf(out, "Uptime:  %s\n", status.Uptime)
+	fmt.Fprintf(out, "Version: %s (%s)\n", status.Version, status.GoVersion)
+
+	if status.Health != nil {
+		fmt.Fprintf(out, "Health:  %s\n", status.Health.Overall)
+		for _, name := range sortedKeys(status.Health.Checks) {
+			check := status.Health.Checks[name]
+			if check.Error != "" {
+				fmt.Fprintf(out, "  %-20s %s: %s\n", name, check.Status, check.Error)
+			} else {
+				fmt.Fprintf(out, "  %-20s %s\n", name, check.Status)
+			}
+		}
+	}
+
+	if len(status.Metrics) > 0 {
+		fmt.Fprintln(out, "Metrics:")
+		for _, name := range sortedKeys(status.Metrics) {
+			fmt.Fprintf(out, "  %-24s %v\n", name, status.Metrics[name])
+		}
+	}
+
+	if len(status.Alerts) == 0 {
+		fmt.Fprintln(out, "Alerts:  none")
+		return nil
+	}
+	fmt.Fprintln(out, "Alerts:")
+	for _, alert := range status.Alerts {
+		fmt.Fprintf(out, "  [%s] %s: %s (since %s, %d times)\n",
+			alert.Severity, alert.Name, alert.Message, alert.StartTime.Format(time.RFC3339), alert.Count)
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// restartPolicy decides whether a bot is restarted after it exits.
+type restartPolicy string
+
+const (
+	// restartAlways restarts a bot whenever it exits.
+	restartAlways restartPolicy = "always"
+	// restartOnFailure restarts a bot only when it exits with an error.
+	restartOnFailure restartPolicy = "on-failure"
+	// restartNever leaves a bot stopped once it exits.
+	restartNever restartPolicy = "never"
+)
+
+// supervisorOptions configures how the supervisor restarts and stops bots.
+type supervisorOptions struct {
+	// defaultPolicy applies to every bot without an entry in policies.
+	defaultPolicy restartPolicy
+	policies      map[string]restartPolicy
+
+	// A bot that has to be restarted more than maxRestarts times within
+	// restartWindow has exceeded its crash budget.
+	maxRestarts   int
+	restartWindow time.Duration
+
+	// backoff is the delay before the first restart; it doubles for each
+	// consecutive restart up to maxBackoff.
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	// stopTimeout is how long bots get to exit after a forwarded signal
+	// before they are killed.
+	stopTimeout time.Duration
+}
+
+// parseRestartPolicies parses a --restart value such as
+// "on-failure,music=always,mtg=never". A bare policy sets the default;
+// name=policy entries override it for one bot.
+func parseRestartPolicies(value string, opts *supervisorOptions) error {
+	opts.policies = make(map[string]restartPolicy)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, policy, perBot := strings.Cut(entry, "=")
+		if !perBot {
+			policy = name
+		}
+
+		switch restartPolicy(policy) {
+		case restartAlways, restartOnFailure, restartNever:
+		default:
+			return fmt.Errorf("invalid restart policy '%s', must be one of: always, on-failure, never", policy)
+		}
+
+		if perBot {
+			opts.policies[name] = restartPolicy(policy)
+		} else {
+			opts.defaultPolicy = restartPolicy(policy)
+		}
+	}
+
+	return nil
+}
+
+// restartBudget applies the restart policy and crash budget of one bot to
+// its runs.
+type restartBudget struct {
+	opts     supervisorOptions
+	policy   restartPolicy
+	restarts []time.Time
+	// consecutive counts the restarts since the bot last stayed up for a
+	// whole window.
+	consecutive int
+}
+
+// newRestartBudget creates the budget of the bot called name.
+func newRestartBudget(opts supervisorOptions, name string) *restartBudget {
+	policy, ok := opts.policies[name]
+	if !ok {
+		policy = opts.defaultPolicy
+	}
+	return &restartBudget{opts: opts, policy: policy}
+}
+
+// next decides what follows a run that ended with err after ranFor: whether
+// the bot is restarted and after which delay, or whether it has exceeded its
+// crash budget.
+func (b *restartBudget) next(err error, ranFor time.Duration) (delay time.Duration, restart, exceeded bool) {
+	if b.policy == restartNever || (b.policy == restartOnFailure && err == nil) {
+		return 0, false, false
+	}
+
+	// Only restarts within the window count against the crash budget.
+	now := time.Now()
+	recent := b.restarts[:0]
+	for _, restarted := range b.restarts {
+		if now.Sub(restarted) < b.opts.restartWindow {
+			recent = append(recent, restarted)
+		}
+	}
+	b.restarts = recent
+
+	if len(b.restarts) >= b.opts.maxRestarts {
+		return 0, false, true
+	}
+
+	// A bot that stayed up for a whole window starts over with the
+	// shortest delay.
+	if ranFor >= b.opts.restartWindow {
+		b.consecutive = 0
+	}
+	delay = b.opts.backoff << b.consecutive
+	if delay <= 0 || delay > b.opts.maxBackoff {
+		delay = b.opts.maxBackoff
+	}
+	b.consecutive++
+
+	return delay, true, false
+}
+
+// restarted records that the bot was restarted.
+func (b *restartBudget) restarted() {
+	b.restarts = append(b.restarts, time.Now())
+}
+
+// childStatus summarizes the runs of one supervised bot.
+type childStatus struct {
+	name     string
+	restarts int
+	lastExit string
+	budget   bool // crash budget exceeded
+}
+
+// supervisor runs bot binaries as child processes and restarts them according
+// to their policy.
+type supervisor struct {
+	opts supervisorOptions
+	mux  *logMux
+	logs map[string]*botLog // by bot name
+
+	stopping chan struct{}
+	signals  chan os.Signal
+
+	processes map[string]*os.Process
+	statuses  []*childStatus
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// newSupervisor creates a supervisor with the given options. Launcher
+// messages are written to mux and the output of each bot to its log in logs.
+func newSupervisor(opts supervisorOptions, mux *logMux, logs map[string]*botLog) *supervisor {
+	return &supervisor{
+		opts:      opts,
+		mux:       mux,
+		logs:      logs,
+		stopping:  make(chan struct{}),
+		signals:   make(chan os.Signal, 1),
+		processes: make(map[string]*os.Process),
+	}
+}
+
+// run supervises the given bots until they have all stopped for good, a
+// SIGINT or SIGTERM stops them, or one of them exceeds its crash budget. It
+// returns an error in the last case.
+func (s *supervisor) run(apps []botApp) error {
+	signal.Notify(s.signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(s.signals)
+
+	for _, app := range apps {
+		status := &childStatus{name: app.name}
+		s.statuses = append(s.statuses, status)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if s.supervise(app, status) {
+				// Stop the remaining bots so the launcher can exit with an error.
+				s.stop(syscall.SIGTERM)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case sig := <-s.signals:
+		s.mux.printf("Received %s, stopping all bots...\n", sig)
+		s.stop(sig)
+		<-done
+	case <-done:
+	}
+
+	return s.report()
+}
+
+// supervise runs one bot until it stops for good. It reports whether the bot
+// exceeded its crash budget.
+func (s *supervisor) supervise(app botApp, status *childStatus) bool {
+	name := app.name
+	budget := newRestartBudget(s.opts, name)
+
+	for {
+		started := time.Now()
+		err := s.start(app)
+		ranFor := time.Since(started).Round(time.Second)
+
+		status.lastExit = exitDescription(err)
+		s.mux.printf("%s exited (%s) after %s\n", name, status.lastExit, ranFor)
+
+		select {
+		case <-s.stopping:
+			return false
+		default:
+		}
+
+		delay, restart, exceeded := budget.next(err, ranFor)
+		if exceeded {
+			status.budget = true
+			s.mux.printf("%s exceeded its crash budget (%d restarts within %s), giving up\n", name, s.opts.maxRestarts, s.opts.restartWindow)
+			return true
+		}
+		if !restart {
+			return false
+		}
+
+		s.mux.printf("Restarting %s in %s (policy %s)\n", name, delay, budget.policy)
+		select {
+		case <-time.After(delay):
+		case <-s.stopping:
+			return false
+		}
+
+		budget.restarted()
+		status.restarts++
+	}
+}
+
+// start runs a bot binary and waits for it to exit. The launcher's --config,
+// --set and --debug flags are passed on to the bot, and its output goes to its
+// log.
+func (s *supervisor) start(app botApp) error {
+	name := app.name
+	args := []string{"--config", configFlag}
+	for key, value := range loadOptions().Flags {
+		args = append(args, "--set", key+"="+value)
+	}
+	cmd := exec.Command(filepath.Join("bin", app.binary), args...)
+	stdout := s.logs[name].writer(false)
+	stderr := s.logs[name].writer(true)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	s.mux.printf("Starting %s...\n", name)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.processes[name] = cmd.Process
+	select {
+	case <-s.stopping:
+		// Stopping began while the bot was starting.
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+	default:
+	}
+	s.mu.Unlock()
+
+	err := cmd.Wait()
+	stdout.flush()
+	stderr.flush()
+
+	s.mu.Lock()
+	delete(s.processes, name)
+	s.mu.Unlock()
+
+	return err
+}
+
+// stop forwards sig to every running bot and kills those still running after
+// the stop timeout. It is safe to call more than once.
+func (s *supervisor) stop(sig os.Signal) {
+	s.mu.Lock()
+	select {
+	case <-s.stopping:
+		s.mu.Unlock()
+		return
+	default:
+		close(s.stopping)
+	}
+	for _, process := range s.processes {
+		_ = process.Signal(sig)
+	}
+	s.mu.Unlock()
+
+	go func() {
+		select {
+		case <-time.After(s.opts.stopTimeout):
+		case <-s.signals:
+			s.mux.printf("Received second signal, killing bots\n")
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for name, process := range s.processes {
+			s.mux.printf("%s did not stop in time, killing it\n", name)
+			_ = process.Kill()
+		}
+	}()
+}
+
+// report prints the final status of every bot and returns an error if any of
+// them exceeded its crash budget.
+func (s *supervisor) report() error {
+	var failed []string
+
+	s.mux.printf("Bot exit status:\n")
+	for _, status := range s.statuses {
+		s.mux.printf("  %-14s %-24s restarts: %d\n", status.name, status.lastExit, status.restarts)
+		if status.budget {
+			failed = append(failed, status.name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("crash budget exceeded: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// exitDescription describes how a bot process ended.
+func exitDescription(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return "signal: " + status.Signal().String()
+		}
+		return fmt.Sprintf("exit status %d", exitErr.ExitCode())
+	}
+
+	return err.Error()
+}